@@ -56,6 +56,13 @@ func main() {
 		log.Fatalf("Failed to migrate transcripts: %v", err)
 	}
 
+	// The transcripts were copied in with plain INSERTs, which bypass the
+	// Store's search indexing.
+	log.Println("Rebuilding search index...")
+	if err := store.RebuildSearchIndex(newDB); err != nil {
+		log.Fatalf("Failed to rebuild search index: %v", err)
+	}
+
 	log.Println("Migrating worker_status...")
 	if err := migrateWorkerStatus(oldDB, newDB); err != nil {
 		log.Printf("Warning: Failed to migrate worker_status (migth not exist in old DB): %v", err)
@@ -6,6 +6,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -24,6 +26,59 @@ type ChannelConfig struct {
 	// TwitchLogin is the channel's Twitch login used to build stream links
 	// for Twitch streams. Defaults to lowercase DisplayName.
 	TwitchLogin string `yaml:"twitchLogin"`
+	// Watchlist alerts on words or patterns spoken on this channel's streams.
+	Watchlist WatchlistConfig `yaml:"watchlist"`
+	// NormalizeLoudness loudness-normalises (EBU R128) the audio of this
+	// channel's clips, trims and full VODs by default. A request can ask
+	// otherwise.
+	NormalizeLoudness bool `yaml:"normalizeLoudness"`
+	// Encoding picks, by name, the encoding profiles this channel's audio is
+	// encoded with.
+	Encoding ChannelEncodingConfig `yaml:"encoding"`
+	// AutoVod builds each stream's full VOD on its own once the stream
+	// ends, instead of waiting for an admin to ask.
+	AutoVod AutoVodConfig `yaml:"autoVod"`
+	// ChunkSeconds is the length of the media chunks the channel's worker
+	// uploads, one per line. It is the target duration of the channel's HLS
+	// playlists. Zero means the default of 10 seconds.
+	ChunkSeconds int `yaml:"chunkSeconds"`
+}
+
+// AutoVodConfig schedules a channel's automatic full-VOD builds.
+type AutoVodConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// DelaySeconds is how long after a stream ends the build starts, so
+	// media the worker is still uploading makes it in. Zero means the
+	// default of 5 minutes.
+	DelaySeconds int `yaml:"delaySeconds"`
+}
+
+// ChannelEncodingConfig names the encodingProfiles entries a channel uses.
+// Empty leaves that output to ffmpeg's defaults for AAC in .m4a.
+type ChannelEncodingConfig struct {
+	// Audio is the profile for the per-line audio served to clients.
+	Audio string `yaml:"audio"`
+	// Clips is the profile for audio clips and trims.
+	Clips string `yaml:"clips"`
+	// Vods is the profile for full audio-only VODs.
+	Vods string `yaml:"vods"`
+}
+
+// WatchlistConfig lists what to watch a channel's transcript for. A line
+// matching any entry sends a Discord alert on the webhook, at most once per
+// entry per cooldown.
+type WatchlistConfig struct {
+	// Keywords are matched as whole words (or phrases), case-insensitively.
+	Keywords []string `yaml:"keywords"`
+	// Patterns are Go regular expressions matched against the line's text.
+	// They are case-sensitive unless they start with (?i).
+	Patterns []string `yaml:"patterns"`
+	// CooldownSeconds is how long an entry stays quiet after alerting.
+	// Zero means the default of 5 minutes.
+	CooldownSeconds int `yaml:"cooldownSeconds"`
+	// Broadcast also sends each alert to the channel's clients as a
+	// keywordHit event.
+	Broadcast bool `yaml:"broadcast"`
 }
 
 type R2Config struct {
@@ -72,6 +127,65 @@ type DatabaseConfig struct {
 	SkipWarmup    bool   `yaml:"skip_warmup"`
 }
 
+// ClipQueueConfig bounds the background clip and trim jobs. Both limits apply
+// to each channel separately; zero means the default.
+type ClipQueueConfig struct {
+	// Concurrency is how many of a channel's jobs may run ffmpeg at once.
+	// Defaults to 2.
+	Concurrency int `yaml:"concurrency"`
+	// MaxPending is how many of a channel's jobs may be queued or running
+	// before new requests are turned away. Defaults to 20.
+	MaxPending int `yaml:"maxPending"`
+}
+
+// AnimatedClipConfig sizes one animated clip format. Zero means the format's
+// default.
+type AnimatedClipConfig struct {
+	// MaxWidth caps the width in pixels; narrower video keeps its own.
+	MaxWidth int `yaml:"maxWidth"`
+	// FPS is the frame rate the clip is rendered at.
+	FPS int `yaml:"fps"`
+}
+
+// AnimatedClipsConfig sizes the gif and webm clips made from video streams.
+type AnimatedClipsConfig struct {
+	// GIF defaults to 480px wide at 12fps.
+	GIF AnimatedClipConfig `yaml:"gif"`
+	// WebM defaults to 720px wide at 30fps.
+	WebM AnimatedClipConfig `yaml:"webm"`
+}
+
+// FFmpegConfig bounds how long ffmpeg may run, in seconds per operation. A
+// run that takes longer is killed, along with anything it started. Zero means
+// the operation's default (see media.DefaultTimeouts).
+type FFmpegConfig struct {
+	ConvertSeconds      int `yaml:"convertSeconds"`
+	RemuxSeconds        int `yaml:"remuxSeconds"`
+	TrimSeconds         int `yaml:"trimSeconds"`
+	ExtractFrameSeconds int `yaml:"extractFrameSeconds"`
+	CutSeconds          int `yaml:"cutSeconds"`
+	ProbeSeconds        int `yaml:"probeSeconds"`
+	SubtitlesSeconds    int `yaml:"subtitlesSeconds"`
+	AnimateSeconds      int `yaml:"animateSeconds"`
+	PeaksSeconds        int `yaml:"peaksSeconds"`
+	// VodSeconds bounds a whole full-VOD build's encoding instead, since
+	// it works on an entire stream. Defaults to 4 hours.
+	VodSeconds int `yaml:"vodSeconds"`
+}
+
+// EncodingProfile is a named way of encoding audio.
+type EncodingProfile struct {
+	// Codec is "aac" (in .m4a) or "opus" (in .opus).
+	Codec string `yaml:"codec"`
+	// BitrateKbps is the target bitrate in kbit/s. Zero lets the encoder pick.
+	BitrateKbps int `yaml:"bitrateKbps"`
+	// SampleRate is in Hz. Zero keeps the source's.
+	SampleRate int `yaml:"sampleRate"`
+	// Channels is the channel count, e.g. 1 for mono. Zero keeps the
+	// source's.
+	Channels int `yaml:"channels"`
+}
+
 type Credentials struct {
 	ApiKey string `yaml:"apiKey"`
 }
@@ -86,6 +200,14 @@ type Config struct {
 	Storage    StorageConfig   `yaml:"storage"`
 	Channels   []ChannelConfig `yaml:"channels"`
 	Discord    DiscordConfig   `yaml:"discord"`
+	ClipQueue  ClipQueueConfig `yaml:"clipQueue"`
+	// AnimatedClips sizes gif and webm clips.
+	AnimatedClips AnimatedClipsConfig `yaml:"animatedClips"`
+	// EncodingProfiles are the audio encodings channels can pick from, by
+	// name.
+	EncodingProfiles map[string]EncodingProfile `yaml:"encodingProfiles"`
+	// FFmpeg bounds ffmpeg run times.
+	FFmpeg FFmpegConfig `yaml:"ffmpeg"`
 }
 
 // Load reads and validates the configuration at path.
@@ -114,6 +236,30 @@ func (c Config) Validate() error {
 	default:
 		return fmt.Errorf("storage.type must be \"local\" or \"r2\", got %q", c.Storage.Type)
 	}
+	if c.ClipQueue.Concurrency < 0 || c.ClipQueue.MaxPending < 0 {
+		return fmt.Errorf("clipQueue.concurrency and clipQueue.maxPending must not be negative")
+	}
+	for name, a := range map[string]AnimatedClipConfig{"gif": c.AnimatedClips.GIF, "webm": c.AnimatedClips.WebM} {
+		if a.MaxWidth < 0 || a.FPS < 0 {
+			return fmt.Errorf("animatedClips.%s.maxWidth and animatedClips.%s.fps must not be negative", name, name)
+		}
+	}
+	f := c.FFmpeg
+	for _, v := range []int{f.ConvertSeconds, f.RemuxSeconds, f.TrimSeconds, f.ExtractFrameSeconds, f.CutSeconds, f.ProbeSeconds, f.SubtitlesSeconds, f.AnimateSeconds, f.PeaksSeconds, f.VodSeconds} {
+		if v < 0 {
+			return fmt.Errorf("ffmpeg timeouts must not be negative")
+		}
+	}
+	for name, p := range c.EncodingProfiles {
+		switch p.Codec {
+		case "aac", "opus":
+		default:
+			return fmt.Errorf("encodingProfiles.%s.codec must be \"aac\" or \"opus\", got %q", name, p.Codec)
+		}
+		if p.BitrateKbps < 0 || p.SampleRate < 0 || p.Channels < 0 {
+			return fmt.Errorf("encodingProfiles.%s: bitrateKbps, sampleRate and channels must not be negative", name)
+		}
+	}
 	seen := make(map[string]bool, len(c.Channels))
 	for _, ch := range c.Channels {
 		if ch.Name == "" {
@@ -123,6 +269,30 @@ func (c Config) Validate() error {
 			return fmt.Errorf("duplicate channel name %q", ch.Name)
 		}
 		seen[ch.Name] = true
+		for _, kw := range ch.Watchlist.Keywords {
+			if strings.TrimSpace(kw) == "" {
+				return fmt.Errorf("channel %q: watchlist.keywords must not contain empty entries", ch.Name)
+			}
+		}
+		for _, p := range ch.Watchlist.Patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("channel %q: watchlist.patterns: %w", ch.Name, err)
+			}
+		}
+		if ch.Watchlist.CooldownSeconds < 0 {
+			return fmt.Errorf("channel %q: watchlist.cooldownSeconds must not be negative", ch.Name)
+		}
+		if ch.AutoVod.DelaySeconds < 0 {
+			return fmt.Errorf("channel %q: autoVod.delaySeconds must not be negative", ch.Name)
+		}
+		if ch.ChunkSeconds < 0 {
+			return fmt.Errorf("channel %q: chunkSeconds must not be negative", ch.Name)
+		}
+		for field, profile := range map[string]string{"audio": ch.Encoding.Audio, "clips": ch.Encoding.Clips, "vods": ch.Encoding.Vods} {
+			if _, ok := c.EncodingProfiles[profile]; profile != "" && !ok {
+				return fmt.Errorf("channel %q: encoding.%s names unknown encoding profile %q", ch.Name, field, profile)
+			}
+		}
 	}
 	return nil
 }
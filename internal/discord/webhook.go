@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -165,16 +166,7 @@ func (d *Client) NotifyStreamStart(channelKey, streamID, streamTitle, startTime
 		imageUrl = fmt.Sprintf("https://i.ytimg.com/vi/%s/maxresdefault.jpg", streamID)
 	}
 
-	var transcriptLink string
-	if d.transcriptBaseURL != "" {
-		transcriptLink = d.transcriptBaseURL + "/" + channelKey + "/"
-	} else {
-		domain := "www.duck-automata.com"
-		if d.Version == "dev" {
-			domain = "dev.duck-automata.com"
-		}
-		transcriptLink = fmt.Sprintf("https://%s/live-transcript/%s/", domain, channelKey)
-	}
+	transcriptLink := d.transcriptURL(channelKey)
 
 	embed := map[string]any{
 		"title":       fmt.Sprintf("%s's Stream Started", fullName),
@@ -196,6 +188,57 @@ func (d *Client) NotifyStreamStart(channelKey, streamID, streamTitle, startTime
 	go d.send(payload)
 }
 
+// transcriptURL is the link to a channel's transcript page: under
+// transcriptBaseURL when configured, else on the production (or, for dev
+// builds, dev) site.
+func (d *Client) transcriptURL(channelKey string) string {
+	if d.transcriptBaseURL != "" {
+		return d.transcriptBaseURL + "/" + channelKey + "/"
+	}
+	domain := "www.duck-automata.com"
+	if d.Version == "dev" {
+		domain = "dev.duck-automata.com"
+	}
+	return fmt.Sprintf("https://%s/live-transcript/%s/", domain, channelKey)
+}
+
+// NotifyKeywordHit alerts that a watchlist keyword was spoken. matched is the
+// text that matched keyword, text the whole line (truncated to fit the embed)
+// and timestamp the line's Unix time. The link opens the transcript at the
+// line.
+func (d *Client) NotifyKeywordHit(channelKey, streamID string, lineID, timestamp int, keyword, matched, text string) {
+	if d.WebhookURL == "" {
+		return
+	}
+
+	fullName := channelKey
+	if p, ok := d.channels[channelKey]; ok {
+		fullName = p.displayName
+	}
+	link := fmt.Sprintf("%s?streamId=%s&lineId=%d", d.transcriptURL(channelKey), url.QueryEscape(streamID), lineID)
+
+	payload := map[string]any{
+		"embeds": []map[string]any{
+			{
+				"title":       fmt.Sprintf("Keyword Hit: %s", truncate(matched, 200)),
+				"description": fmt.Sprintf("**%s** said:\n> %s\n\n<t:%d:T> | [Transcript](%s)", fullName, truncate(text, 1500), timestamp, link),
+				"url":         link,
+				"color":       16744448, // Orange
+				"fields": []map[string]any{
+					{"name": "Keyword", "value": truncate(keyword, 1024), "inline": true},
+					{"name": "Stream ID", "value": streamID, "inline": true},
+					{"name": "Line ID", "value": strconv.Itoa(lineID), "inline": true},
+				},
+				"timestamp": time.Unix(int64(timestamp), 0).Format(time.RFC3339),
+				"footer": map[string]string{
+					"text": fmt.Sprintf("Version: %s", d.Version),
+				},
+			},
+		},
+	}
+	go d.send(payload)
+}
+
 // NotifyWorkerOffline alerts that a channel's worker has stopped reporting.
 func (d *Client) NotifyWorkerOffline(channelKey string, lastSeen int64) {
 	if d.WebhookURL == "" {
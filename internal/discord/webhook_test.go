@@ -165,6 +165,33 @@ func TestNotifyStreamStart_TranscriptBaseURLOverride(t *testing.T) {
 	}
 }
 
+func TestNotifyKeywordHit_LinksToLine(t *testing.T) {
+	cfg := config.DiscordConfig{TranscriptBaseURL: "https://example.com/lt"}
+	channels := []config.ChannelConfig{{Name: "doki", DisplayName: "Doki"}}
+	c, payloads := newTestClient(t, cfg, "test", channels)
+
+	c.NotifyKeywordHit("doki", "abc 1", 42, 1700000000, "giveaway", "Giveaway", "big Giveaway tonight")
+	embed := embedFrom(t, waitPayload(t, payloads))
+
+	if got := embed["title"]; got != "Keyword Hit: Giveaway" {
+		t.Errorf("title=%v want the matched text", got)
+	}
+	wantLink := "https://example.com/lt/doki/?streamId=abc+1&lineId=42"
+	if got := embed["url"]; got != wantLink {
+		t.Errorf("url=%v want %s", got, wantLink)
+	}
+	desc, _ := embed["description"].(string)
+	for _, want := range []string{"**Doki**", "big Giveaway tonight", "<t:1700000000:T>", "[Transcript](" + wantLink + ")"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("description=%q missing %q", desc, want)
+		}
+	}
+	fields := fieldsFrom(t, embed)
+	if len(fields) == 0 || fields[0] != [2]string{"Keyword", "giveaway"} {
+		t.Errorf("fields=%v want the keyword first", fields)
+	}
+}
+
 func TestNotify500Error_Throttled(t *testing.T) {
 	c, payloads := newTestClient(t, config.DiscordConfig{}, "test", nil)
 
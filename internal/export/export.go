@@ -0,0 +1,185 @@
+// Package export renders stored transcripts into the subtitle and text
+// formats editors load into other tools (SRT, WebVTT, plain text). It depends
+// only on model, so both the transcript download endpoint and media code that
+// needs subtitle files can use it.
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"live-transcript-server/internal/model"
+)
+
+// Cue timing bounds. A segment is shown until the next one starts, but never
+// for longer than maxCueDuration, so a long silence does not leave the last
+// words on screen. The final segment of a transcript has no successor and is
+// shown for lastCueDuration. minCueDuration covers segments whose successor
+// starts at the same time (or earlier, if timestamps went backwards).
+const (
+	maxCueDuration  = 10 * time.Second
+	lastCueDuration = 5 * time.Second
+	minCueDuration  = time.Second
+)
+
+// Cue is one timed piece of subtitle text. Start and End are offsets from the
+// transcript's origin (see Origin).
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// segment mirrors the worker's segment JSON. Timestamp is a pointer so a
+// segment that omits it can fall back to its line's timestamp.
+type segment struct {
+	Timestamp *float64 `json:"timestamp"`
+	Text      string   `json:"text"`
+}
+
+// Origin picks the instant transcript timestamps are measured from. Workers
+// send unix-second timestamps, and subtitles want offsets into the stream, so
+// the stream's start time is the origin. Timestamps that are already smaller
+// than the start time cannot be absolute, so they are treated as offsets and
+// the origin is 0. startTime is the stream's stored start time (unix seconds
+// as a string); anything unparseable also yields 0.
+func Origin(startTime string, lines []model.Line) int64 {
+	start, err := strconv.ParseInt(startTime, 10, 64)
+	if err != nil || start <= 0 || len(lines) == 0 {
+		return 0
+	}
+	if int64(lines[0].Timestamp) < start {
+		return 0
+	}
+	return start
+}
+
+// offset converts a transcript timestamp (seconds) into an offset from
+// origin, clamped at zero.
+func offset(ts float64, origin int64) time.Duration {
+	d := time.Duration((ts - float64(origin)) * float64(time.Second))
+	return max(d, 0)
+}
+
+// lineSegments decodes a line's segments, falling back to the line's own
+// timestamp for segments without one. Segments that are not valid JSON yield
+// nothing: the line is skipped rather than failing the whole export.
+func lineSegments(line model.Line) []segment {
+	var segs []segment
+	if err := json.Unmarshal(line.Segments, &segs); err != nil {
+		return nil
+	}
+	for i := range segs {
+		if segs[i].Timestamp == nil {
+			ts := float64(line.Timestamp)
+			segs[i].Timestamp = &ts
+		}
+		segs[i].Text = strings.Join(strings.Fields(segs[i].Text), " ")
+	}
+	return segs
+}
+
+// Cues flattens lines into one cue per non-empty segment, in transcript
+// order, with each cue lasting until the next begins (within the bounds
+// above).
+func Cues(lines []model.Line, origin int64) []Cue {
+	var cues []Cue
+	for _, line := range lines {
+		for _, seg := range lineSegments(line) {
+			if seg.Text == "" {
+				continue
+			}
+			cues = append(cues, Cue{Start: offset(*seg.Timestamp, origin), Text: seg.Text})
+		}
+	}
+
+	for i := range cues {
+		end := cues[i].Start + lastCueDuration
+		if i+1 < len(cues) {
+			end = min(cues[i+1].Start, cues[i].Start+maxCueDuration)
+		}
+		cues[i].End = max(end, cues[i].Start+minCueDuration)
+	}
+	return cues
+}
+
+// Window returns the cues that overlap [from, to), re-timed so that from is
+// zero and clamped to the window. It turns a transcript's cues into a clip's:
+// from and to are the clip's bounds as offsets from the same origin.
+func Window(cues []Cue, from, to time.Duration) []Cue {
+	var out []Cue
+	for _, c := range cues {
+		if c.End <= from || c.Start >= to {
+			continue
+		}
+		out = append(out, Cue{
+			Start: max(c.Start, from) - from,
+			End:   min(c.End, to) - from,
+			Text:  c.Text,
+		})
+	}
+	return out
+}
+
+// formatTimestamp renders d as HH:MM:SS followed by sep and milliseconds, the
+// shape shared by SRT (",") and WebVTT (".").
+func formatTimestamp(d time.Duration, sep string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, sep, ms%1000)
+}
+
+// WriteSRT writes cues as a SubRip file.
+func WriteSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(c.Start, ","), formatTimestamp(c.End, ","), c.Text)
+	}
+	return bw.Flush()
+}
+
+// vttEscaper escapes the characters WebVTT cue text may not contain literally.
+// Escaping ">" also breaks up any "-->", which would otherwise be read as a
+// timing line.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// WriteVTT writes cues as a WebVTT file.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		fmt.Fprintf(bw, "%s --> %s\n%s\n\n", formatTimestamp(c.Start, "."), formatTimestamp(c.End, "."), vttEscaper.Replace(c.Text))
+	}
+	return bw.Flush()
+}
+
+// WriteText writes one line of text per transcript line, prefixed with the
+// line's [HH:MM:SS] offset from origin. Lines with no text are left out.
+func WriteText(w io.Writer, lines []model.Line, origin int64) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range lines {
+		text := LineText(line)
+		if text == "" {
+			continue
+		}
+		secs := int64(offset(float64(line.Timestamp), origin).Seconds())
+		fmt.Fprintf(bw, "[%02d:%02d:%02d] %s\n", secs/3600, secs/60%60, secs%60, text)
+	}
+	return bw.Flush()
+}
+
+// LineText joins the text of a line's non-empty segments with spaces. It is
+// empty for a line with no text or with segments that are not valid JSON.
+func LineText(line model.Line) string {
+	var texts []string
+	for _, seg := range lineSegments(line) {
+		if seg.Text != "" {
+			texts = append(texts, seg.Text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
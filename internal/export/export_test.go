@@ -0,0 +1,125 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"live-transcript-server/internal/model"
+)
+
+func testLines() []model.Line {
+	return []model.Line{
+		{ID: 0, Timestamp: 1000, Segments: json.RawMessage(`[{"timestamp": 1000, "text": " Hello  world "}, {"timestamp": 1003.5, "text": "second"}]`)},
+		{ID: 1, Timestamp: 1030, Segments: json.RawMessage(`[{"text": "a < b & c --> d"}]`)},
+		{ID: 2, Timestamp: 1040, Segments: json.RawMessage(`not json`)},
+		{ID: 3, Timestamp: 3700, Segments: json.RawMessage(`[{"timestamp": 3700, "text": ""}]`)},
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	lines := testLines()
+	tests := []struct {
+		start string
+		want  int64
+	}{
+		{"990", 990},
+		{"1000", 1000},
+		{"2000", 0}, // timestamps before the start are already offsets
+		{"", 0},
+		{"abc", 0},
+	}
+	for _, tt := range tests {
+		if got := Origin(tt.start, lines); got != tt.want {
+			t.Errorf("Origin(%q) = %d, want %d", tt.start, got, tt.want)
+		}
+	}
+	if got := Origin("990", nil); got != 0 {
+		t.Errorf("Origin with no lines = %d, want 0", got)
+	}
+}
+
+func TestCues(t *testing.T) {
+	cues := Cues(testLines(), 1000)
+	want := []Cue{
+		{Start: 0, End: 3500 * time.Millisecond, Text: "Hello world"},
+		{Start: 3500 * time.Millisecond, End: 13500 * time.Millisecond, Text: "second"},
+		{Start: 30 * time.Second, End: 35 * time.Second, Text: "a < b & c --> d"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("got %d cues, want %d: %+v", len(cues), len(want), cues)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+}
+
+func TestCuesNonMonotonic(t *testing.T) {
+	lines := []model.Line{
+		{ID: 0, Timestamp: 10, Segments: json.RawMessage(`[{"timestamp": 10, "text": "a"}, {"timestamp": 10, "text": "b"}]`)},
+	}
+	cues := Cues(lines, 0)
+	if cues[0].End != cues[0].Start+minCueDuration {
+		t.Errorf("expected zero-length cue to get the minimum duration, got %+v", cues[0])
+	}
+}
+
+func TestWindow(t *testing.T) {
+	cues := Window(Cues(testLines(), 1000), 2*time.Second, 31*time.Second)
+	want := []Cue{
+		{Start: 0, End: 1500 * time.Millisecond, Text: "Hello world"},
+		{Start: 1500 * time.Millisecond, End: 11500 * time.Millisecond, Text: "second"},
+		{Start: 28 * time.Second, End: 29 * time.Second, Text: "a < b & c --> d"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("got %d cues, want %d: %+v", len(cues), len(want), cues)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+	if cues := Window(Cues(testLines(), 1000), 14*time.Second, 30*time.Second); len(cues) != 0 {
+		t.Errorf("expected no cues between segments, got %+v", cues)
+	}
+}
+
+func TestWriteSRT(t *testing.T) {
+	var b strings.Builder
+	cues := []Cue{
+		{Start: 0, End: 1500 * time.Millisecond, Text: "one"},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "two"},
+	}
+	if err := WriteSRT(&b, cues); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:00,000 --> 00:00:01,500\none\n\n2\n01:02:03,004 --> 01:02:05,000\ntwo\n\n"
+	if b.String() != want {
+		t.Errorf("WriteSRT:\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	var b strings.Builder
+	if err := WriteVTT(&b, []Cue{{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "a < b & c --> d"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n00:00:01.500 --> 00:00:02.000\na &lt; b &amp; c --&gt; d\n\n"
+	if b.String() != want {
+		t.Errorf("WriteVTT:\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var b strings.Builder
+	if err := WriteText(&b, testLines(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	want := "[00:00:00] Hello world second\n[00:00:30] a < b & c --> d\n"
+	if b.String() != want {
+		t.Errorf("WriteText:\n%q\nwant\n%q", b.String(), want)
+	}
+}
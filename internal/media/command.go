@@ -0,0 +1,66 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// stderrTailBytes is how much of a failed command's stderr its error keeps.
+// ffmpeg prints its banner and stream layout first; the reason it failed is
+// at the end.
+const stderrTailBytes = 4 << 10
+
+// waitDelay bounds how long a killed command may hold its output pipes open
+// (through a child it forked) before Wait gives up on them.
+const waitDelay = 5 * time.Second
+
+// run runs name with args and returns its stdout. The command is killed,
+// along with any processes it started, when ctx is done; if ctx has no
+// deadline of its own, timeout (when positive) sets one. On failure the error
+// names what was being done, wraps ctx's error when that is why the command
+// stopped, and carries the tail of the command's stderr.
+func run(ctx context.Context, timeout time.Duration, what, name string, args ...string) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+
+	var stdout bytes.Buffer
+	stderr := &tailWriter{max: stderrTailBytes}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = fmt.Errorf("%w (%v)", ctxErr, err)
+		}
+		return nil, fmt.Errorf("%s failed: %w, output: %s", what, err, stderr.buf)
+	}
+	return stdout.Bytes(), nil
+}
+
+// tailWriter keeps the last max bytes written to it.
+type tailWriter struct {
+	max int
+	buf []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= w.max {
+		p = p[len(p)-w.max:]
+		w.buf = w.buf[:0]
+	} else if over := len(w.buf) + len(p) - w.max; over > 0 {
+		w.buf = w.buf[over:]
+	}
+	w.buf = append(w.buf, p...)
+	return n, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package media
+
+import "os/exec"
+
+// killProcessGroup leaves cmd as is: without process groups, cancelling it
+// kills only the process itself.
+func killProcessGroup(cmd *exec.Cmd) {}
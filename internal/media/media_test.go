@@ -1,11 +1,15 @@
 package media
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -201,3 +205,212 @@ func TestMergeRawAudioCanceledContext(t *testing.T) {
 		t.Errorf("orphaned merged file left behind at %s (stat err: %v)", mergedPath, statErr)
 	}
 }
+
+// durationProcessor is a Processor whose only working method is Duration,
+// which reports each chunk's length as its size in bytes.
+type durationProcessor struct{ Processor }
+
+func (durationProcessor) Duration(ctx context.Context, inputPath string) (float64, error) {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return 0, err
+	}
+	return float64(info.Size()), nil
+}
+
+func TestMergeRawAudioTimed(t *testing.T) {
+	st, tmpDir := newTestStorage(t)
+
+	channelKey := "testchannel"
+	streamID := "stream1"
+
+	saveRaw(t, st, channelKey, streamID, "file1", "Part1")
+	saveRaw(t, st, channelKey, streamID, "file2", "Part22")
+
+	mergedPath, durations, err := MergeRawAudioTimed(context.TODO(), st, durationProcessor{}, tmpDir, channelKey, streamID, []string{"file1", "file2"}, "timed")
+	if err != nil {
+		t.Fatalf("MergeRawAudioTimed failed: %v", err)
+	}
+	if content, _ := os.ReadFile(mergedPath); string(content) != "Part1Part22" {
+		t.Errorf("expected Part1Part22, got %s", content)
+	}
+	if len(durations) != 2 || durations[0] != 5 || durations[1] != 6 {
+		t.Errorf("expected durations [5 6], got %v", durations)
+	}
+}
+
+func TestCutPosition(t *testing.T) {
+	// Chunks whose line timestamps (whole seconds) drift from their real
+	// lengths: the second starts at 10 but the first only lasts 9.5.
+	offsets := []float64{0, 10, 20}
+	durations := []float64{9.5, 10.25, 8}
+
+	tests := []struct {
+		t    float64
+		want float64
+	}{
+		{-5, 0},         // before the first chunk
+		{2.5, 2.5},      // inside the first chunk
+		{9.75, 9.5},     // in the gap after the first chunk
+		{12, 9.5 + 2},   // placed relative to its own chunk
+		{20, 19.75},     // the start of the last chunk
+		{27, 19.75 + 7}, // inside the last chunk
+		{40, 27.75},     // past the end
+	}
+	for _, tt := range tests {
+		if got := CutPosition(offsets, durations, tt.t); got != tt.want {
+			t.Errorf("CutPosition(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestPeaksFromPCM(t *testing.T) {
+	// 2.5 windows at 40Hz (4 samples a window): silence, a loud window
+	// whose loudest sample is negative, then half a quiet window.
+	samples := []int16{0, 0, 0, 0, 100, -32768, 16384, 0, 1, -1}
+	var pcm bytes.Buffer
+	for _, s := range samples {
+		binary.Write(&pcm, binary.LittleEndian, s)
+	}
+	pcm.WriteByte(0) // a truncated trailing sample is ignored
+
+	got, err := PeaksFromPCM(&pcm, 40)
+	if err != nil {
+		t.Fatalf("PeaksFromPCM: %v", err)
+	}
+	if want := []int{0, 100, 1}; !slices.Equal(got, want) {
+		t.Errorf("PeaksFromPCM = %v, want %v", got, want)
+	}
+
+	if got, err := PeaksFromPCM(bytes.NewReader(nil), 40); err != nil || len(got) != 0 {
+		t.Errorf("PeaksFromPCM(empty) = %v, %v; want no peaks", got, err)
+	}
+}
+
+func TestAudioProfile(t *testing.T) {
+	opus := AudioProfile{Codec: CodecOpus, BitrateKbps: 24, SampleRate: 48000, Channels: 1}
+	if got := opus.Ext(); got != ".opus" {
+		t.Errorf("opus Ext = %q, want .opus", got)
+	}
+	if got, want := opus.args(), []string{"-c:a", "libopus", "-b:a", "24k", "-ar", "48000", "-ac", "1"}; !slices.Equal(got, want) {
+		t.Errorf("opus args = %v, want %v", got, want)
+	}
+
+	// A profile applies only to its own format.
+	if got := opus.For(".opus"); got != opus {
+		t.Errorf("For(.opus) = %+v, want the profile", got)
+	}
+	if got := opus.For(".mp3"); got != (AudioProfile{}) {
+		t.Errorf("For(.mp3) = %+v, want the zero profile", got)
+	}
+
+	// The zero profile is ffmpeg's defaults in an m4a.
+	var zero AudioProfile
+	if got := zero.Ext(); got != ".m4a" {
+		t.Errorf("zero Ext = %q, want .m4a", got)
+	}
+	if got := zero.args(); len(got) != 0 {
+		t.Errorf("zero args = %v, want none", got)
+	}
+	if got, want := (AudioProfile{Codec: CodecAAC, BitrateKbps: 96}).args(), []string{"-c:a", "aac", "-b:a", "96k"}; !slices.Equal(got, want) {
+		t.Errorf("aac args = %v, want %v", got, want)
+	}
+	if got, want := zero.copyArgs(), []string{"-c", "copy"}; !slices.Equal(got, want) {
+		t.Errorf("zero copyArgs = %v, want %v", got, want)
+	}
+
+	// Normalisation is part of the encode, applies to every format, and sets
+	// the sample rate loudnorm would otherwise raise.
+	normalized := AudioProfile{Normalize: true}
+	if got, want := normalized.args(), []string{"-af", LoudnessTarget, "-ar", "48000"}; !slices.Equal(got, want) {
+		t.Errorf("normalized args = %v, want %v", got, want)
+	}
+	if got, want := normalized.copyArgs(), []string{"-c:v", "copy", "-af", LoudnessTarget, "-ar", "48000"}; !slices.Equal(got, want) {
+		t.Errorf("normalized copyArgs = %v, want %v", got, want)
+	}
+	opus.Normalize = true
+	if got := opus.For(".mp3"); got != normalized {
+		t.Errorf("normalized For(.mp3) = %+v, want only normalisation", got)
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	video := `{
+		"streams": [
+			{"codec_name": "h264", "codec_type": "video", "width": 1920, "height": 1080},
+			{"codec_name": "aac", "codec_type": "audio"}
+		],
+		"format": {"duration": "10.026667"}
+	}`
+	got, err := parseProbe([]byte(video))
+	if err != nil {
+		t.Fatalf("parseProbe(video): %v", err)
+	}
+	if want := (ProbeInfo{Duration: 10.026667, Codec: "aac", Width: 1920, Height: 1080}); got != want {
+		t.Errorf("parseProbe(video) = %+v, want %+v", got, want)
+	}
+
+	// Audio-only media has no resolution, and an untimed one no duration.
+	got, err = parseProbe([]byte(`{"streams": [{"codec_name": "opus", "codec_type": "audio"}], "format": {"duration": "N/A"}}`))
+	if err != nil {
+		t.Fatalf("parseProbe(audio): %v", err)
+	}
+	if want := (ProbeInfo{Codec: "opus"}); got != want {
+		t.Errorf("parseProbe(audio) = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseProbe([]byte("not json")); err == nil {
+		t.Error("parseProbe(garbage) succeeded, want an error")
+	}
+}
+
+func TestTailWriter(t *testing.T) {
+	w := &tailWriter{max: 8}
+	w.Write([]byte("hello "))
+	w.Write([]byte("world"))
+	if got := string(w.buf); got != "lo world" {
+		t.Errorf("after two writes: tail %q, want %q", got, "lo world")
+	}
+	if n, _ := w.Write([]byte("0123456789")); n != 10 {
+		t.Errorf("Write reported %d bytes, want 10", n)
+	}
+	if got := string(w.buf); got != "23456789" {
+		t.Errorf("after an oversized write: tail %q, want %q", got, "23456789")
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh on PATH")
+	}
+	ctx := context.Background()
+
+	out, err := run(ctx, time.Second, "echo", "sh", "-c", "echo out; echo noise >&2")
+	if err != nil || string(out) != "out\n" {
+		t.Errorf("run = %q, %v; want stdout only", out, err)
+	}
+
+	// A failure carries the end of stderr.
+	_, err = run(ctx, time.Second, "failing step", "sh", "-c", "echo why it failed >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "failing step failed") || !strings.Contains(err.Error(), "why it failed") {
+		t.Errorf("run error = %v, want the step and its stderr", err)
+	}
+
+	// A hung command is killed at the timeout, along with the children it
+	// started, rather than waited for.
+	started := time.Now()
+	_, err = run(ctx, 100*time.Millisecond, "hang", "sh", "-c", "sleep 30 & sleep 30")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run error = %v, want a deadline exceeded", err)
+	}
+	if took := time.Since(started); took > 10*time.Second {
+		t.Errorf("hung command took %s to stop", took)
+	}
+
+	// The caller's own deadline wins over the timeout.
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := run(short, time.Hour, "hang", "sh", "-c", "sleep 30"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run with a deadline: error = %v, want a deadline exceeded", err)
+	}
+}
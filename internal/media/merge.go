@@ -23,6 +23,32 @@ const mergeConcurrency = 32
 // chunk of the stream at once. Returns the path to the merged file (which
 // lives in tempDir; the caller owns its cleanup).
 func MergeRawAudio(ctx context.Context, st storage.Storage, tempDir, channelKey, streamID string, fileIDs []string, outputName string) (string, error) {
+	return mergeRaw(ctx, st, tempDir, channelKey, streamID, fileIDs, outputName, nil)
+}
+
+// MergeRawAudioTimed is MergeRawAudio that also reports each chunk's duration
+// in seconds, in fileIDs order. The durations are probed from the downloaded
+// chunks as they are appended, so timing a merge costs no extra downloads.
+func MergeRawAudioTimed(ctx context.Context, st storage.Storage, proc Processor, tempDir, channelKey, streamID string, fileIDs []string, outputName string) (string, []float64, error) {
+	durations := make([]float64, 0, len(fileIDs))
+	path, err := mergeRaw(ctx, st, tempDir, channelKey, streamID, fileIDs, outputName, func(chunkPath string) error {
+		d, err := proc.Duration(ctx, chunkPath)
+		if err != nil {
+			return err
+		}
+		durations = append(durations, d)
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return path, durations, nil
+}
+
+// mergeRaw does the work of MergeRawAudio. onChunk, when non-nil, sees each
+// downloaded chunk just before it is appended; an error from it fails the
+// merge.
+func mergeRaw(ctx context.Context, st storage.Storage, tempDir, channelKey, streamID string, fileIDs []string, outputName string, onChunk func(chunkPath string) error) (string, error) {
 	if len(fileIDs) == 0 {
 		return "", fmt.Errorf("no files to merge")
 	}
@@ -118,6 +144,11 @@ func MergeRawAudio(ctx context.Context, st storage.Storage, tempDir, channelKey,
 		if res.err != nil {
 			return abort(res.err)
 		}
+		if onChunk != nil {
+			if err := onChunk(res.path); err != nil {
+				return abort(fmt.Errorf("failed to inspect chunk %s: %w", res.path, err))
+			}
+		}
 
 		f, err := os.Open(res.path)
 		if err != nil {
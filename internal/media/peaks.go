@@ -0,0 +1,53 @@
+package media
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// PeaksPerSecond is how many peaks Peaks computes for each second of audio.
+// Ten is enough to see where speech starts and stops while keeping a chunk's
+// peaks to a few hundred bytes.
+const PeaksPerSecond = 10
+
+// peaksSampleRate is the rate audio is decoded at for peak detection. Peaks
+// only need the envelope, so a low rate keeps the decoded PCM small.
+const peaksSampleRate = 8000
+
+// PeaksFromPCM reads mono signed 16-bit little-endian PCM at sampleRate and
+// returns the loudest sample of every 1/PeaksPerSecond of it, scaled to
+// 0-100 of full scale. A trailing partial window gets a peak of its own.
+func PeaksFromPCM(r io.Reader, sampleRate int) ([]int, error) {
+	window := max(sampleRate/PeaksPerSecond, 1)
+	br := bufio.NewReader(r)
+
+	var peaks []int
+	loudest, n := 0, 0
+	var sample [2]byte
+	for {
+		if _, err := io.ReadFull(br, sample[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		v := int(int16(binary.LittleEndian.Uint16(sample[:])))
+		loudest = max(loudest, v, -v)
+		if n++; n == window {
+			peaks = append(peaks, scalePeak(loudest))
+			loudest, n = 0, 0
+		}
+	}
+	if n > 0 {
+		peaks = append(peaks, scalePeak(loudest))
+	}
+	return peaks, nil
+}
+
+// scalePeak maps an absolute 16-bit sample to 0-100, rounding up so that
+// any sound at all shows.
+func scalePeak(v int) int {
+	return min((v*100+32767)/32768, 100)
+}
@@ -0,0 +1,56 @@
+package media
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ProbeInfo is what Probe finds in a media file. Codec is the first audio
+// stream's codec (e.g. "aac"); Width and Height are the first video
+// stream's, zero for audio-only media.
+type ProbeInfo struct {
+	Duration float64
+	Codec    string
+	Width    int
+	Height   int
+}
+
+// parseProbe reads ffprobe's JSON output (-of json) for the streams' codec
+// type, codec name and dimensions and the format's duration.
+func parseProbe(output []byte) (ProbeInfo, error) {
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return ProbeInfo{}, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+
+	var info ProbeInfo
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "audio":
+			if info.Codec == "" {
+				info.Codec = s.CodecName
+			}
+		case "video":
+			if info.Width == 0 && info.Height == 0 {
+				info.Width, info.Height = s.Width, s.Height
+			}
+		}
+	}
+	// ffprobe omits the duration, or reports "N/A", for media it cannot
+	// time; that leaves it zero rather than failing the whole probe.
+	if d, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = d
+	}
+	return info, nil
+}
@@ -2,109 +2,334 @@
 package media
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Processor is the ffmpeg seam. It replaces the old mutable package-level
 // Ffmpeg* function vars: tests substitute a fake Processor instead of
-// swapping globals.
+// swapping globals. Every operation stops, killing ffmpeg, when its ctx is
+// done.
 type Processor interface {
 	// Convert transcodes inputPath into outputPath, dropping any video
-	// stream.
-	Convert(inputPath, outputPath string) error
+	// stream. The audio is encoded per profile.
+	Convert(ctx context.Context, inputPath, outputPath string, profile AudioProfile) error
 
 	// Remux rewrites inputPath's container into outputPath without
-	// re-encoding.
-	Remux(inputPath, outputPath string) error
+	// re-encoding. With any profile but the zero one the audio is
+	// re-encoded per profile instead (say, to normalise it); the video is
+	// always copied.
+	Remux(ctx context.Context, inputPath, outputPath string, profile AudioProfile) error
 
 	// Trim copies the [start, end) span of inputPath (seconds) into
-	// outputPath without re-encoding.
-	Trim(inputPath, outputPath string, start, end float64) error
+	// outputPath without re-encoding, except for the audio of a non-zero
+	// profile, as with Remux.
+	Trim(ctx context.Context, inputPath, outputPath string, start, end float64, profile AudioProfile) error
 
 	// ExtractFrame writes a single frame of inputPath into outputPath,
 	// scaled to the given height with aspect ratio preserved.
-	ExtractFrame(inputPath, outputPath string, height int) error
+	ExtractFrame(ctx context.Context, inputPath, outputPath string, height int) error
+
+	// Cut encodes the [start, end) span of inputPath (seconds) into
+	// outputPath, re-encoding so the cut lands exactly on those points
+	// rather than on the nearest keyframe. keepVideo keeps (and re-encodes)
+	// the video stream, with AAC audio normalised if profile.Normalize;
+	// otherwise the video is dropped, as with Convert, and the audio is
+	// encoded per profile.
+	Cut(ctx context.Context, inputPath, outputPath string, start, end float64, keepVideo bool, profile AudioProfile) error
+
+	// Duration reports inputPath's duration in seconds, as probed by
+	// ffprobe.
+	Duration(ctx context.Context, inputPath string) (float64, error)
+
+	// Probe reports what ffprobe finds in inputPath: its duration, audio
+	// codec and, when it has a video stream, resolution.
+	Probe(ctx context.Context, inputPath string) (ProbeInfo, error)
+
+	// BurnSubtitles renders the subtitle file subtitlePath onto
+	// inputPath's video, re-encoding it into outputPath. The audio is
+	// copied as is.
+	BurnSubtitles(ctx context.Context, inputPath, subtitlePath, outputPath string) error
+
+	// EmbedSubtitles adds the subtitle file subtitlePath to inputPath as a
+	// mov_text subtitle track, copying the other streams into outputPath
+	// without re-encoding.
+	EmbedSubtitles(ctx context.Context, inputPath, subtitlePath, outputPath string) error
+
+	// Animate renders inputPath's video into an animated outputPath, whose
+	// extension picks the format: ".gif" (silent, palette-optimised) or
+	// ".webm" (VP9 with Opus audio). The video is scaled down to at most
+	// maxWidth pixels wide, aspect ratio preserved, at fps frames per
+	// second.
+	Animate(ctx context.Context, inputPath, outputPath string, maxWidth, fps int) error
+
+	// Peaks decodes inputPath's audio and returns its loudness envelope:
+	// PeaksPerSecond values a second, each 0-100 (see PeaksFromPCM).
+	Peaks(ctx context.Context, inputPath string) ([]int, error)
 }
 
-// FFmpeg implements Processor by shelling out to the ffmpeg binary on PATH.
-// exec.Command fails naturally where ffmpeg is absent.
-type FFmpeg struct{}
+// LoudnessTarget is the ffmpeg loudnorm filter a profile with Normalize
+// applies: the EBU R128 target of -16 LUFS integrated with -1.5 dBTP peaks,
+// the usual target for speech played back on the web.
+const LoudnessTarget = "loudnorm=I=-16:TP=-1.5:LRA=11"
 
-var _ Processor = FFmpeg{}
+// Timeouts bounds how long each FFmpeg operation may run when the caller's
+// context has no deadline of its own. A zero field means the operation's
+// default from DefaultTimeouts.
+type Timeouts struct {
+	Convert      time.Duration
+	Remux        time.Duration
+	Trim         time.Duration
+	ExtractFrame time.Duration
+	Cut          time.Duration
+	Duration     time.Duration // Duration and Probe
+	Subtitles    time.Duration // BurnSubtitles and EmbedSubtitles
+	Animate      time.Duration
+	Peaks        time.Duration
+}
 
-func (FFmpeg) Remux(inputPath, outputPath string) error {
-	cmd := exec.Command("ffmpeg",
-		"-i", inputPath,
-		"-c", "copy",
-		"-movflags", "+faststart",
-		"-y",
-		outputPath)
+// DefaultTimeouts are generous for a clip's worth of media: a hung ffmpeg is
+// killed, a slow one is not. Work on a whole stream, like a full VOD build,
+// should pass a context with its own deadline instead.
+var DefaultTimeouts = Timeouts{
+	Convert:      2 * time.Minute,
+	Remux:        2 * time.Minute,
+	Trim:         2 * time.Minute,
+	ExtractFrame: 30 * time.Second,
+	Cut:          5 * time.Minute,
+	Duration:     30 * time.Second,
+	Subtitles:    5 * time.Minute,
+	Animate:      5 * time.Minute,
+	Peaks:        time.Minute,
+}
 
-	output, err := cmd.CombinedOutput() // Capture both stdout and stderr
-	if err != nil {
-		return fmt.Errorf("ffmpeg conversion failed: %w, output: %s", err, string(output))
+// withDefaults fills t's zero fields from DefaultTimeouts.
+func (t Timeouts) withDefaults() Timeouts {
+	fill := func(v *time.Duration, def time.Duration) {
+		if *v <= 0 {
+			*v = def
+		}
 	}
+	d := DefaultTimeouts
+	fill(&t.Convert, d.Convert)
+	fill(&t.Remux, d.Remux)
+	fill(&t.Trim, d.Trim)
+	fill(&t.ExtractFrame, d.ExtractFrame)
+	fill(&t.Cut, d.Cut)
+	fill(&t.Duration, d.Duration)
+	fill(&t.Subtitles, d.Subtitles)
+	fill(&t.Animate, d.Animate)
+	fill(&t.Peaks, d.Peaks)
+	return t
+}
 
-	return nil
+// FFmpeg implements Processor by shelling out to the ffmpeg and ffprobe
+// binaries on PATH, which fail naturally where the binaries are absent. The
+// zero value uses DefaultTimeouts.
+type FFmpeg struct {
+	Timeouts Timeouts
 }
 
-func (FFmpeg) Convert(inputPath, outputPath string) error {
-	// -vn disables video recording, keeping only the audio channel
-	cmd := exec.Command("ffmpeg", "-i", inputPath, "-vn", "-movflags", "+faststart", "-y", outputPath)
+var _ Processor = FFmpeg{}
 
-	output, err := cmd.CombinedOutput() // Capture both stdout and stderr
-	if err != nil {
-		return fmt.Errorf("ffmpeg conversion failed: %w, output: %s", err, string(output))
-	}
+// ffmpeg runs ffmpeg with args, bounded by timeout (see run).
+func (f FFmpeg) ffmpeg(ctx context.Context, timeout time.Duration, what string, args ...string) error {
+	_, err := run(ctx, timeout, what, "ffmpeg", args...)
+	return err
+}
 
-	return nil
+func (f FFmpeg) Remux(ctx context.Context, inputPath, outputPath string, profile AudioProfile) error {
+	args := append([]string{"-i", inputPath}, profile.copyArgs()...)
+	args = append(args, "-movflags", "+faststart", "-y", outputPath)
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Remux, "ffmpeg conversion", args...)
 }
 
-func (FFmpeg) ExtractFrame(inputPath, outputPath string, height int) error {
+func (f FFmpeg) Convert(ctx context.Context, inputPath, outputPath string, profile AudioProfile) error {
+	// -vn disables video recording, keeping only the audio channel
+	args := append([]string{"-i", inputPath, "-vn"}, profile.args()...)
+	args = append(args, "-movflags", "+faststart", "-y", outputPath)
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Convert, "ffmpeg conversion", args...)
+}
+
+func (f FFmpeg) ExtractFrame(ctx context.Context, inputPath, outputPath string, height int) error {
 	// -vf "scale=-1:height" maintains aspect ratio while setting height
 	scaleFilter := fmt.Sprintf("scale=-1:%d", height)
 
-	cmd := exec.Command("ffmpeg",
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().ExtractFrame, "ffmpeg frame extraction",
 		"-i", inputPath,
 		"-vframes", "1",
 		"-vf", scaleFilter,
 		"-q:v", "5", // Standard quality for jpeg
 		"-y",
 		outputPath)
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("ffmpeg frame extraction failed: %w, output: %s", err, string(output))
+func (f FFmpeg) Trim(ctx context.Context, inputPath, outputPath string, start, end float64, profile AudioProfile) error {
+	duration := end - start
+	if duration <= 0 {
+		return fmt.Errorf("invalid duration: %f", duration)
 	}
 
-	return nil
+	args := []string{
+		"-ss", fmt.Sprintf("%f", start),
+		"-i", inputPath,
+		"-t", fmt.Sprintf("%f", duration),
+	}
+	args = append(args, profile.copyArgs()...)
+	args = append(args, "-avoid_negative_ts", "make_zero", "-movflags", "+faststart", "-y", outputPath)
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Trim, "ffmpeg trim", args...)
 }
 
-func (FFmpeg) Trim(inputPath, outputPath string, start, end float64) error {
+func (f FFmpeg) Cut(ctx context.Context, inputPath, outputPath string, start, end float64, keepVideo bool, profile AudioProfile) error {
 	duration := end - start
 	if duration <= 0 {
 		return fmt.Errorf("invalid duration: %f", duration)
 	}
 
-	cmd := exec.Command("ffmpeg",
-		"-ss", fmt.Sprintf("%f", start),
+	// -ss after -i decodes up to the cut instead of seeking to a keyframe,
+	// which is slower but exact. Clips are short, so that is affordable.
+	args := []string{
 		"-i", inputPath,
+		"-ss", fmt.Sprintf("%f", start),
 		"-t", fmt.Sprintf("%f", duration),
+	}
+	if keepVideo {
+		// The video is cut into an mp4, whose audio is always AAC.
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast")
+		args = append(args, AudioProfile{Codec: CodecAAC, Normalize: profile.Normalize}.args()...)
+	} else {
+		args = append(args, "-vn")
+		args = append(args, profile.args()...)
+	}
+	args = append(args, "-movflags", "+faststart", "-y", outputPath)
+
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Cut, "ffmpeg cut", args...)
+}
+
+func (f FFmpeg) Duration(ctx context.Context, inputPath string) (float64, error) {
+	output, err := run(ctx, f.Timeouts.withDefaults().Duration, "ffprobe", "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		inputPath)
+	if err != nil {
+		return 0, err
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe reported no duration for %s: %q", inputPath, output)
+	}
+	return d, nil
+}
+
+func (f FFmpeg) Probe(ctx context.Context, inputPath string) (ProbeInfo, error) {
+	output, err := run(ctx, f.Timeouts.withDefaults().Duration, "ffprobe", "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration:stream=codec_type,codec_name,width,height",
+		"-of", "json",
+		inputPath)
+	if err != nil {
+		return ProbeInfo{}, err
+	}
+	return parseProbe(output)
+}
+
+func (f FFmpeg) BurnSubtitles(ctx context.Context, inputPath, subtitlePath, outputPath string) error {
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Subtitles, "ffmpeg subtitle burn",
+		"-i", inputPath,
+		"-vf", "subtitles="+filterEscaper.Replace(subtitlePath),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		"-y",
+		outputPath)
+}
+
+func (f FFmpeg) EmbedSubtitles(ctx context.Context, inputPath, subtitlePath, outputPath string) error {
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Subtitles, "ffmpeg subtitle embed",
+		"-i", inputPath,
+		"-i", subtitlePath,
+		"-map", "0",
+		"-map", "1",
 		"-c", "copy",
-		"-avoid_negative_ts", "make_zero",
+		"-c:s", "mov_text",
 		"-movflags", "+faststart",
 		"-y",
 		outputPath)
+}
 
-	output, err := cmd.CombinedOutput()
+func (f FFmpeg) Animate(ctx context.Context, inputPath, outputPath string, maxWidth, fps int) error {
+	// min(maxWidth,iw) never upscales; -2 keeps the height even, which the
+	// encoders require.
+	scale := fmt.Sprintf("fps=%d,scale='min(%d,iw)':-2:flags=lanczos", fps, maxWidth)
+
+	args := []string{"-i", inputPath}
+	switch ext := strings.ToLower(filepath.Ext(outputPath)); ext {
+	case ".gif":
+		// A palette generated from the clip itself looks far better than
+		// the default 256-colour one.
+		args = append(args,
+			"-vf", scale+",split[a][b];[a]palettegen[p];[b][p]paletteuse",
+			"-loop", "0")
+	case ".webm":
+		args = append(args,
+			"-vf", scale,
+			"-c:v", "libvpx-vp9",
+			"-crf", "35",
+			"-b:v", "0",
+			"-deadline", "realtime",
+			"-cpu-used", "8",
+			"-c:a", "libopus")
+	default:
+		return fmt.Errorf("unsupported animated format %q", ext)
+	}
+	args = append(args, "-y", outputPath)
+
+	return f.ffmpeg(ctx, f.Timeouts.withDefaults().Animate, "ffmpeg animation", args...)
+}
+
+func (f FFmpeg) Peaks(ctx context.Context, inputPath string) ([]int, error) {
+	output, err := run(ctx, f.Timeouts.withDefaults().Peaks, "ffmpeg peaks", "ffmpeg",
+		"-i", inputPath,
+		"-vn",
+		"-ac", "1",
+		"-ar", strconv.Itoa(peaksSampleRate),
+		"-f", "s16le",
+		"pipe:1")
 	if err != nil {
-		return fmt.Errorf("ffmpeg trim failed: %w, output: %s", err, string(output))
+		return nil, err
 	}
 
-	return nil
+	return PeaksFromPCM(bytes.NewReader(output), peaksSampleRate)
+}
+
+// filterEscaper escapes a path for use as a filter option value in a -vf
+// filtergraph, where ':' separates options and backslashes and single quotes
+// are escape characters.
+var filterEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+
+// CutPosition maps t, in seconds into the stream, to a position in a merged
+// file of chunks that start at offsets (also seconds into the stream) and
+// last durations. t is placed relative to the chunk that contains it, so the
+// whole-second rounding of line timestamps never accumulates across chunks.
+// Instants before the first chunk or past the end of the last map to the
+// file's start or end.
+func CutPosition(offsets, durations []float64, t float64) float64 {
+	pos := 0.0
+	for i := range offsets {
+		if i+1 < len(offsets) && offsets[i+1] <= t {
+			pos += durations[i]
+			continue
+		}
+		return pos + min(max(t-offsets[i], 0), durations[i])
+	}
+	return pos
 }
 
 // ChangeExtension replaces path's extension with newExt.
@@ -0,0 +1,86 @@
+package media
+
+import "strconv"
+
+// Audio codecs an AudioProfile can select.
+const (
+	CodecAAC  = "aac"
+	CodecOpus = "opus"
+)
+
+// AudioProfile is how audio is encoded: codec, bitrate, sample rate, channel
+// count and whether it is loudness-normalised. The zero value leaves every
+// choice to ffmpeg's defaults for the output's container, which is how audio
+// was always encoded before profiles existed.
+type AudioProfile struct {
+	// Codec is CodecAAC or CodecOpus; empty means the container's default.
+	Codec string
+	// BitrateKbps is the target bitrate in kbit/s; 0 lets the encoder pick.
+	BitrateKbps int
+	// SampleRate is in Hz; 0 keeps the input's.
+	SampleRate int
+	// Channels is the channel count; 0 keeps the input's.
+	Channels int
+	// Normalize applies LoudnessTarget in the same pass that encodes the
+	// audio.
+	Normalize bool
+}
+
+// Ext returns the extension, with the leading dot, of the files the profile
+// encodes into: ".opus" (Ogg) for Opus, ".m4a" otherwise.
+func (p AudioProfile) Ext() string {
+	if p.Codec == CodecOpus {
+		return ".opus"
+	}
+	return ".m4a"
+}
+
+// For returns the profile to encode a file with extension ext: p itself when
+// ext is the profile's own, otherwise the zero profile, so an explicitly
+// requested format (say, an mp3 clip) keeps ffmpeg's defaults. Normalize
+// applies to every format.
+func (p AudioProfile) For(ext string) AudioProfile {
+	if ext != p.Ext() {
+		return AudioProfile{Normalize: p.Normalize}
+	}
+	return p
+}
+
+// args returns the ffmpeg output options that encode audio per the profile.
+func (p AudioProfile) args() []string {
+	var args []string
+	switch p.Codec {
+	case CodecAAC:
+		args = append(args, "-c:a", "aac")
+	case CodecOpus:
+		args = append(args, "-c:a", "libopus")
+	}
+	if p.BitrateKbps > 0 {
+		args = append(args, "-b:a", strconv.Itoa(p.BitrateKbps)+"k")
+	}
+	if p.Normalize {
+		args = append(args, "-af", LoudnessTarget)
+		// loudnorm upsamples to 192kHz internally, so the output rate has
+		// to be set back explicitly.
+		if p.SampleRate == 0 {
+			p.SampleRate = 48000
+		}
+	}
+	if p.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(p.SampleRate))
+	}
+	if p.Channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(p.Channels))
+	}
+	return args
+}
+
+// copyArgs returns the ffmpeg output options for a stream copy: the zero
+// profile copies every stream as is, any other copies the video and encodes
+// the audio per the profile.
+func (p AudioProfile) copyArgs() []string {
+	if p == (AudioProfile{}) {
+		return []string{"-c", "copy"}
+	}
+	return append([]string{"-c:v", "copy"}, p.args()...)
+}
@@ -61,10 +61,24 @@ var (
 		Name: "lt_server_oos",
 		Help: "The total number of times the server was out-of-sync with the client.",
 	})
+	DuplicateLines = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lt_duplicate_lines",
+		Help: "The total number of worker lines that were already stored with the same content (retries), answered as success without a broadcast.",
+	})
+	ConflictingLines = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lt_conflicting_lines",
+		Help: "The total number of worker lines whose ID was already stored with different content. Each is also counted in lt_server_oos.",
+	})
 	WebsocketError = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "lt_websocket_errors",
 		Help: "The total number of errors for the Websocket.",
 	})
+	WebsocketResumes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_websocket_resumes",
+		Help: "The total number of WebSocket reconnects carrying a resume token, by whether the token was accepted (resumed) or the client fell back to a full sync (rejected).",
+	},
+		[]string{"result"},
+	)
 	Http400Errors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "lt_400_errors",
 		Help: "The total number of HTTP 4xx client errors.",
@@ -91,6 +105,54 @@ var (
 	},
 		[]string{"key"},
 	)
+	WorkerDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_worker_drift_per_key",
+		Help: "The total number of statuschecks where the worker's transcript state disagreed with the server's, by what differed (stream, lines, checksum).",
+	},
+		[]string{"key", "reason"},
+	)
+
+	KeywordHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_keyword_hits_per_key",
+		Help: "The total number of watchlist alerts sent, by channel and the keyword or pattern that matched.",
+	},
+		[]string{"key", "keyword"},
+	)
+
+	ClipJobsQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lt_clip_jobs_queued_per_key",
+		Help: "The number of clip and trim jobs waiting for a free slot.",
+	},
+		[]string{"key"},
+	)
+
+	ClipJobsRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lt_clip_jobs_running_per_key",
+		Help: "The number of clip and trim jobs currently being processed.",
+	},
+		[]string{"key"},
+	)
+
+	ClipJobsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_clip_jobs_rejected_per_key",
+		Help: "The total number of clip and trim requests turned away because the channel's job queue was full.",
+	},
+		[]string{"key"},
+	)
+
+	ClipRequestsDeduplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_clip_requests_deduplicated_per_key",
+		Help: "The total number of clip requests answered with an identical clip that already existed or was being made.",
+	},
+		[]string{"key"},
+	)
+
+	ClipJobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_clip_jobs_finished_per_key",
+		Help: "The total number of clip and trim jobs that finished, by kind (clip, trim) and result (done, failed).",
+	},
+		[]string{"key", "kind", "result"},
+	)
 
 	TotalAudioPlayed = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "lt_total_audio_played_per_key",
@@ -150,6 +212,12 @@ var (
 	},
 		[]string{"key"},
 	)
+	TotalSearches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lt_total_searches_per_key",
+		Help: "The total number of successful calls to the /search endpoint.",
+	},
+		[]string{"key"},
+	)
 	// PastStreamFetchAge is observed only for streams that are no longer live
 	// and that have a known activation time, so it measures how far back
 	// viewers reach rather than counting every /transcript call. Compare its
@@ -13,6 +13,81 @@ type Line struct {
 	Segments       json.RawMessage `json:"segments"`
 	MediaAvailable bool            `json:"mediaAvailable"`
 	VodAccurate    bool            `json:"vodAccurate"`
+	// Hidden marks a line an admin hid. It keeps its place in the transcript
+	// (so line IDs stay contiguous) but its Segments are empty.
+	Hidden bool `json:"hidden,omitempty"`
+	// ChunkMedia describes the line's media chunk, as probed when it was
+	// uploaded. Its fields are zero for lines without media and for chunks
+	// uploaded before probing.
+	ChunkMedia
+}
+
+// ChunkMedia is the metadata of one uploaded media chunk. Duration is in
+// seconds and Codec is the audio codec (e.g. "aac"); Width and Height are
+// the video resolution, zero for audio streams. SizeBytes is the size of the
+// raw chunk as the worker sent it.
+type ChunkMedia struct {
+	Duration  float64 `json:"duration,omitempty"`
+	Codec     string  `json:"codec,omitempty"`
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	SizeBytes int64   `json:"sizeBytes,omitempty"`
+}
+
+// LineRevision is one change to a transcript line. Prior is the line's
+// segments before the change (null when the change created the line) and
+// Segments after it (null when it deleted the line). Source is what made the
+// change: "line" (worker /line or /lines), "sync" (worker /sync) or "admin".
+type LineRevision struct {
+	RevisionID int64           `json:"revisionId"`
+	LineID     int             `json:"lineId"`
+	Source     string          `json:"source"`
+	ChangedAt  int64           `json:"changedAt"`
+	Prior      json.RawMessage `json:"prior"`
+	Segments   json.RawMessage `json:"segments"`
+}
+
+// SearchHit is a transcript line matching a full-text search. Snippet is an
+// excerpt of the line's text with each matched term wrapped in
+// <mark>...</mark>.
+type SearchHit struct {
+	StreamID  string `json:"streamId"`
+	LineID    int    `json:"lineId"`
+	Timestamp int    `json:"timestamp"`
+	Snippet   string `json:"snippet"`
+}
+
+// Clip is a catalogued media clip. StartLine and EndLine are the transcript
+// lines it was cut from; a trim keeps its source clip's range (-1 when the
+// source predates the catalog) and names the source in SourceClipID. Format
+// is the file extension without the dot; an mp4 clip with HasSidecar also has
+// an m4a copy of its audio under the same clip ID.
+type Clip struct {
+	ClipID       string `json:"clipId"`
+	StreamID     string `json:"streamId"`
+	StartLine    int    `json:"startLine"`
+	EndLine      int    `json:"endLine"`
+	Format       string `json:"format"`
+	Title        string `json:"title,omitempty"`
+	CreatedAt    int64  `json:"createdAt"`
+	SourceClipID string `json:"sourceClipId,omitempty"`
+	SizeBytes    int64  `json:"sizeBytes"`
+	HasSidecar   bool   `json:"hasSidecar,omitempty"`
+	// StartTime and EndTime are the span, in seconds into the stream, of a
+	// clip cut by time rather than by whole lines. StartLine and EndLine are
+	// then the lines whose media it was cut from. Nil for other clips.
+	StartTime *float64 `json:"startTime,omitempty"`
+	EndTime   *float64 `json:"endTime,omitempty"`
+	// Subtitles is how the clip carries its transcript: "burn" (rendered
+	// onto the video), "embed" (a subtitle track) or empty for none.
+	Subtitles string `json:"subtitles,omitempty"`
+	// Normalized is set when the clip's audio was loudness-normalised (EBU
+	// R128).
+	Normalized bool `json:"normalized,omitempty"`
+	// Encoding describes the encoder settings the clip was made with: the
+	// clip profile of its audio, or the size and frame rate of a gif or
+	// webm. Clips are only reused under the same settings.
+	Encoding string `json:"encoding,omitempty"`
 }
 
 // Stream represents the state of a stream for a channel in the database.
@@ -24,6 +99,10 @@ type Stream struct {
 	IsLive        bool   `json:"isLive"`
 	MediaType     string `json:"mediaType"`
 	ActivatedTime int64  `json:"activatedTime"`
+	// AudioFormat is the extension of the stream's per-line audio files
+	// (e.g. ".opus"). Empty for streams from before encoding profiles, whose
+	// audio is ".m4a".
+	AudioFormat string `json:"audioFormat,omitempty"`
 }
 
 // WorkerData represents the full state of the worker. Used to sync the server
@@ -683,6 +683,176 @@ func TestAdminEditStreamBroadcastsPastStreams(t *testing.T) {
 	}
 }
 
+func TestAdminEditLine(t *testing.T) {
+	app, mux := setupTestApp(t, []string{"doki"})
+	seedExampleData(t, app, "doki") // live, lines 0 and 1
+	conn, cleanup := dialAndDrain(t, mux, "doki", 1)
+	defer cleanup()
+
+	rec := adminReq(t, mux, http.MethodPost, "/doki/admin/line/stream-1/1", "admin-doki", map[string]any{
+		"segments": []map[string]any{{"timestamp": 200, "text": "This is a fixed test"}},
+	})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status=%d want 204, body=%s", rec.Code, rec.Body.String())
+	}
+
+	data := nextEvent(t, conn, ws.EventUpdatedLine)
+	line := data["line"].(map[string]any)
+	if data["streamId"] != "stream-1" || line["id"] != float64(1) {
+		t.Errorf("unexpected updatedLine: %v", data)
+	}
+	if segs := line["segments"].([]any); segs[0].(map[string]any)["text"] != "This is a fixed test" {
+		t.Errorf("segments=%v", line["segments"])
+	}
+	if data["resumeToken"] != app.Channels["doki"].resumeToken("stream-1", 1) {
+		t.Errorf("resumeToken=%v", data["resumeToken"])
+	}
+
+	// Hiding keeps the correction underneath.
+	rec = adminReq(t, mux, http.MethodPost, "/doki/admin/line/stream-1/1", "admin-doki", map[string]any{"hidden": true})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("hide: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	line = nextEvent(t, conn, ws.EventUpdatedLine)["line"].(map[string]any)
+	if line["hidden"] != true || len(line["segments"].([]any)) != 0 {
+		t.Errorf("hidden line=%v", line)
+	}
+	rec = adminReq(t, mux, http.MethodPost, "/doki/admin/line/stream-1/1", "admin-doki", map[string]any{"hidden": false})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("unhide: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+
+	// The worker resyncing its own (uncorrected) copy does not undo the edit.
+	body, _ := json.Marshal(model.WorkerData{
+		StreamID:    "stream-1",
+		StreamTitle: "Test Stream Title",
+		StartTime:   streamRow(t, app, "doki", "stream-1").StartTime,
+		IsLive:      true,
+		MediaType:   "audio",
+		Transcript: []model.Line{
+			{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hello world"}]`)},
+			{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"timestamp": 200, "text": "This is a test"}]`)},
+			{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"timestamp": 300, "text": "More"}]`)},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/doki/sync", bytes.NewBuffer(body))
+	req.Header.Set("X-API-Key", app.ApiKey)
+	syncRec := httptest.NewRecorder()
+	mux.ServeHTTP(syncRec, req)
+	if syncRec.Code != http.StatusOK {
+		t.Fatalf("sync: status=%d body=%s", syncRec.Code, syncRec.Body.String())
+	}
+
+	lines, err := app.Store.GetTranscript(context.Background(), "doki", "stream-1")
+	if err != nil || len(lines) != 3 {
+		t.Fatalf("transcript after sync: %v (err %v)", lines, err)
+	}
+	var segs []segment
+	json.Unmarshal(lines[1].Segments, &segs)
+	if len(segs) != 1 || segs[0].Text != "This is a fixed test" || lines[1].Hidden {
+		t.Errorf("edit lost after sync: %+v", lines[1])
+	}
+}
+
+func TestAdminEditLineRejectsBadRequests(t *testing.T) {
+	app, mux := setupTestApp(t, []string{"doki"})
+	seedExampleData(t, app, "doki")
+
+	tests := []struct {
+		name string
+		path string
+		body any
+		want int
+	}{
+		{"no fields", "/doki/admin/line/stream-1/0", map[string]any{}, http.StatusBadRequest},
+		{"segments not an array", "/doki/admin/line/stream-1/0", map[string]any{"segments": "hi"}, http.StatusBadRequest},
+		{"empty segments", "/doki/admin/line/stream-1/0", map[string]any{"segments": []any{}}, http.StatusBadRequest},
+		{"segment without text", "/doki/admin/line/stream-1/0", map[string]any{"segments": []any{map[string]any{"timestamp": 1}}}, http.StatusBadRequest},
+		{"bad line id", "/doki/admin/line/stream-1/x", map[string]any{"hidden": true}, http.StatusBadRequest},
+		{"unknown line", "/doki/admin/line/stream-1/9", map[string]any{"hidden": true}, http.StatusNotFound},
+		{"unknown stream", "/doki/admin/line/nope/0", map[string]any{"hidden": true}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		if rec := adminReq(t, mux, http.MethodPost, tc.path, "admin-doki", tc.body); rec.Code != tc.want {
+			t.Errorf("%s: status=%d want %d, body=%s", tc.name, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+	if rec := adminReq(t, mux, http.MethodPost, "/doki/admin/line/stream-1/0", "", map[string]any{"hidden": true}); rec.Code != http.StatusForbidden {
+		t.Errorf("no key: status=%d want 403", rec.Code)
+	}
+}
+
+func TestAdminLineHistoryAndRevert(t *testing.T) {
+	app, mux := setupTestApp(t, []string{"doki"})
+	seedExampleData(t, app, "doki") // lines 0 and 1, created by a sync
+	ctx := context.Background()
+
+	line := model.Line{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"text":"original quote"}]`)}
+	if err := app.Store.InsertNextLine(ctx, "doki", "stream-1", line); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	lines, _ := app.Store.GetWorkerTranscript(ctx, "doki", "stream-1")
+	lines[2].Segments = json.RawMessage(`[{"text":"rewritten quote"}]`)
+	if _, _, err := app.Store.SyncTranscript(ctx, "doki", "stream-1", lines); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	rec := adminReq(t, mux, http.MethodGet, "/doki/admin/line/stream-1/2/history", "admin-doki", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var history []model.LineRevision
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(history) != 2 || history[0].Source != "line" || history[1].Source != "sync" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	if string(history[1].Prior) != `[{"text":"original quote"}]` || string(history[1].Segments) != `[{"text":"rewritten quote"}]` {
+		t.Errorf("sync revision=%+v", history[1])
+	}
+
+	// A line never changed since it was created has just its creating
+	// revision; unknown lines 404.
+	rec = adminReq(t, mux, http.MethodGet, "/doki/admin/line/stream-1/0/history", "admin-doki", nil)
+	var unchanged []model.LineRevision
+	if err := json.Unmarshal(rec.Body.Bytes(), &unchanged); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("unchanged line: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if len(unchanged) != 1 || unchanged[0].Source != "sync" {
+		t.Errorf("unchanged line history=%+v, want only its creating sync revision", unchanged)
+	}
+	if rec := adminReq(t, mux, http.MethodGet, "/doki/admin/line/stream-1/9/history", "admin-doki", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown line: status=%d want 404", rec.Code)
+	}
+
+	conn, cleanup := dialAndDrain(t, mux, "doki", 1)
+	defer cleanup()
+
+	rec = adminReq(t, mux, http.MethodPost, "/doki/admin/line/stream-1/2/revert", "admin-doki", map[string]any{"revisionId": history[1].RevisionID})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("revert: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	got := nextEvent(t, conn, ws.EventUpdatedLine)["line"].(map[string]any)
+	if segs := got["segments"].([]any); segs[0].(map[string]any)["text"] != "original quote" {
+		t.Errorf("updatedLine segments=%v", got["segments"])
+	}
+
+	for _, tc := range []struct {
+		name string
+		body any
+		want int
+	}{
+		{"missing revision", map[string]any{}, http.StatusBadRequest},
+		{"creating revision", map[string]any{"revisionId": history[0].RevisionID}, http.StatusBadRequest},
+		{"unknown revision", map[string]any{"revisionId": 999}, http.StatusNotFound},
+	} {
+		if rec := adminReq(t, mux, http.MethodPost, "/doki/admin/line/stream-1/2/revert", "admin-doki", tc.body); rec.Code != tc.want {
+			t.Errorf("%s: status=%d want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
 func TestAdminDeleteStreamRejectsLive(t *testing.T) {
 	app, mux := setupTestApp(t, []string{"doki"})
 	seedExampleData(t, app, "doki") // seeds with IsLive=true
@@ -951,6 +1121,38 @@ func TestAdminActionsNotifyDiscord(t *testing.T) {
 				"Media Deleted": "no",
 			},
 		},
+		{
+			name: "revert line",
+			setup: func(t *testing.T, app *App) {
+				seedExampleData(t, app, "doki")
+				if _, err := app.Store.EditLine(context.Background(), "doki", "stream-1", 1, json.RawMessage(`[{"text":"x"}]`)); err != nil {
+					t.Fatalf("edit: %v", err)
+				}
+			},
+			method:    http.MethodPost,
+			path:      "/doki/admin/line/stream-1/1/revert",
+			body:      map[string]any{"revisionId": 1},
+			wantTitle: "Admin: Reverted transcript line",
+			wantFields: map[string]string{
+				"Stream ID":       "stream-1",
+				"Line ID":         "1",
+				"Revision Undone": "1",
+			},
+		},
+		{
+			name:      "edit line",
+			setup:     func(t *testing.T, app *App) { seedExampleData(t, app, "doki") },
+			method:    http.MethodPost,
+			path:      "/doki/admin/line/stream-1/1",
+			body:      map[string]any{"hidden": true},
+			wantTitle: "Admin: Edited transcript line",
+			wantFields: map[string]string{
+				"Stream ID":      "stream-1",
+				"Line ID":        "1",
+				"Text Corrected": "no",
+				"Hidden":         "yes",
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -26,6 +26,7 @@ import (
 	"live-transcript-server/internal/notify"
 	"live-transcript-server/internal/storage"
 	"live-transcript-server/internal/store"
+	"live-transcript-server/internal/watchlist"
 	"live-transcript-server/internal/ws"
 
 	"github.com/gorilla/websocket"
@@ -40,11 +41,41 @@ type ChannelState struct {
 	NumPastStreams  int
 	Hub             *ws.Hub
 
+	// NormalizeLoudness is the default for whether clips, trims and full
+	// VODs get their audio loudness-normalised; requests may override it.
+	NormalizeLoudness bool
+
+	// AudioProfile, ClipProfile and VodProfile encode the channel's per-line
+	// audio, audio clips and trims, and audio-only VODs. The zero profile
+	// means ffmpeg's defaults for AAC in .m4a.
+	AudioProfile media.AudioProfile
+	ClipProfile  media.AudioProfile
+	VodProfile   media.AudioProfile
+
+	// AutoVodDelay is how long after a stream ends its full VOD is built
+	// automatically (see scheduleAutoVod). Zero disables automatic builds.
+	AutoVodDelay time.Duration
+
+	// ChunkSeconds is the length of the worker's per-line media chunks, with
+	// the default filled in.
+	ChunkSeconds int
+
 	// AdminChangeCounter versions the admin-visible state of the channel for
 	// the GET /{channel}/admin/poll long poll. Bumped (via bumpAdminChange)
 	// on incoming/restart/stream changes; seeded from the clock so a client
 	// holding a pre-restart counter resyncs immediately.
 	AdminChangeCounter atomic.Int64
+
+	// TranscriptRevision is bumped whenever already-sent lines are rewritten
+	// (e.g. by a worker /sync), and is part of every resume token: a client
+	// holding an older revision may have stale lines, so its resume is
+	// refused and it gets a full sync. Seeded from the clock like
+	// AdminChangeCounter so pre-restart tokens never match.
+	TranscriptRevision atomic.Int64
+
+	// Watchlist alerts on keywords in new lines (see checkWatchlist). Nil
+	// when the channel has none configured.
+	Watchlist *watchlist.Watchlist
 }
 
 // App holds the application-wide dependencies and configuration.
@@ -65,6 +96,13 @@ type App struct {
 	// Vods tracks in-flight full-VOD builds so concurrent admin requests for
 	// the same stream collapse into a single build. See vod.go.
 	Vods *vodRegistry
+	// Clips queues clip and trim jobs with per-channel limits. See
+	// clipjobs.go.
+	Clips *clipQueue
+	// AnimatedClips sizes gif and webm clips, with the defaults filled in.
+	AnimatedClips config.AnimatedClipsConfig
+	// VodTimeout bounds the ffmpeg work of a full-VOD build.
+	VodTimeout time.Duration
 
 	IncomingStreamTTL time.Duration
 	Version           string
@@ -83,11 +121,15 @@ func NewApp(cfg config.Config, st *store.Store, tempDir, version, buildTime stri
 	if ttlMinutes <= 0 {
 		ttlMinutes = 24 * 60 // 24 hours
 	}
+	vodSeconds := cfg.FFmpeg.VodSeconds
+	if vodSeconds <= 0 {
+		vodSeconds = 4 * 60 * 60 // 4 hours
+	}
 
 	app := &App{
 		ApiKey:  cfg.Credentials.ApiKey,
 		Store:   st,
-		Media:   media.FFmpeg{},
+		Media:   media.FFmpeg{Timeouts: ffmpegTimeouts(cfg.FFmpeg)},
 		Discord: discord.NewClient(cfg.Discord, version, cfg.Channels),
 		Archive: archive.NewClient(cfg.ArchiveURL, cfg.ArchiveKey),
 		Upgrader: websocket.Upgrader{
@@ -99,6 +141,9 @@ func NewApp(cfg config.Config, st *store.Store, tempDir, version, buildTime stri
 		Notifier:          notify.New(),
 		Channels:          make(map[string]*ChannelState),
 		Vods:              newVodRegistry(),
+		Clips:             newClipQueue(cfg.ClipQueue.Concurrency, cfg.ClipQueue.MaxPending),
+		AnimatedClips:     animatedClipDefaults(cfg.AnimatedClips),
+		VodTimeout:        time.Duration(vodSeconds) * time.Second,
 		MaxConn:           10_000, // through testing, assuming a steady flow of connections, 10k connections will use 200 millicores
 		MaxClipSize:       40,
 		TempDir:           tempDir,
@@ -109,15 +154,30 @@ func NewApp(cfg config.Config, st *store.Store, tempDir, version, buildTime stri
 	app.ctx, app.cancel = context.WithCancel(context.Background())
 
 	for _, cc := range cfg.Channels {
+		wl, err := watchlist.New(cc.Watchlist)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s watchlist: %w", cc.Name, err)
+		}
 		cs := &ChannelState{
-			Key:             cc.Name,
-			AdminKey:        cc.AdminKey,
-			MembersName:     cc.MembersName,
-			BaseMediaFolder: filepath.Join(tempDir, cc.Name),
-			NumPastStreams:  cc.NumPastStreams,
-			Hub:             ws.NewHub(cc.Name, app.MaxConn),
+			Key:               cc.Name,
+			AdminKey:          cc.AdminKey,
+			MembersName:       cc.MembersName,
+			BaseMediaFolder:   filepath.Join(tempDir, cc.Name),
+			NumPastStreams:    cc.NumPastStreams,
+			Hub:               ws.NewHub(cc.Name, app.MaxConn),
+			Watchlist:         wl,
+			NormalizeLoudness: cc.NormalizeLoudness,
+			AudioProfile:      audioProfile(cfg.EncodingProfiles, cc.Encoding.Audio),
+			ClipProfile:       audioProfile(cfg.EncodingProfiles, cc.Encoding.Clips),
+			VodProfile:        audioProfile(cfg.EncodingProfiles, cc.Encoding.Vods),
+			AutoVodDelay:      autoVodDelay(cc.AutoVod),
+			ChunkSeconds:      cc.ChunkSeconds,
+		}
+		if cs.ChunkSeconds == 0 {
+			cs.ChunkSeconds = defaultChunkSeconds
 		}
 		cs.AdminChangeCounter.Store(time.Now().UnixMilli())
+		cs.TranscriptRevision.Store(time.Now().UnixMilli())
 		app.Channels[cc.Name] = cs
 	}
 
@@ -136,6 +196,57 @@ func NewApp(cfg config.Config, st *store.Store, tempDir, version, buildTime stri
 	return app, nil
 }
 
+// audioProfile looks up the encoding profile called name. An empty or unknown
+// name (Validate rejects the latter) is the zero profile.
+func audioProfile(profiles map[string]config.EncodingProfile, name string) media.AudioProfile {
+	p, ok := profiles[name]
+	if name == "" || !ok {
+		return media.AudioProfile{}
+	}
+	return media.AudioProfile{
+		Codec:       p.Codec,
+		BitrateKbps: p.BitrateKbps,
+		SampleRate:  p.SampleRate,
+		Channels:    p.Channels,
+	}
+}
+
+// defaultAutoVodDelay leaves the worker time to finish uploading a stream's
+// last chunks before its automatic VOD build merges them.
+const defaultAutoVodDelay = 5 * time.Minute
+
+// autoVodDelay converts a channel's autoVod config: zero when automatic
+// builds are disabled, otherwise the configured delay or its default.
+func autoVodDelay(cfg config.AutoVodConfig) time.Duration {
+	if !cfg.Enabled {
+		return 0
+	}
+	if cfg.DelaySeconds == 0 {
+		return defaultAutoVodDelay
+	}
+	return time.Duration(cfg.DelaySeconds) * time.Second
+}
+
+// defaultChunkSeconds is the worker's usual chunk length.
+const defaultChunkSeconds = 10
+
+// ffmpegTimeouts converts the configured ffmpeg timeouts. Zero stays zero,
+// which media.FFmpeg reads as the operation's default.
+func ffmpegTimeouts(cfg config.FFmpegConfig) media.Timeouts {
+	seconds := func(s int) time.Duration { return time.Duration(s) * time.Second }
+	return media.Timeouts{
+		Convert:      seconds(cfg.ConvertSeconds),
+		Remux:        seconds(cfg.RemuxSeconds),
+		Trim:         seconds(cfg.TrimSeconds),
+		ExtractFrame: seconds(cfg.ExtractFrameSeconds),
+		Cut:          seconds(cfg.CutSeconds),
+		Duration:     seconds(cfg.ProbeSeconds),
+		Subtitles:    seconds(cfg.SubtitlesSeconds),
+		Animate:      seconds(cfg.AnimateSeconds),
+		Peaks:        seconds(cfg.PeaksSeconds),
+	}
+}
+
 // Init performs the environment side effects the app needs before serving:
 // temp/media directories and worker-status seeding.
 func (app *App) Init(ctx context.Context) error {
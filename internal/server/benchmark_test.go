@@ -32,7 +32,7 @@ func BenchmarkSync(b *testing.B) {
 			Segments:  json.RawMessage(fmt.Sprintf(`[{"text": "Line %d content is here and it might be long"}]`, i)),
 		}
 	}
-	app.Store.ReplaceTranscript(ctx, key, streamID, lines)
+	seedTranscript(b, app, key, streamID, lines)
 
 	server := httptest.NewServer(mux)
 	defer server.Close()
@@ -0,0 +1,512 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"live-transcript-server/internal/metrics"
+	"live-transcript-server/internal/model"
+
+	"github.com/lithammer/shortuuid/v4"
+)
+
+// Clip and trim creation run ffmpeg over up to MaxClipSize chunks, which for
+// video easily outlasts a proxy's request timeout, and a burst of requests
+// would otherwise start one ffmpeg each. So both run as jobs: the request
+// validates what it can, queues the work and answers 202 with a job ID, and
+// the client polls GET /{channel}/clip/job/{id} until the job is done.
+//
+// The queue is bounded per channel, like vodRegistry is keyed per stream:
+//   - at most concurrency jobs of a channel run at once; the rest wait for a
+//     slot in the order they arrived, and
+//   - once maxPending jobs are waiting or running, further requests are
+//     turned away with 503 instead of piling up.
+//
+// Many viewers tend to clip the same moment, so an identical clip (same
+// stream, line range and format) is only ever built once:
+//   - a request that matches a queued or running job joins that job, the way
+//     vodRegistry.claim collapses VOD builds,
+//   - a request that matches a finished job, or a clip already in the
+//     catalog, is answered at once with that clip, and
+//   - the index is dropped with the stream (forgetStream), so a clip is never
+//     reused once its media is gone.
+
+// Job states reported to the client.
+const (
+	clipJobStateQueued  = "queued"  // waiting for a slot
+	clipJobStateRunning = "running" // being processed
+	clipJobStateDone    = "done"    // the clip is in storage and the catalog
+	clipJobStateFailed  = "failed"  // processing failed; no clip was made
+)
+
+// Phases of a running job, so a long wait is legible.
+const (
+	clipJobPhaseMerging     = "merging chunks"
+	clipJobPhaseConverting  = "converting"
+	clipJobPhaseDownloading = "downloading source"
+	clipJobPhaseTrimming    = "trimming"
+	clipJobPhaseSubtitles   = "adding subtitles"
+	clipJobPhaseAnimating   = "animating"
+	clipJobPhaseUploading   = "uploading"
+)
+
+// Kinds of job, one per endpoint that queues them.
+const (
+	clipJobKindClip = "clip"
+	clipJobKindTrim = "trim"
+)
+
+// Queue limits used when the config leaves them at zero.
+const (
+	defaultClipConcurrency = 2
+	defaultClipMaxPending  = 20
+)
+
+// clipJobRetention is how long a finished job can still be polled. Clients
+// poll every few seconds, so this only has to cover one that fell behind.
+const clipJobRetention = 15 * time.Minute
+
+// errClipQueueFull is returned by enqueue when the channel already has
+// maxPending jobs waiting or running.
+var errClipQueueFull = errors.New("clip queue is full")
+
+// clipJobStatus is a point-in-time snapshot of a job. Copied out of the job
+// under its lock so readers never touch live fields.
+type clipJobStatus struct {
+	State   string
+	Phase   string
+	Failure string
+	// Clip is the catalogued result, set once the job is done.
+	Clip       *model.Clip
+	CreatedAt  int64
+	StartedAt  int64
+	FinishedAt int64
+}
+
+// clipJob is one queued clip or trim. Its status is written by the goroutine
+// doing the work and read by polling requests, so all access goes through the
+// lock.
+type clipJob struct {
+	id         string
+	channelKey string
+	kind       string
+	// requestKey indexes the job for deduplication; empty for trims and
+	// subtitled clips.
+	requestKey string
+
+	mu sync.Mutex
+	clipJobStatus
+}
+
+func (j *clipJob) status() clipJobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.clipJobStatus
+}
+
+func (j *clipJob) setPhase(phase string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Phase = phase
+}
+
+func (j *clipJob) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.State = clipJobStateRunning
+	j.StartedAt = time.Now().Unix()
+}
+
+// finish records the job's outcome. A nil err marks the clip available.
+func (j *clipJob) finish(clip *model.Clip, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Phase = ""
+	j.FinishedAt = time.Now().Unix()
+	if err != nil {
+		j.State = clipJobStateFailed
+		j.Failure = err.Error()
+		return
+	}
+	j.State = clipJobStateDone
+	j.Clip = clip
+}
+
+// clipQueue tracks clip jobs and hands out per-channel processing slots.
+type clipQueue struct {
+	concurrency int
+	maxPending  int
+
+	mu   sync.Mutex
+	jobs map[string]*clipJob
+	// byRequest indexes clip jobs by channel and clipRequestKey, so an
+	// identical request finds the job that already made (or is making) it.
+	byRequest map[string]*clipJob
+	// slots holds one semaphore per channel, created on first use.
+	slots map[string]chan struct{}
+	// pending counts each channel's jobs that are waiting or running.
+	pending map[string]int
+}
+
+// newClipQueue returns a queue with the given per-channel limits; zero or
+// negative values fall back to the defaults.
+func newClipQueue(concurrency, maxPending int) *clipQueue {
+	if concurrency <= 0 {
+		concurrency = defaultClipConcurrency
+	}
+	if maxPending <= 0 {
+		maxPending = defaultClipMaxPending
+	}
+	return &clipQueue{
+		concurrency: concurrency,
+		maxPending:  maxPending,
+		jobs:        make(map[string]*clipJob),
+		byRequest:   make(map[string]*clipJob),
+		slots:       make(map[string]chan struct{}),
+		pending:     make(map[string]int),
+	}
+}
+
+// clipRequestKey identifies a clip without subtitles by what it is cut from
+// and every option that shapes its media: the same lines of a stream in the
+// same format, with the same normalisation and encoding (see clipEncoding),
+// always make the same media. Subtitled clips have no key, as their captions
+// follow the lines' text, which can change.
+func clipRequestKey(streamID string, start, end int, format string, normalized bool, encoding string) string {
+	return fmt.Sprintf("%s/%d/%d/%s/%t/%s", streamID, start, end, format, normalized, encoding)
+}
+
+// clipTimeRequestKey is clipRequestKey for a clip cut by time, with the span
+// in seconds into the stream.
+func clipTimeRequestKey(streamID string, startTime, endTime float64, format string, normalized bool, encoding string) string {
+	return fmt.Sprintf("%s/%.3fs/%.3fs/%s/%t/%s", streamID, startTime, endTime, format, normalized, encoding)
+}
+
+func clipIndexKey(channelKey, requestKey string) string {
+	return channelKey + "/" + requestKey
+}
+
+// enqueue registers a new queued job for a channel, or returns
+// errClipQueueFull when the channel has no room. Finished jobs past their
+// retention are dropped here, so the registry stays small without a sweeper.
+//
+// A non-empty requestKey makes the job deduplicated: if the channel already
+// has a queued, running or done job for it, that job is returned instead and
+// started is false. Only the caller that started a job may run its work. A
+// failed job is replaced, so a clip can be retried after a failure.
+func (q *clipQueue) enqueue(channelKey, kind, requestKey string) (job *clipJob, started bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cutoff := time.Now().Add(-clipJobRetention).Unix()
+	for id, job := range q.jobs {
+		if s := job.status(); s.FinishedAt != 0 && s.FinishedAt < cutoff {
+			delete(q.jobs, id)
+			q.unindex(job)
+		}
+	}
+
+	if requestKey != "" {
+		if existing, ok := q.byRequest[clipIndexKey(channelKey, requestKey)]; ok && existing.status().State != clipJobStateFailed {
+			return existing, false, nil
+		}
+	}
+
+	if q.pending[channelKey] >= q.maxPending {
+		return nil, false, errClipQueueFull
+	}
+	q.pending[channelKey]++
+	metrics.ClipJobsQueued.WithLabelValues(channelKey).Inc()
+
+	job = q.add(channelKey, kind, requestKey, clipJobStatus{State: clipJobStateQueued})
+	return job, true, nil
+}
+
+// finished registers an already-done job for a clip found in the catalog, so
+// a deduplicated request still gets a job it can poll, and later identical
+// requests are answered without going back to the catalog.
+func (q *clipQueue) finished(channelKey, kind, requestKey string, clip *model.Clip) *clipJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now().Unix()
+	return q.add(channelKey, kind, requestKey, clipJobStatus{
+		State:      clipJobStateDone,
+		Clip:       clip,
+		StartedAt:  now,
+		FinishedAt: now,
+	})
+}
+
+// add creates and indexes a job. Callers hold q.mu.
+func (q *clipQueue) add(channelKey, kind, requestKey string, status clipJobStatus) *clipJob {
+	status.CreatedAt = time.Now().Unix()
+	job := &clipJob{
+		id:            shortuuid.New(),
+		channelKey:    channelKey,
+		kind:          kind,
+		requestKey:    requestKey,
+		clipJobStatus: status,
+	}
+	q.jobs[job.id] = job
+	if requestKey != "" {
+		q.byRequest[clipIndexKey(channelKey, requestKey)] = job
+	}
+	return job
+}
+
+// unindex drops a job from the request index, unless a newer job for the
+// same request has replaced it there. Callers hold q.mu.
+func (q *clipQueue) unindex(job *clipJob) {
+	if job.requestKey == "" {
+		return
+	}
+	key := clipIndexKey(job.channelKey, job.requestKey)
+	if q.byRequest[key] == job {
+		delete(q.byRequest, key)
+	}
+}
+
+// lookup returns the channel's queued, running or done job for a clip
+// request, or nil when there is none to reuse.
+func (q *clipQueue) lookup(channelKey, requestKey string) *clipJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.byRequest[clipIndexKey(channelKey, requestKey)]
+	if !ok || job.status().State == clipJobStateFailed {
+		return nil
+	}
+	return job
+}
+
+// forgetStream drops a stream's clips from the request index. Called when the
+// stream's media is deleted or pruned, so a later request for the same lines
+// is never answered with a clip that no longer exists. The jobs themselves
+// stay pollable until their retention runs out.
+func (q *clipQueue) forgetStream(channelKey, streamID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	prefix := clipIndexKey(channelKey, streamID+"/")
+	for key := range q.byRequest {
+		if strings.HasPrefix(key, prefix) {
+			delete(q.byRequest, key)
+		}
+	}
+}
+
+// get returns a channel's job by ID, or nil when there is none (never
+// queued, queued on another channel, or finished long enough ago to be
+// dropped).
+func (q *clipQueue) get(channelKey, id string) *clipJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.jobs[id]
+	if job == nil || job.channelKey != channelKey {
+		return nil
+	}
+	return job
+}
+
+// slot returns the channel's semaphore.
+func (q *clipQueue) slot(channelKey string) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s, ok := q.slots[channelKey]
+	if !ok {
+		s = make(chan struct{}, q.concurrency)
+		q.slots[channelKey] = s
+	}
+	return s
+}
+
+// acquire blocks until the job's channel has a free slot, and marks the job
+// running. It returns false if ctx ends first; the job is then no longer
+// pending and the caller must not call release.
+func (q *clipQueue) acquire(ctx context.Context, job *clipJob) bool {
+	select {
+	case q.slot(job.channelKey) <- struct{}{}:
+	case <-ctx.Done():
+		metrics.ClipJobsQueued.WithLabelValues(job.channelKey).Dec()
+		q.done(job)
+		return false
+	}
+	metrics.ClipJobsQueued.WithLabelValues(job.channelKey).Dec()
+	metrics.ClipJobsRunning.WithLabelValues(job.channelKey).Inc()
+	job.start()
+	return true
+}
+
+// release frees the slot a running job held.
+func (q *clipQueue) release(job *clipJob) {
+	<-q.slot(job.channelKey)
+	metrics.ClipJobsRunning.WithLabelValues(job.channelKey).Dec()
+	q.done(job)
+}
+
+func (q *clipQueue) done(job *clipJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[job.channelKey]--
+}
+
+// clipWork produces a job's clip: it does the media processing and upload,
+// reporting progress through job.setPhase, and returns the clip to catalog.
+// Its error is shown to the client as-is, so it must not carry internal
+// detail; the work logs that itself.
+type clipWork func(job *clipJob) (model.Clip, error)
+
+// queueClipJob queues work for a channel and answers the request: 202 with the
+// job's state, or 503 when the channel's queue is full. A non-empty requestKey
+// deduplicates the job (see enqueue); a request that joins an existing job is
+// answered with that job instead and work is not run.
+//
+// The job runs on its own goroutine once a slot frees up. Unlike a VOD build
+// it is tracked by app.wg: a clip is at most MaxClipSize chunks, and the
+// catalog write at the end must not race the store closing on shutdown. A
+// job still waiting for a slot at shutdown fails without running.
+func (app *App) queueClipJob(w http.ResponseWriter, cs *ChannelState, kind, requestKey string, work clipWork) {
+	job, started, err := app.Clips.enqueue(cs.Key, kind, requestKey)
+	if err != nil {
+		metrics.ClipJobsRejected.WithLabelValues(cs.Key).Inc()
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "Too many clips are being made right now. Try again in a moment.", http.StatusServiceUnavailable)
+		slog.Warn("clip queue full", "key", cs.Key, "func", "queueClipJob", "kind", kind)
+		return
+	}
+	if !started {
+		metrics.ClipRequestsDeduplicated.WithLabelValues(cs.Key).Inc()
+		writeClipJob(w, job)
+		return
+	}
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		if !app.Clips.acquire(app.ctx, job) {
+			job.finish(nil, errors.New("server is shutting down"))
+			metrics.ClipJobsFinished.WithLabelValues(cs.Key, kind, clipJobStateFailed).Inc()
+			return
+		}
+		defer app.Clips.release(job)
+
+		start := time.Now()
+		clip, err := work(job)
+		if err != nil {
+			job.finish(nil, err)
+			metrics.ClipJobsFinished.WithLabelValues(cs.Key, kind, clipJobStateFailed).Inc()
+			return
+		}
+		app.catalogClip(context.WithoutCancel(app.ctx), cs, &clip)
+		job.finish(&clip, nil)
+		metrics.ClipJobsFinished.WithLabelValues(cs.Key, kind, clipJobStateDone).Inc()
+		if time.Since(start).Seconds() > 10 {
+			slog.Warn("slow clip processing time", "key", cs.Key, "func", "queueClipJob", "kind", kind, "processingTimeMs", time.Since(start).Milliseconds(), "clipID", clip.ClipID)
+		}
+	}()
+
+	writeClipJob(w, job)
+}
+
+// reuseClip answers a clip request with an identical clip that already exists
+// or is being made: a job in the queue's index, or failing that a clip in the
+// catalog found by find (the catalog outlives both the index and a restart).
+// It returns false, writing nothing, when there is none and the clip has to be
+// made.
+func (app *App) reuseClip(w http.ResponseWriter, r *http.Request, cs *ChannelState, requestKey string, find func(ctx context.Context) (*model.Clip, error)) bool {
+	job := app.Clips.lookup(cs.Key, requestKey)
+	if job == nil {
+		clip, err := find(r.Context())
+		if err != nil {
+			// Deduplication only saves work, so a failed lookup just means
+			// the clip is made again.
+			slog.Warn("failed to look up existing clip", "key", cs.Key, "func", "reuseClip", "requestKey", requestKey, "err", err)
+			return false
+		}
+		if clip == nil {
+			return false
+		}
+		job = app.Clips.finished(cs.Key, clipJobKindClip, requestKey, clip)
+	}
+	metrics.ClipRequestsDeduplicated.WithLabelValues(cs.Key).Inc()
+	writeClipJob(w, job)
+	return true
+}
+
+// writeClipJob responds with a job's state: 200 once it is finished, 202 while
+// it is still queued or running.
+func writeClipJob(w http.ResponseWriter, job *clipJob) {
+	resp := clipJobResponse(job)
+	if resp.State == clipJobStateQueued || resp.State == clipJobStateRunning {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	writeJSON(w, resp)
+}
+
+// reportClipJobError records a job's server-side failure the way report500
+// does for a request: log at Error level and alert Discord. The job's own
+// (client-facing) error is set by the caller.
+func (app *App) reportClipJobError(job *clipJob, err error, msg string, attrs ...any) {
+	app.Discord.Notify500Error(fmt.Errorf("%s: %w", msg, err), "clip job "+job.id)
+	slog.Error(msg, append(attrs, "key", job.channelKey, "jobID", job.id, "kind", job.kind, "err", err)...)
+}
+
+// ClipJobResponse is the state of a clip or trim job, returned when it is
+// queued and by GET /{channel}/clip/job/{id}.
+type ClipJobResponse struct {
+	JobID string `json:"job_id"`
+	// Kind is "clip" or "trim".
+	Kind  string `json:"kind"`
+	State string `json:"state"`
+	// Phase is what a running job is currently doing; empty otherwise.
+	Phase string `json:"phase,omitempty"`
+	// Error says why a failed job failed.
+	Error string `json:"error,omitempty"`
+	// ClipID and Clip describe the result once the state is "done".
+	ClipID     string      `json:"clip_id,omitempty"`
+	Clip       *model.Clip `json:"clip,omitempty"`
+	CreatedAt  int64       `json:"created_at"`
+	StartedAt  int64       `json:"started_at,omitempty"`
+	FinishedAt int64       `json:"finished_at,omitempty"`
+}
+
+func clipJobResponse(job *clipJob) ClipJobResponse {
+	s := job.status()
+	resp := ClipJobResponse{
+		JobID:      job.id,
+		Kind:       job.kind,
+		State:      s.State,
+		Phase:      s.Phase,
+		Error:      s.Failure,
+		Clip:       s.Clip,
+		CreatedAt:  s.CreatedAt,
+		StartedAt:  s.StartedAt,
+		FinishedAt: s.FinishedAt,
+	}
+	if s.Clip != nil {
+		resp.ClipID = s.Clip.ClipID
+	}
+	return resp
+}
+
+// getClipJobHandler reports the state of a clip or trim job. Side-effect-free
+// — clients poll it until the job is done or failed.
+func (app *App) getClipJobHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	id := r.PathValue("id")
+	if !isValidID(id) {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	job := app.Clips.get(cs.Key, id)
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	writeJSON(w, clipJobResponse(job))
+}
@@ -0,0 +1,600 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"live-transcript-server/internal/config"
+	"live-transcript-server/internal/media"
+	"live-transcript-server/internal/metrics"
+	"live-transcript-server/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// postClipJob posts an m4a clip request for lines start-end of the channel's
+// seeded VOD stream.
+func postClipJob(t *testing.T, mux *http.ServeMux, channel string, start, end int) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"stream_id": "s1", "start": start, "end": end, "type": "m4a"})
+	req := httptest.NewRequest(http.MethodPost, "/"+channel+"/clip", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func getClipJob(t *testing.T, mux *http.ServeMux, channel, id string) (int, ClipJobResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+channel+"/clip/job/"+id, nil))
+	var job ClipJobResponse
+	if rr.Code == http.StatusOK {
+		json.NewDecoder(rr.Body).Decode(&job)
+	}
+	return rr.Code, job
+}
+
+func TestClipJobQueueLimits(t *testing.T) {
+	key := "test-clip-queue"
+	app, mux := setupTestApp(t, []string{key, "other"})
+	seedVodStream(t, app, key, "s1", "audio", 3, 3)
+	app.Clips = newClipQueue(1, 2)
+
+	started := make(chan struct{}, 4)
+	unblock := make(chan struct{})
+	app.Media = fakeProcessor{convert: func(in, out string) error {
+		started <- struct{}{}
+		<-unblock
+		return writePlaceholder(out)
+	}}
+
+	first := postClipJob(t, mux, key, 0, 1)
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("first clip: expected 202, got %d: %s", first.Code, first.Body.String())
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first clip job never started")
+	}
+
+	second := postClipJob(t, mux, key, 1, 2)
+	if second.Code != http.StatusAccepted {
+		t.Fatalf("second clip: expected 202, got %d: %s", second.Code, second.Body.String())
+	}
+	var queued ClipJobResponse
+	json.Unmarshal(second.Body.Bytes(), &queued)
+	if code, job := getClipJob(t, mux, key, queued.JobID); code != http.StatusOK || job.State != clipJobStateQueued {
+		t.Errorf("second job: expected queued behind the first, got %d %+v", code, job)
+	}
+	if got := testutil.ToFloat64(metrics.ClipJobsQueued.WithLabelValues(key)); got != 1 {
+		t.Errorf("queued gauge = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.ClipJobsRunning.WithLabelValues(key)); got != 1 {
+		t.Errorf("running gauge = %v, want 1", got)
+	}
+
+	// Two jobs pending is the limit: a third is turned away.
+	if rr := postClipJob(t, mux, key, 0, 2); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("third clip: expected 503, got %d", rr.Code)
+	}
+	if got := testutil.ToFloat64(metrics.ClipJobsRejected.WithLabelValues(key)); got != 1 {
+		t.Errorf("rejected counter = %v, want 1", got)
+	}
+
+	// Jobs are scoped to their channel.
+	if code, _ := getClipJob(t, mux, "other", queued.JobID); code != http.StatusNotFound {
+		t.Errorf("job polled on another channel: expected 404, got %d", code)
+	}
+	if code, _ := getClipJob(t, mux, key, "missing"); code != http.StatusNotFound {
+		t.Errorf("unknown job: expected 404, got %d", code)
+	}
+
+	close(unblock)
+	for _, rr := range []*httptest.ResponseRecorder{first, second} {
+		if job := waitClipJob(t, mux, key, rr); job.State != clipJobStateDone || job.Clip == nil {
+			t.Errorf("expected job done with a clip, got %+v", job)
+		}
+	}
+}
+
+func TestClipJobFailure(t *testing.T) {
+	key := "test-clip-job-failure"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "audio", 2, 2)
+	app.Media = fakeProcessor{convert: func(in, out string) error { return errors.New("ffmpeg exploded at /tmp/secret") }}
+
+	job := waitClipJob(t, mux, key, postClipJob(t, mux, key, 0, 1))
+	if job.State != clipJobStateFailed || job.Error != "unable to convert media" || job.Clip != nil {
+		t.Errorf("expected a failed job with a generic error, got %+v", job)
+	}
+	if clips, err := app.Store.ListClips(t.Context(), key, "s1"); err != nil || len(clips) != 0 {
+		t.Errorf("expected no catalogued clip after a failed job, got %+v (err %v)", clips, err)
+	}
+}
+
+func TestClipJobDeduplication(t *testing.T) {
+	key := "test-clip-dedupe"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "audio", 2, 2)
+
+	var builds atomic.Int32
+	unblock := make(chan struct{})
+	app.Media = fakeProcessor{convert: func(in, out string) error {
+		builds.Add(1)
+		<-unblock
+		return writePlaceholder(out)
+	}}
+
+	// Concurrent identical requests collapse into one job.
+	first := postClipJob(t, mux, key, 0, 1)
+	second := postClipJob(t, mux, key, 0, 1)
+	var a, b ClipJobResponse
+	json.Unmarshal(first.Body.Bytes(), &a)
+	json.Unmarshal(second.Body.Bytes(), &b)
+	if second.Code != http.StatusAccepted || a.JobID == "" || b.JobID != a.JobID {
+		t.Fatalf("identical request: expected 202 joining job %q, got %d %+v", a.JobID, second.Code, b)
+	}
+	close(unblock)
+	done := waitClipJob(t, mux, key, first)
+	if done.State != clipJobStateDone {
+		t.Fatalf("expected job done, got %+v", done)
+	}
+
+	// A repeat after the build is answered at once with the same clip.
+	rr := postClipJob(t, mux, key, 0, 1)
+	var again ClipJobResponse
+	json.Unmarshal(rr.Body.Bytes(), &again)
+	if rr.Code != http.StatusOK || again.State != clipJobStateDone || again.ClipID != done.ClipID {
+		t.Errorf("repeat request: expected 200 with clip %q, got %d %+v", done.ClipID, rr.Code, again)
+	}
+
+	// The catalog answers once the in-memory index no longer knows the clip
+	// (e.g. after a restart).
+	app.Clips = newClipQueue(0, 0)
+	rr = postClipJob(t, mux, key, 0, 1)
+	json.Unmarshal(rr.Body.Bytes(), &again)
+	if rr.Code != http.StatusOK || again.ClipID != done.ClipID {
+		t.Errorf("request after restart: expected 200 with clip %q, got %d %+v", done.ClipID, rr.Code, again)
+	}
+	if code, job := getClipJob(t, mux, key, again.JobID); code != http.StatusOK || job.ClipID != done.ClipID {
+		t.Errorf("reused clip's job: expected pollable with clip %q, got %d %+v", done.ClipID, code, job)
+	}
+	if got := builds.Load(); got != 1 {
+		t.Errorf("expected one build, got %d", got)
+	}
+
+	// A different format is a different clip.
+	body, _ := json.Marshal(map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "mp3"})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+"/clip", bytes.NewReader(body)))
+	if job := waitClipJob(t, mux, key, rr); job.ClipID == done.ClipID {
+		t.Errorf("mp3 request reused the m4a clip %q", done.ClipID)
+	}
+
+	// Once the stream is removed, its clips are no longer reused.
+	app.Clips.forgetStream(key, "s1")
+	if job := app.Clips.lookup(key, clipRequestKey("s1", 0, 1, "m4a", false, "")); job != nil {
+		t.Errorf("expected the index entry to be dropped with the stream, got job %s", job.id)
+	}
+}
+
+func TestClipJobDeduplicationByProfile(t *testing.T) {
+	key := "test-clip-dedupe-profile"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "audio", 2, 2)
+
+	var builds atomic.Int32
+	unblock := make(chan struct{})
+	app.Media = fakeProcessor{convert: func(in, out string) error {
+		builds.Add(1)
+		<-unblock
+		return writePlaceholder(out)
+	}}
+
+	// The same lines in the same format, but encoded with another clip
+	// profile, are another clip.
+	first := postClipJob(t, mux, key, 0, 1)
+	app.Channels[key].ClipProfile = media.AudioProfile{Codec: media.CodecAAC, BitrateKbps: 64}
+	second := postClipJob(t, mux, key, 0, 1)
+	var a, b ClipJobResponse
+	json.Unmarshal(first.Body.Bytes(), &a)
+	json.Unmarshal(second.Body.Bytes(), &b)
+	if a.JobID == "" || b.JobID == "" || b.JobID == a.JobID {
+		t.Fatalf("expected two jobs, got %q and %q", a.JobID, b.JobID)
+	}
+	close(unblock)
+	done := waitClipJob(t, mux, key, first)
+	other := waitClipJob(t, mux, key, second)
+	if done.ClipID == "" || other.ClipID == done.ClipID {
+		t.Errorf("expected two clips, got %+v and %+v", done, other)
+	}
+	if got := builds.Load(); got != 2 {
+		t.Errorf("expected two builds, got %d", got)
+	}
+
+	// The catalog keeps them apart too.
+	app.Clips = newClipQueue(0, 0)
+	rr := postClipJob(t, mux, key, 0, 1)
+	var again ClipJobResponse
+	json.Unmarshal(rr.Body.Bytes(), &again)
+	if rr.Code != http.StatusOK || again.ClipID != other.ClipID {
+		t.Errorf("request after restart: expected 200 with clip %q, got %d %+v", other.ClipID, rr.Code, again)
+	}
+}
+
+func TestClipJobByTime(t *testing.T) {
+	key := "test-clip-by-time"
+	app, mux := setupTestApp(t, []string{key})
+	// Lines start every 10 seconds, at 0, 10, 20 and 30.
+	seedVodStream(t, app, key, "s1", "audio", 4, 4)
+
+	type cut struct {
+		from, to  float64
+		keepVideo bool
+	}
+	cuts := make(chan cut, 4)
+	app.Media = fakeProcessor{
+		// The first chunk is a second short of the next line's timestamp.
+		duration: func(in string) (float64, error) {
+			if strings.HasSuffix(in, "_file1.raw") {
+				return 9, nil
+			}
+			return 10, nil
+		},
+		cut: func(in, out string, from, to float64, keepVideo bool) error {
+			cuts <- cut{from, to, keepVideo}
+			return writePlaceholder(out)
+		},
+	}
+
+	post := func(body map[string]any) *httptest.ResponseRecorder {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+"/clip", bytes.NewReader(b)))
+		return rr
+	}
+
+	job := waitClipJob(t, mux, key, post(map[string]any{"stream_id": "s1", "start_time": 12.5, "end_time": 24.25, "type": "m4a"}))
+	if job.State != clipJobStateDone || job.Clip == nil {
+		t.Fatalf("expected a done job with a clip, got %+v", job)
+	}
+	c := job.Clip
+	if c.StartLine != 1 || c.EndLine != 2 || c.StartTime == nil || *c.StartTime != 12.5 || c.EndTime == nil || *c.EndTime != 24.25 {
+		t.Errorf("expected lines 1-2 cut at 12.5-24.25, got %+v", c)
+	}
+	// Lines 1 and 2 are merged; each cut point is placed within its own chunk.
+	if got := <-cuts; got != (cut{from: 2.5, to: 13.25}) {
+		t.Errorf("cut = %+v, want 2.5-13.25 without video", got)
+	}
+
+	// The same span again is the same clip.
+	rr := post(map[string]any{"stream_id": "s1", "start_time": 12.5, "end_time": 24.25, "type": "m4a"})
+	var again ClipJobResponse
+	json.Unmarshal(rr.Body.Bytes(), &again)
+	if rr.Code != http.StatusOK || again.ClipID != c.ClipID {
+		t.Errorf("repeat request: expected 200 with clip %q, got %d %+v", c.ClipID, rr.Code, again)
+	}
+	// Whole lines 1-2 are a different clip from a span within them.
+	rr = post(map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "m4a"})
+	if job := waitClipJob(t, mux, key, rr); job.ClipID == c.ClipID {
+		t.Errorf("line clip reused the time clip %q", c.ClipID)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"only start_time":  {"stream_id": "s1", "start_time": 1.0, "type": "m4a"},
+		"end before start": {"stream_id": "s1", "start_time": 5.0, "end_time": 5.0, "type": "m4a"},
+		"negative start":   {"stream_id": "s1", "start_time": -1.0, "end_time": 5.0, "type": "m4a"},
+		"unknown stream":   {"stream_id": "s2", "start_time": 1.0, "end_time": 5.0, "type": "m4a"},
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+
+	// A span past the end of the media fails the job.
+	job = waitClipJob(t, mux, key, post(map[string]any{"stream_id": "s1", "start_time": 100.0, "end_time": 110.0, "type": "m4a"}))
+	if job.State != clipJobStateFailed {
+		t.Errorf("span past the media: expected a failed job, got %+v", job)
+	}
+}
+
+func TestClipJobSubtitles(t *testing.T) {
+	key := "test-clip-subtitles"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "video", 3, 3)
+	lines := []model.Line{
+		{ID: 0, Timestamp: 0, FileID: "file0", MediaAvailable: true, Segments: json.RawMessage(`[{"timestamp":0,"text":"hello"},{"timestamp":4,"text":"there"}]`)},
+		{ID: 1, Timestamp: 10, FileID: "file1", MediaAvailable: true, Segments: json.RawMessage(`[{"timestamp":10,"text":"general"}]`)},
+		{ID: 2, Timestamp: 20, FileID: "file2", MediaAvailable: true, Segments: json.RawMessage(`[{"timestamp":20,"text":"kenobi"}]`)},
+	}
+	seedTranscript(t, app, key, "s1", lines)
+
+	srts := make(chan string, 2)
+	capture := func(kind string) func(in, subtitles, out string) error {
+		return func(in, subtitles, out string) error {
+			b, err := os.ReadFile(subtitles)
+			if err != nil {
+				return err
+			}
+			srts <- kind + ":" + string(b)
+			return writePlaceholder(out)
+		}
+	}
+	app.Media = fakeProcessor{burn: capture("burn"), embed: capture("embed")}
+
+	post := func(body map[string]any) *httptest.ResponseRecorder {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+"/clip", bytes.NewReader(b)))
+		return rr
+	}
+
+	// A line clip's cues end where the next line starts.
+	job := waitClipJob(t, mux, key, post(map[string]any{"stream_id": "s1", "start": 1, "end": 1, "type": "mp4", "subtitles": "burn"}))
+	if job.State != clipJobStateDone || job.Clip == nil || job.Clip.Subtitles != "burn" {
+		t.Fatalf("burn: expected a done job with a burned clip, got %+v", job)
+	}
+	if got, want := <-srts, "burn:1\n00:00:00,000 --> 00:00:10,000\ngeneral\n\n"; got != want {
+		t.Errorf("burn: subtitles = %q, want %q", got, want)
+	}
+	burned := job.Clip.ClipID
+
+	// A time clip's cues are offset to, and clamped by, its span.
+	job = waitClipJob(t, mux, key, post(map[string]any{"stream_id": "s1", "start_time": 3.0, "end_time": 12.0, "type": "m4a", "subtitles": "embed"}))
+	if job.State != clipJobStateDone || job.Clip == nil || job.Clip.Subtitles != "embed" {
+		t.Fatalf("embed: expected a done job with an embedded clip, got %+v", job)
+	}
+	want := "embed:" +
+		"1\n00:00:00,000 --> 00:00:01,000\nhello\n\n" +
+		"2\n00:00:01,000 --> 00:00:07,000\nthere\n\n" +
+		"3\n00:00:07,000 --> 00:00:09,000\ngeneral\n\n"
+	if got := <-srts; got != want {
+		t.Errorf("embed: subtitles = %q, want %q", got, want)
+	}
+
+	// Cues follow the probed chunk durations the cut is placed with, not the
+	// line timestamps: with 8-second chunks, stream time 10 is 8 seconds into
+	// the merged media and the clip is 3-10.5 of it.
+	app.Media = fakeProcessor{burn: capture("burn"), embed: capture("embed"), duration: func(string) (float64, error) { return 8, nil }}
+	job = waitClipJob(t, mux, key, post(map[string]any{"stream_id": "s1", "start_time": 3.0, "end_time": 12.5, "type": "m4a", "subtitles": "embed"}))
+	if job.State != clipJobStateDone {
+		t.Fatalf("embed with short chunks: expected a done job, got %+v", job)
+	}
+	want = "embed:" +
+		"1\n00:00:00,000 --> 00:00:01,000\nhello\n\n" +
+		"2\n00:00:01,000 --> 00:00:05,000\nthere\n\n" +
+		"3\n00:00:05,000 --> 00:00:07,500\ngeneral\n\n"
+	if got := <-srts; got != want {
+		t.Errorf("embed with short chunks: subtitles = %q, want %q", got, want)
+	}
+
+	// A subtitled clip is made afresh every time, so an edit to its lines is
+	// never served from an older clip.
+	if _, err := app.Store.EditLine(context.Background(), key, "s1", 1, json.RawMessage(`[{"timestamp":10,"text":"General"}]`)); err != nil {
+		t.Fatalf("EditLine failed: %v", err)
+	}
+	again := waitClipJob(t, mux, key, post(map[string]any{"stream_id": "s1", "start": 1, "end": 1, "type": "mp4", "subtitles": "burn"}))
+	if again.State != clipJobStateDone || again.Clip == nil || again.Clip.ClipID == burned {
+		t.Fatalf("burn again: expected a new clip, got %+v", again)
+	}
+	if got, want := <-srts, "burn:1\n00:00:00,000 --> 00:00:10,000\nGeneral\n\n"; got != want {
+		t.Errorf("burn again: subtitles = %q, want %q", got, want)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"burn into audio": {"stream_id": "s1", "start": 0, "end": 1, "type": "m4a", "subtitles": "burn"},
+		"embed into mp3":  {"stream_id": "s1", "start": 0, "end": 1, "type": "mp3", "subtitles": "embed"},
+		"unknown option":  {"stream_id": "s1", "start": 0, "end": 1, "type": "mp4", "subtitles": "karaoke"},
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestClipJobAnimated(t *testing.T) {
+	key := "test-clip-animated"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "video", 3, 3)
+	seedVodStream(t, app, key, "s2", "audio", 3, 3)
+	app.AnimatedClips = animatedClipDefaults(config.AnimatedClipsConfig{GIF: config.AnimatedClipConfig{MaxWidth: 320}})
+
+	type animation struct {
+		in, out       string
+		maxWidth, fps int
+	}
+	animations := make(chan animation, 4)
+	app.Media = fakeProcessor{animate: func(in, out string, maxWidth, fps int) error {
+		animations <- animation{filepath.Ext(in), filepath.Ext(out), maxWidth, fps}
+		return writePlaceholder(out)
+	}}
+
+	post := func(path string, body map[string]any) *httptest.ResponseRecorder {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+path, bytes.NewReader(b)))
+		return rr
+	}
+
+	// An animated clip is rendered from the mp4 clip, sized per the config.
+	job := waitClipJob(t, mux, key, post("/clip", map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "gif"}))
+	if job.State != clipJobStateDone || job.Clip == nil || job.Clip.Format != "gif" || job.Clip.HasSidecar {
+		t.Fatalf("gif: expected a done gif clip without a sidecar, got %+v", job)
+	}
+	if got, want := <-animations, (animation{".mp4", ".gif", 320, defaultGIFFPS}); got != want {
+		t.Errorf("gif: animated %+v, want %+v", got, want)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+key+"/stream/s1/clips/"+job.ClipID+".gif", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/gif" {
+		t.Errorf("gif: stream: expected 200 image/gif, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	// A trimmed mp4 clip can be rendered as webm.
+	source := waitClipJob(t, mux, key, post("/clip", map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "mp4"})).ClipID
+	job = waitClipJob(t, mux, key, post("/trim", map[string]any{"stream_id": "s1", "clip_id": source, "file_format": "mp4", "type": "webm", "start": 0.5, "end": 1.5}))
+	if job.State != clipJobStateDone || job.Clip == nil || job.Clip.Format != "webm" || job.Clip.SourceClipID != source {
+		t.Fatalf("webm trim: expected a done webm clip of %s, got %+v", source, job)
+	}
+	if got, want := <-animations, (animation{".mp4", ".webm", defaultWebMMaxWidth, defaultWebMFPS}); got != want {
+		t.Errorf("webm trim: animated %+v, want %+v", got, want)
+	}
+
+	if rr := post("/clip", map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "gif", "subtitles": "embed"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("embed into gif: expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := post("/clip", map[string]any{"stream_id": "s2", "start": 0, "end": 1, "type": "webm"}); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("webm of an audio stream: expected 405, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := post("/trim", map[string]any{"stream_id": "s1", "clip_id": source, "file_format": "m4a", "type": "gif", "start": 0.5, "end": 1.5}); rr.Code != http.StatusBadRequest {
+		t.Errorf("gif trim of an audio clip: expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestClipJobNormalize(t *testing.T) {
+	key := "test-clip-normalize"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "video", 3, 3)
+
+	normalized := make(chan string, 8)
+	app.Media = fakeProcessor{encode: func(out string, profile media.AudioProfile) {
+		if profile.Normalize {
+			normalized <- filepath.Ext(out)
+		}
+	}}
+	drain := func() []string {
+		var exts []string
+		for {
+			select {
+			case ext := <-normalized:
+				exts = append(exts, ext)
+			default:
+				return exts
+			}
+		}
+	}
+
+	post := func(path string, body map[string]any) ClipJobResponse {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+path, bytes.NewReader(b)))
+		job := waitClipJob(t, mux, key, rr)
+		if job.State != clipJobStateDone || job.Clip == nil {
+			t.Fatalf("%s %v: expected a done job, got %+v", path, body, job)
+		}
+		return job
+	}
+
+	// An mp4 clip's sidecar is normalised along with it.
+	job := post("/clip", map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "mp4", "normalize": true})
+	if !job.Clip.Normalized || !job.Clip.HasSidecar {
+		t.Errorf("normalize: expected a normalised clip with a sidecar, got %+v", job.Clip)
+	}
+	if got := drain(); !slices.Equal(got, []string{".mp4", ".m4a"}) {
+		t.Errorf("normalize: normalised %v, want the mp4 and its sidecar", got)
+	}
+
+	// The same lines without normalisation are a different clip.
+	plain := post("/clip", map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "mp4"})
+	if plain.ClipID == job.ClipID || plain.Clip.Normalized {
+		t.Errorf("plain: expected a new clip that is not normalised, got %+v", plain.Clip)
+	}
+	if got := drain(); len(got) != 0 {
+		t.Errorf("plain: normalised %v", got)
+	}
+
+	// The channel default applies unless the request overrides it, and a gif
+	// has no audio to normalise.
+	app.Channels[key].NormalizeLoudness = true
+	if job := post("/clip", map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "m4a"}); !job.Clip.Normalized {
+		t.Errorf("channel default: expected a normalised clip, got %+v", job.Clip)
+	}
+	if job := post("/clip", map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "m4a", "normalize": false}); job.Clip.Normalized {
+		t.Errorf("override: expected a clip that is not normalised, got %+v", job.Clip)
+	}
+	if job := post("/clip", map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "gif"}); job.Clip.Normalized {
+		t.Errorf("gif: expected a clip that is not normalised, got %+v", job.Clip)
+	}
+	if got := drain(); !slices.Equal(got, []string{".m4a"}) {
+		t.Errorf("channel default: normalised %v, want only the m4a", got)
+	}
+
+	trim := post("/trim", map[string]any{"stream_id": "s1", "clip_id": plain.ClipID, "file_format": "mp4", "start": 0.5, "end": 1.5})
+	if !trim.Clip.Normalized {
+		t.Errorf("trim: expected a normalised trim, got %+v", trim.Clip)
+	}
+	if got := drain(); !slices.Equal(got, []string{".mp4"}) {
+		t.Errorf("trim: normalised %v, want the trimmed mp4", got)
+	}
+}
+
+func TestClipJobEncodingProfile(t *testing.T) {
+	key := "test-clip-profile"
+	app, mux := setupTestApp(t, []string{key})
+	seedVodStream(t, app, key, "s1", "video", 3, 3)
+
+	opus := media.AudioProfile{Codec: media.CodecOpus, BitrateKbps: 32}
+	app.Channels[key].ClipProfile = opus
+	type encoding struct {
+		ext     string
+		profile media.AudioProfile
+	}
+	encoded := make(chan encoding, 8)
+	app.Media = fakeProcessor{encode: func(out string, profile media.AudioProfile) {
+		encoded <- encoding{filepath.Ext(out), profile}
+	}}
+	post := func(path string, body map[string]any) ClipJobResponse {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+path, bytes.NewReader(b)))
+		job := waitClipJob(t, mux, key, rr)
+		if job.State != clipJobStateDone || job.Clip == nil {
+			t.Fatalf("%s %v: expected a done job, got %+v", path, body, job)
+		}
+		return job
+	}
+
+	// An audio clip without a type is made in the profile's format.
+	job := post("/clip", map[string]any{"stream_id": "s1", "start": 0, "end": 1})
+	if job.Clip.Format != "opus" {
+		t.Errorf("default type: format %q, want opus", job.Clip.Format)
+	}
+	if got, want := <-encoded, (encoding{".opus", opus}); got != want {
+		t.Errorf("default type: encoded %+v, want %+v", got, want)
+	}
+
+	// Other formats keep ffmpeg's defaults.
+	post("/clip", map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "mp3"})
+	if got, want := <-encoded, (encoding{".mp3", media.AudioProfile{}}); got != want {
+		t.Errorf("mp3: encoded %+v, want %+v", got, want)
+	}
+
+	// An opus clip can be trimmed, but has no subtitle track to embed into.
+	trim := post("/trim", map[string]any{"stream_id": "s1", "clip_id": job.ClipID, "file_format": "opus", "start": 0.5, "end": 1.5})
+	if trim.Clip.Format != "opus" {
+		t.Errorf("trim: format %q, want opus", trim.Clip.Format)
+	}
+	b, _ := json.Marshal(map[string]any{"stream_id": "s1", "start": 0, "end": 1, "type": "opus", "subtitles": "embed"})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/"+key+"/clip", bytes.NewReader(b)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("embed into opus: expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
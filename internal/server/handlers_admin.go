@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
@@ -523,6 +524,180 @@ func (app *App) postAdminStopHandler(w http.ResponseWriter, r *http.Request, cs
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// maxEditedSegments bounds an edited line. Workers send a handful of segments
+// per line; anything near this is not a correction.
+const maxEditedSegments = 100
+
+// editedSegment is the shape an admin-supplied segment must have. Only text is
+// required; other fields (timestamp, anything the worker adds) pass through.
+type editedSegment struct {
+	Text *string `json:"text"`
+}
+
+// postAdminLineHandler corrects a transcript line's segments and/or hides it.
+// Both fields are optional and only the ones present are changed. The edit is
+// stored apart from the worker's copy of the line, so the worker's next /sync
+// does not undo it, and clients get the line's new state as updatedLine.
+func (app *App) postAdminLineHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	streamID, lineID, ok := adminLinePath(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Segments json.RawMessage `json:"segments"`
+		Hidden   *bool           `json:"hidden"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	if body.Segments == nil && body.Hidden == nil {
+		http.Error(w, "No fields to update. Send at least one of segments, hidden.", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+
+	var segments json.RawMessage
+	if body.Segments != nil {
+		var segs []editedSegment
+		if err := json.Unmarshal(body.Segments, &segs); err != nil || len(segs) == 0 || len(segs) > maxEditedSegments {
+			http.Error(w, fmt.Sprintf("segments must be an array of 1 to %d segment objects.", maxEditedSegments), http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		for _, seg := range segs {
+			if seg.Text == nil {
+				http.Error(w, "every segment must have a text field.", http.StatusBadRequest)
+				metrics.Http400Errors.Inc()
+				return
+			}
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, body.Segments); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		segments = compact.Bytes()
+	}
+
+	line, err := app.Store.ApplyLineEdit(r.Context(), cs.Key, streamID, lineID, segments, body.Hidden)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "line not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		metrics.Http500Errors.Inc()
+		slog.Error("failed to edit line", "key", cs.Key, "func", "postAdminLineHandler", "streamID", streamID, "lineID", lineID, "err", err)
+		return
+	}
+
+	app.broadcastUpdatedLine(r.Context(), cs, streamID, *line)
+
+	app.bumpAdminChange(cs.Key)
+	app.notifyAdminAction(r, cs, "Edited transcript line",
+		discord.AdminField{Name: "Stream ID", Value: streamID, Inline: true},
+		discord.AdminField{Name: "Line ID", Value: strconv.Itoa(lineID), Inline: true},
+		discord.AdminField{Name: "Text Corrected", Value: yesNo(segments != nil), Inline: true},
+		discord.AdminField{Name: "Hidden", Value: yesNo(line.Hidden), Inline: true},
+	)
+	slog.Info("admin edited transcript line", "key", cs.Key, "func", "postAdminLineHandler", "streamID", streamID, "lineID", lineID, "textCorrected", segments != nil, "hidden", line.Hidden)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminLinePath reads the {streamID}/{lineID} of an admin line route,
+// answering 400 itself when either is invalid.
+func adminLinePath(w http.ResponseWriter, r *http.Request) (streamID string, lineID int, ok bool) {
+	streamID = r.PathValue("streamID")
+	if !isValidID(streamID) {
+		http.Error(w, "invalid stream id", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return "", 0, false
+	}
+	lineID, err := strconv.Atoi(r.PathValue("lineID"))
+	if err != nil || lineID < 0 {
+		http.Error(w, "invalid line id", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return "", 0, false
+	}
+	return streamID, lineID, true
+}
+
+// getAdminLineHistoryHandler lists every recorded change to a line, oldest
+// first, with its source and the segments before and after. Side-effect-free.
+func (app *App) getAdminLineHistoryHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	streamID, lineID, ok := adminLinePath(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.Store.GetLineHistory(r.Context(), cs.Key, streamID, lineID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "line not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		metrics.Http500Errors.Inc()
+		slog.Error("failed to get line history", "key", cs.Key, "func", "getAdminLineHistoryHandler", "streamID", streamID, "lineID", lineID, "err", err)
+		return
+	}
+	if revisions == nil {
+		revisions = []model.LineRevision{}
+	}
+	writeJSON(w, revisions)
+}
+
+// postAdminLineRevertHandler undoes one revision of a line: the line goes
+// back to the segments it had before that revision. Like any admin edit, the
+// revert survives the worker's next /sync and reaches clients as updatedLine.
+func (app *App) postAdminLineRevertHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	streamID, lineID, ok := adminLinePath(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		RevisionID *int64 `json:"revisionId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RevisionID == nil {
+		http.Error(w, "Invalid JSON body. Send the revisionId to undo.", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+
+	line, err := app.Store.RevertLine(r.Context(), cs.Key, streamID, lineID, *body.RevisionID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "line or revision not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrNoPriorRevision) {
+		http.Error(w, "That revision created the line; there is nothing before it to revert to.", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		metrics.Http500Errors.Inc()
+		slog.Error("failed to revert line", "key", cs.Key, "func", "postAdminLineRevertHandler", "streamID", streamID, "lineID", lineID, "revisionID", *body.RevisionID, "err", err)
+		return
+	}
+
+	app.broadcastUpdatedLine(r.Context(), cs, streamID, *line)
+
+	app.bumpAdminChange(cs.Key)
+	app.notifyAdminAction(r, cs, "Reverted transcript line",
+		discord.AdminField{Name: "Stream ID", Value: streamID, Inline: true},
+		discord.AdminField{Name: "Line ID", Value: strconv.Itoa(lineID), Inline: true},
+		discord.AdminField{Name: "Revision Undone", Value: strconv.FormatInt(*body.RevisionID, 10), Inline: true},
+	)
+	slog.Info("admin reverted transcript line", "key", cs.Key, "func", "postAdminLineRevertHandler", "streamID", streamID, "lineID", lineID, "revisionID", *body.RevisionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // getAdminMembershipHandler lists the membership keys for this channel by
 // proxying to the archive server. The archive-side channel name is taken from
 // config (cs.MembersName), never from the request, so a channel admin can only
@@ -3,16 +3,22 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"live-transcript-server/internal/config"
+	"live-transcript-server/internal/export"
 	"live-transcript-server/internal/media"
 	"live-transcript-server/internal/metrics"
 	"live-transcript-server/internal/model"
@@ -25,10 +31,13 @@ import (
 // mediaContentTypes maps the media file extensions the server serves to their
 // content types. Shared by the stream and download handlers.
 var mediaContentTypes = map[string]string{
-	".m4a": "audio/mp4",
-	".mp4": "video/mp4",
-	".mp3": "audio/mpeg",
-	".jpg": "image/jpeg",
+	".m4a":  "audio/mp4",
+	".opus": "audio/ogg",
+	".mp4":  "video/mp4",
+	".mp3":  "audio/mpeg",
+	".jpg":  "image/jpeg",
+	".gif":  "image/gif",
+	".webm": "video/webm",
 }
 
 // getStatusHandler is the public server + workers status endpoint.
@@ -103,10 +112,10 @@ func (app *App) streamHandler(w http.ResponseWriter, r *http.Request, cs *Channe
 	}
 
 	switch ext {
-	case ".m4a", ".mp3":
+	case ".m4a", ".opus", ".mp3":
 		metrics.TotalAudioPlayed.WithLabelValues(cs.Key).Inc()
 		metrics.StreamAudioPlayed.WithLabelValues(cs.Key).Inc()
-	case ".mp4":
+	case ".mp4", ".gif", ".webm":
 		metrics.TotalVideoPlayed.WithLabelValues(cs.Key).Inc()
 		metrics.StreamVideoPlayed.WithLabelValues(cs.Key).Inc()
 	}
@@ -221,11 +230,7 @@ func (app *App) downloadHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 		return
 	}
 
-	// Optional name query param overrides the download filename.
-	downloadFilename := requestedStreamID + "_" + filename
-	if queryName := r.URL.Query().Get("name"); queryName != "" {
-		downloadFilename = sanitize.BaseName(queryName) + ext
-	}
+	downloadFilename := attachmentName(r, requestedStreamID+"_"+filename, ext)
 
 	filePath := filepath.Join(cs.BaseMediaFolder, requestedStreamID, mediaType, idStr+ext)
 	if _, err := os.Stat(filePath); err != nil {
@@ -243,7 +248,60 @@ func (app *App) downloadHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 	http.ServeFile(w, r, filePath)
 }
 
-// getTranscriptHandler returns the full transcript for a stream as JSON.
+// attachmentName picks the filename for a download: the sanitized "name"
+// query param plus ext when one is given, otherwise fallback (which already
+// carries its extension).
+func attachmentName(r *http.Request, fallback string, ext string) string {
+	if queryName := r.URL.Query().Get("name"); queryName != "" {
+		return sanitize.BaseName(queryName) + ext
+	}
+	return fallback
+}
+
+// Transcript export formats, selected by the format query param or the
+// Accept header.
+const (
+	transcriptFormatJSON = "json"
+	transcriptFormatSRT  = "srt"
+	transcriptFormatVTT  = "vtt"
+	transcriptFormatText = "txt"
+)
+
+// transcriptContentTypes maps each transcript export format to its content
+// type. The format name doubles as the download's file extension.
+var transcriptContentTypes = map[string]string{
+	transcriptFormatJSON: "application/json",
+	transcriptFormatSRT:  "application/x-subrip",
+	transcriptFormatVTT:  "text/vtt",
+	transcriptFormatText: "text/plain",
+}
+
+// transcriptFormat resolves the export format for a transcript request. An
+// explicit format param wins; explicit is true in that case, and ok is false
+// if the param names an unknown format. Otherwise the first Accept entry
+// naming a known content type is used, falling back to JSON.
+func transcriptFormat(r *http.Request) (format string, explicit bool, ok bool) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		_, ok := transcriptContentTypes[f]
+		return f, true, ok
+	}
+	for entry := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(entry, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		for f, ct := range transcriptContentTypes {
+			if mediaType == ct {
+				return f, false, true
+			}
+		}
+	}
+	return transcriptFormatJSON, false, true
+}
+
+// getTranscriptHandler returns the full transcript for a stream. JSON is the
+// default; SRT, WebVTT and plain text are available through the format query
+// param (json|srt|vtt|txt) or the Accept header. Exports are served as
+// attachments named after the stream title, or the "name" query param. The
+// after query param limits the response to lines after the given line ID.
 func (app *App) getTranscriptHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
 	streamID := r.PathValue("streamID")
 	if !isValidID(streamID) {
@@ -252,8 +310,39 @@ func (app *App) getTranscriptHandler(w http.ResponseWriter, r *http.Request, cs
 		return
 	}
 
+	format, explicit, ok := transcriptFormat(r)
+	if !ok {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+
+	// after=<lineId> asks for only the lines following one the client already
+	// has, so a reconnecting client can fill a gap without refetching the
+	// whole transcript. -1 means "from the start".
+	afterID := -1
+	if afterStr := r.URL.Query().Get("after"); afterStr != "" {
+		parsed, err := strconv.Atoi(afterStr)
+		if err != nil || parsed < -1 {
+			http.Error(w, "Invalid after line ID", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		afterID = parsed
+	}
+
 	dbFetchStart := time.Now()
-	lines, err := app.Store.GetTranscript(r.Context(), cs.Key, streamID)
+	var lines []model.Line
+	var err error
+	if afterID >= 0 {
+		lines, err = app.Store.GetTranscriptAfter(r.Context(), cs.Key, streamID, afterID)
+		if lines == nil {
+			// A client that is already caught up gets an empty list, not null.
+			lines = []model.Line{}
+		}
+	} else {
+		lines, err = app.Store.GetTranscript(r.Context(), cs.Key, streamID)
+	}
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		app.report500(r, err, "failed to get transcript", "key", cs.Key, "streamID", streamID)
@@ -266,9 +355,106 @@ func (app *App) getTranscriptHandler(w http.ResponseWriter, r *http.Request, cs
 	metrics.TranscriptFetchLines.WithLabelValues(cs.Key).Observe(float64(len(lines)))
 	app.observePastStreamAge(r.Context(), cs, streamID)
 
-	jsonEncodeStart := time.Now()
-	writeJSON(w, lines)
-	metrics.RequestProcessingDuration.WithLabelValues("getTranscriptHandler", "json_encode", cs.Key).Observe(time.Since(jsonEncodeStart).Seconds())
+	// The plain JSON fetch is what the frontend makes on every past-stream
+	// view; it stays an inline response with no stream lookup.
+	if format == transcriptFormatJSON && !explicit {
+		jsonEncodeStart := time.Now()
+		writeJSON(w, lines)
+		metrics.RequestProcessingDuration.WithLabelValues("getTranscriptHandler", "json_encode", cs.Key).Observe(time.Since(jsonEncodeStart).Seconds())
+		return
+	}
+
+	stream, err := app.Store.GetStreamByID(r.Context(), cs.Key, streamID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get stream", "key", cs.Key, "func", "getTranscriptHandler", "streamID", streamID)
+		return
+	}
+	baseName, startTime := streamID, ""
+	if stream != nil {
+		baseName, startTime = vodDownloadName(stream), stream.StartTime
+	}
+
+	ext := "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", attachmentName(r, baseName+ext, ext)))
+	w.Header().Set("Content-Type", transcriptContentTypes[format]+"; charset=utf-8")
+
+	exportStart := time.Now()
+	origin := export.Origin(startTime, lines)
+	switch format {
+	case transcriptFormatSRT:
+		err = export.WriteSRT(w, export.Cues(lines, origin))
+	case transcriptFormatVTT:
+		err = export.WriteVTT(w, export.Cues(lines, origin))
+	case transcriptFormatText:
+		err = export.WriteText(w, lines, origin)
+	default:
+		err = json.NewEncoder(w).Encode(lines)
+	}
+	if err != nil {
+		slog.Warn("failed to write transcript export", "key", cs.Key, "func", "getTranscriptHandler", "streamID", streamID, "format", format, "err", err)
+	}
+	metrics.RequestProcessingDuration.WithLabelValues("getTranscriptHandler", "export_"+format, cs.Key).Observe(time.Since(exportStart).Seconds())
+}
+
+// Bounds for the number of hits GET /{channel}/search returns.
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// maxSearchQueryLength bounds the search text. Every word becomes a phrase the
+// index has to intersect, so an unbounded query is an easy way to burn CPU.
+const maxSearchQueryLength = 200
+
+// SearchResponse is returned by GET /{channel}/search.
+type SearchResponse struct {
+	Query   string            `json:"query"`
+	Results []model.SearchHit `json:"results"`
+}
+
+// searchHandler runs a full-text search over every stored transcript of the
+// channel. q is matched word by word (every word must appear in the line, in
+// any order); limit caps the number of hits, newest stream first.
+func (app *App) searchHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		http.Error(w, "Missing required parameter: q", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		http.Error(w, fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength), http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+
+	dbSearchStart := time.Now()
+	hits, err := app.Store.SearchTranscripts(r.Context(), cs.Key, q, limit)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to search transcripts", "key", cs.Key, "func", "searchHandler")
+		return
+	}
+	metrics.RequestProcessingDuration.WithLabelValues("searchHandler", "db_search", cs.Key).Observe(time.Since(dbSearchStart).Seconds())
+	metrics.TotalSearches.WithLabelValues(cs.Key).Inc()
+
+	if hits == nil {
+		hits = []model.SearchHit{}
+	}
+	writeJSON(w, SearchResponse{Query: q, Results: hits})
 }
 
 // observePastStreamAge records how old a stream was when its transcript was
@@ -298,19 +484,52 @@ func (app *App) observePastStreamAge(ctx context.Context, cs *ChannelState, stre
 	metrics.PastStreamFetchAge.WithLabelValues(cs.Key).Observe(age.Seconds())
 }
 
-// postClipHandler merges a range of lines' raw media into a single clip,
-// converts it to the requested format, and uploads it to storage.
+// postClipHandler queues a clip job: a range of lines' raw media merged into
+// a single clip, converted to the requested format, uploaded to storage and
+// catalogued. The request is validated up front; the work runs on the clip
+// queue and the response is 202 with the job to poll (see clipjobs.go). An
+// identical clip that already exists or is being made is reused instead, even
+// if the requested title differs; a subtitled clip never is, as its lines'
+// text may have changed since.
+//
+// Instead of start/end line IDs a request may give start_time/end_time, in
+// seconds into the stream. The lines covering that span are merged as usual
+// and the clip is cut to exactly the span in the same ffmpeg pass that
+// converts it (see resolveClipSpan).
+//
+// type "gif" and "webm" (video streams only) render the clip as an animation,
+// sized per the animatedClips config: the mp4 clip is made first and then
+// converted by animateClip.
+//
+// subtitles adds the clipped lines' transcript to the clip: "burn" renders it
+// onto the video (mp4, gif or webm) and "embed" adds it as a mov_text
+// subtitle track (mp4 or m4a). Cue timing comes from the lines' segment
+// timestamps, offset to the clip's start; a clip cut by time places them by
+// its chunks' probed durations, as it places its cut (see clipCues).
+//
+// normalize overrides the channel's normalizeLoudness default for whether
+// the clip's audio is loudness-normalised.
+//
+// An audio clip with no type is made in the format of the channel's clip
+// encoding profile, and audio in that format is encoded per the profile.
 func (app *App) postClipHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
 	observe := func(step string, since time.Time) {
 		metrics.MediaProcessingDuration.WithLabelValues(step, cs.Key).Observe(time.Since(since).Seconds())
 	}
-	processStartTime := time.Now()
 
 	var req struct {
 		StreamID string `json:"stream_id"`
 		Start    int    `json:"start"`
 		End      int    `json:"end"`
 		Type     string `json:"type"`
+		Title    string `json:"title"`
+		// StartTime and EndTime select the clip by time instead of by
+		// Start and End.
+		StartTime *float64 `json:"start_time"`
+		EndTime   *float64 `json:"end_time"`
+		Subtitles string   `json:"subtitles"`
+		// Normalize overrides the channel's loudness normalisation default.
+		Normalize *bool `json:"normalize"`
 	}
 
 	decodeStart := time.Now()
@@ -326,6 +545,10 @@ func (app *App) postClipHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 		metrics.Http400Errors.Inc()
 		return
 	}
+	title, ok := clipTitle(w, req.Title)
+	if !ok {
+		return
+	}
 
 	stream, err := app.Store.GetStreamByID(r.Context(), cs.Key, req.StreamID)
 	if err != nil {
@@ -342,7 +565,9 @@ func (app *App) postClipHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 	end := req.End
 	reqMediaType := req.Type
 
-	clipExt := ".m4a"
+	// The job encodes with the profile its request key was made from.
+	profile := cs.ClipProfile
+	clipExt := profile.Ext()
 	switch reqMediaType {
 	case "mp4":
 		if mediaType != "video" {
@@ -352,10 +577,17 @@ func (app *App) postClipHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 			return
 		}
 		clipExt = ".mp4"
-	case "mp3":
-		clipExt = ".mp3"
-	case "m4a", "":
-		clipExt = ".m4a"
+	case "gif", "webm":
+		if mediaType != "video" {
+			http.Error(w, "Video clipping is disabled for this stream", http.StatusMethodNotAllowed)
+			metrics.Http400Errors.Inc()
+			slog.Warn("cannot clip animated format. Media type is not 'video'", "key", cs.Key, "func", "postClipHandler", "mediaType", mediaType, "type", reqMediaType)
+			return
+		}
+		clipExt = "." + reqMediaType
+	case "mp3", "m4a", "opus":
+		clipExt = "." + reqMediaType
+	case "":
 	default:
 		http.Error(w, "Invalid media type", http.StatusBadRequest)
 		metrics.Http400Errors.Inc()
@@ -363,14 +595,65 @@ func (app *App) postClipHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 		return
 	}
 
-	if start < 0 || end < start || end-start >= app.MaxClipSize {
-		slog.Warn("invalid start or end id", "key", cs.Key, "func", "postClipHandler", "start", start, "end", end, "requestedClipSize", 1+end-start, "maxClipSize", app.MaxClipSize)
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	switch req.Subtitles {
+	case "":
+	case clipSubtitlesBurn:
+		if clipExt != ".mp4" && !isAnimatedExt(clipExt) {
+			http.Error(w, "Subtitles can only be burned into video clips", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+	case clipSubtitlesEmbed:
+		if clipExt != ".m4a" && clipExt != ".mp4" {
+			http.Error(w, "Subtitles can only be embedded in m4a and mp4 clips", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+	default:
+		http.Error(w, "Invalid subtitles option", http.StatusBadRequest)
 		metrics.Http400Errors.Inc()
 		return
 	}
 
-	uniqueID := shortuuid.New()
+	// A gif has no audio to normalise.
+	normalize := wantsNormalize(cs, req.Normalize) && clipExt != ".gif"
+	profile.Normalize = normalize
+
+	format := strings.TrimPrefix(clipExt, ".")
+	encoding := app.clipEncoding(profile, clipExt)
+	var span *clipSpan
+	var requestKey string
+	var findExisting func(ctx context.Context) (*model.Clip, error)
+	if req.StartTime != nil || req.EndTime != nil {
+		if span, ok = app.resolveClipSpan(w, r, cs, stream, req.StreamID, req.StartTime, req.EndTime); !ok {
+			return
+		}
+		start, end = span.startLine, span.endLine
+		requestKey = clipTimeRequestKey(req.StreamID, span.startTime, span.endTime, format, normalize, encoding)
+		findExisting = func(ctx context.Context) (*model.Clip, error) {
+			return app.Store.FindTimeClip(ctx, cs.Key, req.StreamID, span.startTime, span.endTime, format, normalize, encoding)
+		}
+	} else {
+		if start < 0 || end < start || end-start >= app.MaxClipSize {
+			slog.Warn("invalid start or end id", "key", cs.Key, "func", "postClipHandler", "start", start, "end", end, "requestedClipSize", 1+end-start, "maxClipSize", app.MaxClipSize)
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		requestKey = clipRequestKey(req.StreamID, start, end, format, normalize, encoding)
+		findExisting = func(ctx context.Context) (*model.Clip, error) {
+			return app.Store.FindClip(ctx, cs.Key, req.StreamID, start, end, format, normalize, encoding)
+		}
+	}
+
+	// Subtitles are the lines' text when the clip is made, which an admin
+	// edit or a resync can change since, so a subtitled clip is always made
+	// afresh.
+	if req.Subtitles != "" {
+		requestKey = ""
+	} else if app.reuseClip(w, r, cs, requestKey, findExisting) {
+		return
+	}
 
 	dbGetStart := time.Now()
 	fileIDs, err := app.Store.GetFileIDsInRange(r.Context(), cs.Key, req.StreamID, start, end)
@@ -389,92 +672,421 @@ func (app *App) postClipHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 		return
 	}
 
-	mergeAudioStart := time.Now()
-	mergedRawPath, err := media.MergeRawAudio(r.Context(), app.Storage, app.TempDir, cs.Key, req.StreamID, fileIDs, uniqueID)
-	if err != nil {
-		// MergeRawAudio cleans up its own partial output on error.
-		http.Error(w, "Server error", http.StatusInternalServerError)
-		app.report500(r, err, "unable to merge raw audio", "key", cs.Key, "func", "postClipHandler", "startID", start, "endID", end)
-		return
-	}
-	defer os.Remove(mergedRawPath)
-	observe("merge_audio", mergeAudioStart)
-
-	// Convert/remux to the requested container.
-	convertStart := time.Now()
-	tempMediaFile := filepath.Join(app.TempDir, uniqueID+clipExt)
-	sidecarFile := ""
-
-	// Note: audio has to be recoded to m4a otherwise it will be broken. Video
-	// can be remuxed to a different container without compatibility issues.
-	if reqMediaType == "mp4" {
-		err = app.Media.Remux(mergedRawPath, tempMediaFile)
-		if err == nil {
-			// Generate a sidecar m4a so clients on slow connections can use
-			// the audio to clip while the video is still loading.
-			sidecarFile = filepath.Join(app.TempDir, uniqueID+".m4a")
-			if err := app.Media.Convert(mergedRawPath, sidecarFile); err != nil {
-				slog.Error("failed to generate sidecar m4a", "key", cs.Key, "err", err)
-				// Don't fail the entire request. The mp4 is still good.
-				os.Remove(sidecarFile)
+	app.queueClipJob(w, cs, clipJobKindClip, requestKey, func(job *clipJob) (model.Clip, error) {
+		uniqueID := shortuuid.New()
+
+		job.setPhase(clipJobPhaseMerging)
+		mergeAudioStart := time.Now()
+		var mergedRawPath string
+		var durations []float64
+		var err error
+		if span != nil {
+			mergedRawPath, durations, err = media.MergeRawAudioTimed(app.ctx, app.Storage, app.Media, app.TempDir, cs.Key, req.StreamID, fileIDs, uniqueID)
+		} else {
+			mergedRawPath, err = media.MergeRawAudio(app.ctx, app.Storage, app.TempDir, cs.Key, req.StreamID, fileIDs, uniqueID)
+		}
+		if err != nil {
+			// MergeRawAudio cleans up its own partial output on error.
+			app.reportClipJobError(job, err, "unable to merge raw audio", "startID", start, "endID", end)
+			return model.Clip{}, errors.New("unable to merge media")
+		}
+		defer os.Remove(mergedRawPath)
+		observe("merge_audio", mergeAudioStart)
+
+		// A clip by time is cut while it is converted, so convert and remux
+		// become cuts of the span's position in the merged file. Either way
+		// the audio is normalised in the same pass.
+		convert := func(in, out string) error {
+			return app.Media.Convert(app.ctx, in, out, profile.For(filepath.Ext(out)))
+		}
+		remux := func(in, out string) error {
+			return app.Media.Remux(app.ctx, in, out, profile.For(filepath.Ext(out)))
+		}
+		if span != nil {
+			from := media.CutPosition(span.offsets, durations, span.startTime)
+			to := media.CutPosition(span.offsets, durations, span.endTime)
+			if to <= from {
+				slog.Warn("requested clip time has no media", "key", cs.Key, "func", "postClipHandler", "jobID", job.id, "startTime", span.startTime, "endTime", span.endTime, "durations", durations)
+				return model.Clip{}, errors.New("no media for the requested time")
+			}
+			convert = func(in, out string) error {
+				return app.Media.Cut(app.ctx, in, out, from, to, false, profile.For(filepath.Ext(out)))
+			}
+			remux = func(in, out string) error {
+				return app.Media.Cut(app.ctx, in, out, from, to, true, profile.For(filepath.Ext(out)))
+			}
+		}
+
+		// Convert/remux to the requested container. An animated clip is
+		// rendered from an mp4 clip, once any subtitles are burned into it.
+		job.setPhase(clipJobPhaseConverting)
+		convertStart := time.Now()
+		animated := isAnimatedExt(clipExt)
+		mediaExt := clipExt
+		if animated {
+			mediaExt = ".mp4"
+		}
+		tempMediaFile := filepath.Join(app.TempDir, uniqueID+mediaExt)
+		sidecarFile := ""
+
+		// Note: audio has to be recoded to m4a otherwise it will be broken. Video
+		// can be remuxed to a different container without compatibility issues.
+		if animated {
+			err = remux(mergedRawPath, tempMediaFile)
+		} else if reqMediaType == "mp4" {
+			err = remux(mergedRawPath, tempMediaFile)
+			if err == nil {
+				// Generate a sidecar m4a so clients on slow connections can use
+				// the audio to clip while the video is still loading.
+				sidecarFile = filepath.Join(app.TempDir, uniqueID+".m4a")
+				if err := convert(mergedRawPath, sidecarFile); err != nil {
+					slog.Error("failed to generate sidecar m4a", "key", cs.Key, "err", err)
+					// Don't fail the entire job. The mp4 is still good.
+					os.Remove(sidecarFile)
+					sidecarFile = ""
+				} else {
+					defer os.Remove(sidecarFile)
+				}
+			}
+		} else {
+			err = convert(mergedRawPath, tempMediaFile)
+		}
+		if err != nil {
+			os.Remove(tempMediaFile)
+			app.reportClipJobError(job, err, "unable to convert raw media to new extension", "extension", clipExt)
+			return model.Clip{}, errors.New("unable to convert media")
+		}
+		defer os.Remove(tempMediaFile)
+		observe("convert_remux", convertStart)
+
+		if req.Subtitles != "" {
+			job.setPhase(clipJobPhaseSubtitles)
+			subtitlesStart := time.Now()
+			cues, err := app.clipCues(app.ctx, cs, stream, req.StreamID, start, end, span, durations)
+			if err != nil {
+				app.reportClipJobError(job, err, "unable to load transcript for clip subtitles")
+				return model.Clip{}, errors.New("unable to add subtitles")
+			}
+			// A clip of lines without text has nothing to show.
+			if len(cues) > 0 {
+				subtitled, err := app.subtitleClip(uniqueID, mediaExt, tempMediaFile, req.Subtitles, cues)
+				if err != nil {
+					app.reportClipJobError(job, err, "unable to add clip subtitles", "subtitles", req.Subtitles)
+					return model.Clip{}, errors.New("unable to add subtitles")
+				}
+				defer os.Remove(subtitled)
+				tempMediaFile = subtitled
+			}
+			observe("subtitles", subtitlesStart)
+		}
+
+		if animated {
+			job.setPhase(clipJobPhaseAnimating)
+			animateStart := time.Now()
+			animatedFile, err := app.animateClip(uniqueID, clipExt, tempMediaFile)
+			if err != nil {
+				app.reportClipJobError(job, err, "unable to animate clip", "extension", clipExt)
+				return model.Clip{}, errors.New("unable to convert media")
+			}
+			defer os.Remove(animatedFile)
+			tempMediaFile = animatedFile
+			observe("animate", animateStart)
+		}
+
+		// Upload detached from shutdown so it never leaves a half-written
+		// clip in storage.
+		job.setPhase(clipJobPhaseUploading)
+		uploadCtx := context.WithoutCancel(app.ctx)
+
+		uploadClipStart := time.Now()
+		if err := app.uploadFile(uploadCtx, storage.ClipKey(cs.Key, req.StreamID, uniqueID, clipExt), tempMediaFile); err != nil {
+			slog.Error("failed to upload clip", "key", cs.Key, "jobID", job.id, "err", err)
+			return model.Clip{}, errors.New("unable to store clip")
+		}
+		observe("upload_clip", uploadClipStart)
+
+		if sidecarFile != "" {
+			uploadSidecarStart := time.Now()
+			if err := app.uploadFile(uploadCtx, storage.ClipKey(cs.Key, req.StreamID, uniqueID, ".m4a"), sidecarFile); err != nil {
+				slog.Error("failed to upload sidecar m4a", "key", cs.Key, "err", err)
 				sidecarFile = ""
-			} else {
-				defer os.Remove(sidecarFile)
 			}
+			observe("upload_sidecar", uploadSidecarStart)
+		}
+
+		switch clipExt {
+		case ".m4a", ".opus", ".mp3":
+			metrics.TotalAudioClipped.WithLabelValues(cs.Key).Inc()
+			metrics.StreamAudioClipped.WithLabelValues(cs.Key).Inc()
+		case ".mp4", ".gif", ".webm":
+			metrics.TotalVideoClipped.WithLabelValues(cs.Key).Inc()
+			metrics.StreamVideoClipped.WithLabelValues(cs.Key).Inc()
+		}
+
+		clip := model.Clip{
+			ClipID:     uniqueID,
+			StreamID:   req.StreamID,
+			StartLine:  start,
+			EndLine:    end,
+			Format:     format,
+			Title:      title,
+			SizeBytes:  fileSize(tempMediaFile),
+			HasSidecar: sidecarFile != "",
+			Subtitles:  req.Subtitles,
+			Normalized: normalize,
+			Encoding:   encoding,
+		}
+		if span != nil {
+			clip.StartTime = &span.startTime
+			clip.EndTime = &span.endTime
+		}
+		return clip, nil
+	})
+}
+
+// Subtitle options of a clip request.
+const (
+	clipSubtitlesBurn  = "burn"
+	clipSubtitlesEmbed = "embed"
+)
+
+// clipCues returns the subtitle cues of a clip of lines start-end, or of span
+// when the clip was cut by time, timed from the clip's start. A clip cut by
+// time is cut at positions placed with its chunks' probed durations (see
+// media.CutPosition), so its cues are placed the same way: where a chunk is
+// shorter or longer than the gap to the next line, the subtitles keep in step
+// with the media rather than with the line timestamps.
+func (app *App) clipCues(ctx context.Context, cs *ChannelState, stream *model.Stream, streamID string, start, end int, span *clipSpan, durations []float64) ([]export.Cue, error) {
+	// The line after the clip is loaded too: its start is where the last
+	// clipped line's media, and so its cues, end.
+	lines, err := app.Store.GetTranscriptRange(ctx, cs.Key, streamID, start, end+1)
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+	startTime := ""
+	if stream != nil {
+		startTime = stream.StartTime
+	}
+	origin := export.Origin(startTime, lines)
+	seconds := func(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+
+	from := seconds(float64(int64(lines[0].Timestamp) - origin))
+	to := time.Duration(math.MaxInt64)
+	if last := lines[len(lines)-1]; last.ID > end {
+		to = seconds(float64(int64(last.Timestamp) - origin))
+	}
+	if span == nil {
+		return export.Window(export.Cues(lines, origin), from, to), nil
+	}
+
+	position := func(d time.Duration) time.Duration {
+		return seconds(media.CutPosition(span.offsets, durations, d.Seconds()))
+	}
+	var cues []export.Cue
+	for _, c := range export.Cues(lines, origin) {
+		c.Start, c.End = position(c.Start), position(c.End)
+		// A cue spoken past the end of its chunk's media has nothing to show.
+		if c.End > c.Start {
+			cues = append(cues, c)
 		}
+	}
+	return export.Window(cues, position(seconds(span.startTime)), position(seconds(span.endTime))), nil
+}
+
+// subtitleClip writes cues as an SRT file and burns or embeds it (per
+// subtitles) into the clip at mediaPath. It returns the subtitled copy, which
+// the caller owns.
+func (app *App) subtitleClip(uniqueID, clipExt, mediaPath, subtitles string, cues []export.Cue) (string, error) {
+	srtPath := filepath.Join(app.TempDir, uniqueID+".srt")
+	f, err := os.Create(srtPath)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(srtPath)
+	err = export.WriteSRT(f, cues)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	out := filepath.Join(app.TempDir, uniqueID+"_subtitled"+clipExt)
+	if subtitles == clipSubtitlesBurn {
+		err = app.Media.BurnSubtitles(app.ctx, mediaPath, srtPath, out)
 	} else {
-		err = app.Media.Convert(mergedRawPath, tempMediaFile)
+		err = app.Media.EmbedSubtitles(app.ctx, mediaPath, srtPath, out)
 	}
 	if err != nil {
-		os.Remove(tempMediaFile)
-		http.Error(w, "Server error", http.StatusInternalServerError)
-		app.report500(r, err, "unable to convert raw media to new extension", "key", cs.Key, "func", "postClipHandler", "extension", clipExt)
-		return
+		os.Remove(out)
+		return "", err
 	}
-	defer os.Remove(tempMediaFile)
-	observe("convert_remux", convertStart)
+	return out, nil
+}
 
-	// Upload with a detached context so a client disconnect doesn't leave a
-	// half-written clip in storage.
-	uploadCtx := context.WithoutCancel(r.Context())
+// wantsNormalize resolves a request's normalize override against the
+// channel's NormalizeLoudness default.
+func wantsNormalize(cs *ChannelState, override *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return cs.NormalizeLoudness
+}
 
-	uploadClipStart := time.Now()
-	if err := app.uploadFile(uploadCtx, storage.ClipKey(cs.Key, req.StreamID, uniqueID, clipExt), tempMediaFile); err != nil {
-		slog.Error("failed to upload clip", "key", cs.Key, "err", err)
-		http.Error(w, "Storage Error", http.StatusInternalServerError)
-		return
+// isAnimatedExt reports whether ext is one of the animated clip formats,
+// which are rendered from a video clip by animateClip.
+func isAnimatedExt(ext string) bool {
+	return ext == ".gif" || ext == ".webm"
+}
+
+// Animated clip sizes used where the config leaves them zero.
+const (
+	defaultGIFMaxWidth  = 480
+	defaultGIFFPS       = 12
+	defaultWebMMaxWidth = 720
+	defaultWebMFPS      = 30
+)
+
+// animatedClipDefaults fills in the zero values of cfg.
+func animatedClipDefaults(cfg config.AnimatedClipsConfig) config.AnimatedClipsConfig {
+	fill := func(v *int, def int) {
+		if *v <= 0 {
+			*v = def
+		}
 	}
-	observe("upload_clip", uploadClipStart)
+	fill(&cfg.GIF.MaxWidth, defaultGIFMaxWidth)
+	fill(&cfg.GIF.FPS, defaultGIFFPS)
+	fill(&cfg.WebM.MaxWidth, defaultWebMMaxWidth)
+	fill(&cfg.WebM.FPS, defaultWebMFPS)
+	return cfg
+}
 
-	if sidecarFile != "" {
-		uploadSidecarStart := time.Now()
-		if err := app.uploadFile(uploadCtx, storage.ClipKey(cs.Key, req.StreamID, uniqueID, ".m4a"), sidecarFile); err != nil {
-			slog.Error("failed to upload sidecar m4a", "key", cs.Key, "err", err)
+// clipEncoding describes the encoder settings a clipExt clip is made with,
+// for telling apart clips that are otherwise identical: the clip profile's
+// settings for audio in that format (for an mp4, its m4a sidecar's), or the
+// size and frame rate of a gif or webm. It is empty when ffmpeg's defaults
+// apply.
+func (app *App) clipEncoding(profile media.AudioProfile, clipExt string) string {
+	if isAnimatedExt(clipExt) {
+		size := app.AnimatedClips.GIF
+		if clipExt == ".webm" {
+			size = app.AnimatedClips.WebM
 		}
-		observe("upload_sidecar", uploadSidecarStart)
+		return fmt.Sprintf("%dpx/%dfps", size.MaxWidth, size.FPS)
+	}
+	audioExt := clipExt
+	if clipExt == ".mp4" {
+		audioExt = ".m4a"
+	}
+	// Normalisation is the clip's normalized flag instead.
+	p := profile.For(audioExt)
+	p.Normalize = false
+	if p == (media.AudioProfile{}) {
+		return ""
 	}
+	return fmt.Sprintf("%s/%dk/%dHz/%dch", p.Codec, p.BitrateKbps, p.SampleRate, p.Channels)
+}
 
-	switch clipExt {
-	case ".m4a", ".mp3":
-		metrics.TotalAudioClipped.WithLabelValues(cs.Key).Inc()
-		metrics.StreamAudioClipped.WithLabelValues(cs.Key).Inc()
-	case ".mp4":
-		metrics.TotalVideoClipped.WithLabelValues(cs.Key).Inc()
-		metrics.StreamVideoClipped.WithLabelValues(cs.Key).Inc()
+// animateClip renders the video clip at mediaPath as a clipExt (".gif" or
+// ".webm") clip, sized per app.AnimatedClips. It returns the rendered file,
+// which the caller owns.
+func (app *App) animateClip(uniqueID, clipExt, mediaPath string) (string, error) {
+	size := app.AnimatedClips.GIF
+	if clipExt == ".webm" {
+		size = app.AnimatedClips.WebM
+	}
+	out := filepath.Join(app.TempDir, uniqueID+"_animated"+clipExt)
+	if err := app.Media.Animate(app.ctx, mediaPath, out, size.MaxWidth, size.FPS); err != nil {
+		os.Remove(out)
+		return "", err
 	}
+	return out, nil
+}
 
-	if time.Since(processStartTime).Seconds() > 10 {
-		slog.Warn("slow clip processing time", "key", cs.Key, "func", "postClipHandler", "processingTimeMs", time.Since(processStartTime).Milliseconds(), "start", start, "end", end, "mediaType", reqMediaType)
+// clipSpan is a clip request by time, resolved to the lines whose media
+// covers it.
+type clipSpan struct {
+	// startTime and endTime are the requested span, in seconds into the
+	// stream, rounded to milliseconds.
+	startTime, endTime float64
+	// startLine and endLine are the covering lines.
+	startLine, endLine int
+	// offsets holds where each covering line's chunk starts, in seconds into
+	// the stream, in line order.
+	offsets []float64
+}
+
+// resolveClipSpan validates a start_time/end_time pair and finds the lines
+// covering it: from the line whose chunk contains start_time through the last
+// line starting before end_time. Line timestamps are where their chunks
+// begin, measured from the same origin as transcript exports
+// (export.Origin). On failure it writes the error response and returns false.
+func (app *App) resolveClipSpan(w http.ResponseWriter, r *http.Request, cs *ChannelState, stream *model.Stream, streamID string, startTime, endTime *float64) (*clipSpan, bool) {
+	if startTime == nil || endTime == nil {
+		http.Error(w, "start_time and end_time must be given together", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return nil, false
+	}
+	span := &clipSpan{
+		startTime: math.Round(*startTime*1000) / 1000,
+		endTime:   math.Round(*endTime*1000) / 1000,
+	}
+	if span.startTime < 0 || span.endTime <= span.startTime {
+		slog.Warn("invalid start or end time", "key", cs.Key, "func", "resolveClipSpan", "startTime", *startTime, "endTime", *endTime)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return nil, false
 	}
 
-	writeJSON(w, map[string]string{
-		"status":  "success",
-		"clip_id": uniqueID,
-	})
+	lines, err := app.Store.GetLineTimings(r.Context(), cs.Key, streamID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get line timings for clip", "key", cs.Key, "func", "resolveClipSpan")
+		return nil, false
+	}
+	startTimeStr := ""
+	if stream != nil {
+		startTimeStr = stream.StartTime
+	}
+	origin := export.Origin(startTimeStr, lines)
+	offset := func(i int) float64 { return float64(int64(lines[i].Timestamp) - origin) }
+
+	// A span starting before the first line starts with it.
+	first, last := 0, -1
+	for i := range lines {
+		if offset(i) <= span.startTime {
+			first = i
+		}
+		if offset(i) < span.endTime {
+			last = i
+		}
+	}
+	if last < first {
+		http.Error(w, "Requested time is outside the stream", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return nil, false
+	}
+	if last-first >= app.MaxClipSize {
+		slog.Warn("requested clip time spans too many lines", "key", cs.Key, "func", "resolveClipSpan", "startTime", span.startTime, "endTime", span.endTime, "requestedClipSize", 1+last-first, "maxClipSize", app.MaxClipSize)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return nil, false
+	}
+
+	span.startLine, span.endLine = lines[first].ID, lines[last].ID
+	for i := first; i <= last; i++ {
+		span.offsets = append(span.offsets, offset(i))
+	}
+	return span, true
 }
 
-// postTrimHandler cuts an existing clip down to a sub-range and uploads the
-// result as a new clip.
+// postTrimHandler queues a trim job: an existing clip cut down to a sub-range
+// and uploaded (and catalogued) as a new clip. Like postClipHandler it answers
+// 202 with the job to poll.
+//
+// The trim keeps the source's file_format unless type asks for "gif" or
+// "webm", which an mp4 source can be rendered as after it is trimmed.
+// normalize works as for clips.
 func (app *App) postTrimHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
 	observe := func(step string, since time.Time) {
 		metrics.MediaProcessingDuration.WithLabelValues(step, cs.Key).Observe(time.Since(since).Seconds())
@@ -486,6 +1098,10 @@ func (app *App) postTrimHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 		FileFormat string  `json:"file_format"`
 		Start      float64 `json:"start"`
 		End        float64 `json:"end"`
+		Title      string  `json:"title"`
+		Type       string  `json:"type"`
+		// Normalize overrides the channel's loudness normalisation default.
+		Normalize *bool `json:"normalize"`
 	}
 
 	decodeStart := time.Now()
@@ -504,82 +1120,216 @@ func (app *App) postTrimHandler(w http.ResponseWriter, r *http.Request, cs *Chan
 		return
 	}
 
-	if !slices.Contains([]string{"m4a", "mp3", "mp4"}, trimReq.FileFormat) {
+	if !slices.Contains([]string{"m4a", "opus", "mp3", "mp4", "gif", "webm"}, trimReq.FileFormat) {
 		http.Error(w, "Invalid file format", http.StatusBadRequest)
 		metrics.Http400Errors.Inc()
 		return
 	}
+	format := trimReq.FileFormat
+	if trimReq.Type != "" && trimReq.Type != format {
+		if trimReq.FileFormat != "mp4" || !isAnimatedExt("."+trimReq.Type) {
+			http.Error(w, "Invalid media type", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		format = trimReq.Type
+	}
+	normalize := wantsNormalize(cs, trimReq.Normalize) && format != "gif"
 
 	if !isValidID(trimReq.StreamID) || !isValidID(trimReq.ClipID) {
 		http.Error(w, "Invalid stream or clip ID", http.StatusBadRequest)
 		metrics.Http400Errors.Inc()
 		return
 	}
+	title, ok := clipTitle(w, trimReq.Title)
+	if !ok {
+		return
+	}
+
+	app.queueClipJob(w, cs, clipJobKindTrim, "", func(job *clipJob) (model.Clip, error) {
+		uniqueID := shortuuid.New()
+		sourceKey := storage.ClipKey(cs.Key, trimReq.StreamID, trimReq.ClipID, "."+trimReq.FileFormat)
+
+		// Download the source clip to a temp file.
+		job.setPhase(clipJobPhaseDownloading)
+		downloadStart := time.Now()
+		tempSource := filepath.Join(app.TempDir, fmt.Sprintf("src_%s.%s", uniqueID, trimReq.FileFormat))
+		reader, err := app.Storage.Get(app.ctx, sourceKey)
+		if err != nil {
+			slog.Error("failed to download source for trim", "key", sourceKey, "err", err)
+			return model.Clip{}, errors.New("source clip not found")
+		}
+
+		outFile, err := os.Create(tempSource)
+		if err != nil {
+			reader.Close()
+			app.reportClipJobError(job, err, "unable to create temp file for trim")
+			return model.Clip{}, errors.New("unable to download source clip")
+		}
+		_, copyErr := io.Copy(outFile, reader)
+		reader.Close()
+		outFile.Close()
+		defer os.Remove(tempSource)
+		if copyErr != nil {
+			app.reportClipJobError(job, copyErr, "unable to download source for trim")
+			return model.Clip{}, errors.New("unable to download source clip")
+		}
+		observe("download_source", downloadStart)
+
+		// Trim
+		job.setPhase(clipJobPhaseTrimming)
+		trimStart := time.Now()
+		tempDest := filepath.Join(app.TempDir, fmt.Sprintf("trim_%s.%s", uniqueID, trimReq.FileFormat))
+		// A trim is a copy, unless its audio is normalised on the way.
+		var trimProfile media.AudioProfile
+		if normalize {
+			trimProfile = cs.ClipProfile.For("." + trimReq.FileFormat)
+			trimProfile.Normalize = true
+		}
+		if err := app.Media.Trim(app.ctx, tempSource, tempDest, start, end, trimProfile); err != nil {
+			slog.Error("ffmpeg trim failed", "jobID", job.id, "err", err)
+			os.Remove(tempDest)
+			return model.Clip{}, errors.New("trim failed")
+		}
+		defer os.Remove(tempDest)
+		observe("trim_processing", trimStart)
+
+		if format != trimReq.FileFormat {
+			job.setPhase(clipJobPhaseAnimating)
+			animateStart := time.Now()
+			animatedFile, err := app.animateClip(uniqueID, "."+format, tempDest)
+			if err != nil {
+				app.reportClipJobError(job, err, "unable to animate trimmed clip", "format", format)
+				return model.Clip{}, errors.New("unable to convert media")
+			}
+			defer os.Remove(animatedFile)
+			tempDest = animatedFile
+			observe("animate", animateStart)
+		}
+
+		// Upload detached from shutdown so it never leaves a half-written
+		// clip in storage.
+		job.setPhase(clipJobPhaseUploading)
+		uploadCtx := context.WithoutCancel(app.ctx)
+		uploadStart := time.Now()
+		destKey := storage.ClipKey(cs.Key, trimReq.StreamID, uniqueID, "."+format)
+		if err := app.uploadFile(uploadCtx, destKey, tempDest); err != nil {
+			slog.Error("failed to upload trimmed clip", "jobID", job.id, "err", err)
+			return model.Clip{}, errors.New("unable to store clip")
+		}
+		observe("upload_trim", uploadStart)
+
+		switch format {
+		case "m4a", "opus", "mp3":
+			metrics.TotalAudioTrimmed.WithLabelValues(cs.Key).Inc()
+			metrics.StreamAudioTrimmed.WithLabelValues(cs.Key).Inc()
+		case "mp4", "gif", "webm":
+			metrics.TotalVideoTrimmed.WithLabelValues(cs.Key).Inc()
+			metrics.StreamVideoTrimmed.WithLabelValues(cs.Key).Inc()
+		}
+
+		// A trim covers the same lines as its source, when the source is known.
+		trimmed := model.Clip{
+			ClipID:       uniqueID,
+			StreamID:     trimReq.StreamID,
+			StartLine:    -1,
+			EndLine:      -1,
+			Format:       format,
+			Title:        title,
+			SourceClipID: trimReq.ClipID,
+			SizeBytes:    fileSize(tempDest),
+			Normalized:   normalize,
+		}
+		source, err := app.Store.GetClip(uploadCtx, cs.Key, trimReq.ClipID)
+		if err != nil {
+			slog.Warn("failed to look up trim source in clip catalog", "key", cs.Key, "func", "postTrimHandler", "clipID", trimReq.ClipID, "err", err)
+		} else if source != nil && source.StreamID == trimReq.StreamID {
+			trimmed.StartLine = source.StartLine
+			trimmed.EndLine = source.EndLine
+			// A trim copies its source's audio, normalised or not.
+			trimmed.Normalized = trimmed.Normalized || (source.Normalized && format != "gif")
+		}
+		return trimmed, nil
+	})
+}
+
+// maxClipTitleLength bounds the optional title a clip or trim request may
+// carry, in characters.
+const maxClipTitleLength = 200
 
-	uniqueID := shortuuid.New()
-	sourceKey := storage.ClipKey(cs.Key, trimReq.StreamID, trimReq.ClipID, "."+trimReq.FileFormat)
+// clipTitle trims and validates a clip title. On failure it writes the 400
+// response and returns ok=false.
+func clipTitle(w http.ResponseWriter, title string) (string, bool) {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > maxClipTitleLength {
+		http.Error(w, fmt.Sprintf("title must be at most %d characters", maxClipTitleLength), http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return "", false
+	}
+	return title, true
+}
 
-	// Download the source clip to a temp file.
-	downloadStart := time.Now()
-	tempSource := filepath.Join(app.TempDir, fmt.Sprintf("src_%s.%s", uniqueID, trimReq.FileFormat))
-	reader, err := app.Storage.Get(r.Context(), sourceKey)
+// fileSize returns the size of the file at path, or 0 if it cannot be
+// stat'd.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
 	if err != nil {
-		slog.Error("failed to download source for trim", "key", sourceKey, "err", err)
-		http.Error(w, "Source file not found", http.StatusNotFound)
+		return 0
+	}
+	return info.Size()
+}
+
+// catalogClip records an uploaded clip in the clip catalog, stamping its
+// creation time. The clip is already in storage, so a failure is logged
+// rather than failing the job: the client can still use the clip, it just
+// won't be listed.
+func (app *App) catalogClip(ctx context.Context, cs *ChannelState, clip *model.Clip) {
+	clip.CreatedAt = time.Now().Unix()
+	if err := app.Store.InsertClip(ctx, cs.Key, *clip); err != nil {
+		slog.Error("failed to record clip in catalog", "key", cs.Key, "func", "catalogClip", "clipID", clip.ClipID, "streamID", clip.StreamID, "err", err)
+	}
+}
+
+// getClipsHandler lists a stream's catalogued clips, newest first.
+func (app *App) getClipsHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	streamID := r.PathValue("streamID")
+	if !isValidID(streamID) {
+		http.Error(w, "Invalid stream ID", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
 		return
 	}
 
-	outFile, err := os.Create(tempSource)
+	clips, err := app.Store.ListClips(r.Context(), cs.Key, streamID)
 	if err != nil {
-		reader.Close()
-		http.Error(w, "Server Error", http.StatusInternalServerError)
-		app.report500(r, err, "unable to create temp file for trim", "key", cs.Key, "func", "postTrimHandler")
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to list clips", "key", cs.Key, "func", "getClipsHandler", "streamID", streamID)
 		return
 	}
-	_, copyErr := io.Copy(outFile, reader)
-	reader.Close()
-	outFile.Close()
-	defer os.Remove(tempSource)
-	if copyErr != nil {
-		http.Error(w, "Server Error", http.StatusInternalServerError)
-		app.report500(r, copyErr, "unable to download source for trim", "key", cs.Key, "func", "postTrimHandler")
-		return
+	if clips == nil {
+		clips = []model.Clip{}
 	}
-	observe("download_source", downloadStart)
+	writeJSON(w, clips)
+}
 
-	// Trim
-	trimStart := time.Now()
-	tempDest := filepath.Join(app.TempDir, fmt.Sprintf("trim_%s.%s", uniqueID, trimReq.FileFormat))
-	if err := app.Media.Trim(tempSource, tempDest, start, end); err != nil {
-		http.Error(w, "Trim failed", http.StatusInternalServerError)
-		slog.Error("ffmpeg trim failed", "err", err)
+// getClipHandler returns one catalogued clip's metadata.
+func (app *App) getClipHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	clipID := r.PathValue("clipID")
+	if !isValidID(clipID) {
+		http.Error(w, "Invalid clip ID", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
 		return
 	}
-	defer os.Remove(tempDest)
-	observe("trim_processing", trimStart)
 
-	// Upload with a detached context so a client disconnect doesn't leave a
-	// half-written clip in storage.
-	uploadStart := time.Now()
-	destKey := storage.ClipKey(cs.Key, trimReq.StreamID, uniqueID, "."+trimReq.FileFormat)
-	if err := app.uploadFile(context.WithoutCancel(r.Context()), destKey, tempDest); err != nil {
-		http.Error(w, "Upload failed", http.StatusInternalServerError)
-		slog.Error("failed to upload trimmed clip", "err", err)
+	clip, err := app.Store.GetClip(r.Context(), cs.Key, clipID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get clip", "key", cs.Key, "func", "getClipHandler", "clipID", clipID)
 		return
 	}
-	observe("upload_trim", uploadStart)
-
-	switch trimReq.FileFormat {
-	case "m4a", "mp3":
-		metrics.TotalAudioTrimmed.WithLabelValues(cs.Key).Inc()
-		metrics.StreamAudioTrimmed.WithLabelValues(cs.Key).Inc()
-	case "mp4":
-		metrics.TotalVideoTrimmed.WithLabelValues(cs.Key).Inc()
-		metrics.StreamVideoTrimmed.WithLabelValues(cs.Key).Inc()
+	if clip == nil {
+		http.Error(w, "Clip not found", http.StatusNotFound)
+		metrics.Http400Errors.Inc()
+		return
 	}
-
-	writeJSON(w, map[string]string{
-		"status":  "success",
-		"clip_id": uniqueID,
-	})
+	writeJSON(w, clip)
 }
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"live-transcript-server/internal/media"
@@ -23,7 +27,8 @@ import (
 )
 
 // syncHandler handles a sync request from the worker. Sets current stream
-// state and replaces the transcript.
+// state and brings the stored transcript in line with the worker's, writing
+// only the lines that differ and broadcasting them as one linesChanged event.
 func (app *App) syncHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
 	uploadStartTime := time.Now()
 
@@ -71,14 +76,20 @@ func (app *App) syncHandler(w http.ResponseWriter, r *http.Request, cs *ChannelS
 		}
 	}
 
-	if err := app.Store.ReplaceTranscript(r.Context(), cs.Key, data.StreamID, data.Transcript); err != nil {
+	dbSyncStart := time.Now()
+	upserted, deleted, err := app.Store.SyncTranscript(r.Context(), cs.Key, data.StreamID, data.Transcript)
+	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		app.report500(r, err, "failed to replace transcript")
+		app.report500(r, err, "failed to sync transcript")
 		return
 	}
+	metrics.RequestProcessingDuration.WithLabelValues("syncHandler", "db_sync", cs.Key).Observe(time.Since(dbSyncStart).Seconds())
 	app.bumpAdminChange(cs.Key)
 
-	app.broadcastNewLine(r.Context(), cs, data.StreamID, uploadTime, nil)
+	if len(upserted) > 0 || len(deleted) > 0 {
+		slog.Info("transcript resynced", "key", cs.Key, "func", "syncHandler", "streamID", data.StreamID, "upserted", len(upserted), "deleted", len(deleted))
+		app.broadcastLinesChanged(r.Context(), cs, data.StreamID, upserted, deleted)
+	}
 
 	if uploadTime > 5*1000 {
 		slog.Warn("slow upload time", "key", cs.Key, "func", "syncHandler", "uploadTimeMs", uploadTime, "processingTimeMs", time.Since(processStartTime).Milliseconds())
@@ -123,9 +134,21 @@ func (app *App) lineHandler(w http.ResponseWriter, r *http.Request, cs *ChannelS
 
 	dbInsertStart := time.Now()
 	err := app.Store.InsertNextLine(r.Context(), cs.Key, streamID, data)
+	if errors.Is(err, store.ErrDuplicateLine) {
+		// A retry of a line we already committed: the worker just missed our
+		// answer. Clients already have the line, so no broadcast.
+		metrics.DuplicateLines.Inc()
+		slog.Info("duplicate line ignored", "key", cs.Key, "func", "lineHandler", "lineID", data.ID)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Line already stored"))
+		return
+	}
 	if errors.Is(err, store.ErrOutOfSync) {
 		http.Error(w, "Server out of sync. Send current state.", http.StatusConflict)
 		metrics.ServerOOS.Inc()
+		if errors.Is(err, store.ErrLineConflict) {
+			metrics.ConflictingLines.Inc()
+		}
 		slog.Warn("line id mismatch. Requesting worker to send current state.", "key", cs.Key, "func", "lineHandler", "newLineID", data.ID, "err", err)
 		return
 	}
@@ -140,6 +163,8 @@ func (app *App) lineHandler(w http.ResponseWriter, r *http.Request, cs *ChannelS
 	app.broadcastNewLine(r.Context(), cs, streamID, uploadTime, &data)
 	metrics.RequestProcessingDuration.WithLabelValues("lineHandler", "broadcast", cs.Key).Observe(time.Since(broadcastStart).Seconds())
 
+	app.checkWatchlist(cs, streamID, data)
+
 	if time.Since(processStartTime).Seconds() > 1 {
 		slog.Warn("slow processing time", "key", cs.Key, "func", "lineHandler", "uploadTimeMs", time.Since(uploadStartTime).Milliseconds(), "processingTimeMs", time.Since(processStartTime).Milliseconds(), "lineId", data.ID)
 	}
@@ -147,6 +172,126 @@ func (app *App) lineHandler(w http.ResponseWriter, r *http.Request, cs *ChannelS
 	w.Write([]byte("JSON Line data received and processed successfully"))
 }
 
+// maxBatchLines caps how many lines one /lines request may carry. A worker
+// further behind than this should send its state with /sync instead.
+const maxBatchLines = 1000
+
+// LinesResponse is returned by POST /{channel}/lines/{streamID}. Accepted
+// lines were stored and broadcast; Duplicates were already stored with the
+// same content and skipped; LastLineID is the stream's last stored line
+// afterwards (-1 for none). When the batch broke the contiguity rule,
+// OutOfSyncAt is where the worker's transcript stops matching the server's —
+// the next expected ID for a gap, or the ID of a line stored with different
+// content — and ReceivedLineID the ID that was rejected; both are omitted when
+// every line was accepted.
+type LinesResponse struct {
+	Accepted       int    `json:"accepted"`
+	Duplicates     int    `json:"duplicates"`
+	LastLineID     int    `json:"lastLineId"`
+	OutOfSyncAt    *int   `json:"outOfSyncAt,omitempty"`
+	ReceivedLineID *int   `json:"receivedLineId,omitempty"`
+	Message        string `json:"message"`
+}
+
+// linesHandler is the batch form of lineHandler: it appends an ordered array
+// of lines in one transaction and broadcasts the stored ones in order. Lines
+// are accepted up to the first one that is not next in sequence; resent lines
+// already stored with the same content are skipped, as in lineHandler. A fully
+// accepted batch answers 200; otherwise 409 with a LinesResponse saying
+// exactly how far the server got, so the worker can resend from there or fall
+// back to /sync.
+func (app *App) linesHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	uploadStartTime := time.Now()
+
+	decodeStart := time.Now()
+	var lines []model.Line
+	if err := json.NewDecoder(r.Body).Decode(&lines); err != nil {
+		http.Error(w, "Error decoding JSON data", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		slog.Error("unable to decode JSON data", "key", cs.Key, "func", "linesHandler", "err", err)
+		return
+	}
+	metrics.RequestProcessingDuration.WithLabelValues("linesHandler", "decode_json", cs.Key).Observe(time.Since(decodeStart).Seconds())
+
+	uploadTime := time.Since(uploadStartTime).Milliseconds()
+	processStartTime := time.Now()
+
+	streamID := r.PathValue("streamID")
+	if !isValidID(streamID) {
+		http.Error(w, "Invalid stream ID", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		slog.Warn("invalid stream id", "key", cs.Key, "func", "linesHandler", "streamID", streamID)
+		return
+	}
+	if len(lines) == 0 || len(lines) > maxBatchLines {
+		http.Error(w, fmt.Sprintf("Batch must contain between 1 and %d lines", maxBatchLines), http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+
+	// Force MediaAvailable to false for new lines
+	for i := range lines {
+		lines[i].MediaAvailable = false
+	}
+
+	dbInsertStart := time.Now()
+	stored, processed, err := app.Store.InsertNextLines(r.Context(), cs.Key, streamID, lines)
+	if err != nil && !errors.Is(err, store.ErrOutOfSync) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to insert transcript lines", "key", cs.Key, "func", "linesHandler", "streamID", streamID)
+		return
+	}
+	metrics.RequestProcessingDuration.WithLabelValues("linesHandler", "db_insert", cs.Key).Observe(time.Since(dbInsertStart).Seconds())
+
+	broadcastStart := time.Now()
+	for i := range stored {
+		app.broadcastNewLine(r.Context(), cs, streamID, uploadTime, &stored[i])
+	}
+	metrics.RequestProcessingDuration.WithLabelValues("linesHandler", "broadcast", cs.Key).Observe(time.Since(broadcastStart).Seconds())
+
+	for _, line := range stored {
+		app.checkWatchlist(cs, streamID, line)
+	}
+
+	resp := LinesResponse{Accepted: len(stored), Duplicates: processed - len(stored)}
+	metrics.DuplicateLines.Add(float64(resp.Duplicates))
+	if len(stored) > 0 {
+		resp.LastLineID = stored[len(stored)-1].ID
+	} else if resp.LastLineID, err = app.Store.GetLastLineID(r.Context(), cs.Key, streamID); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get last line id", "key", cs.Key, "func", "linesHandler", "streamID", streamID)
+		return
+	}
+
+	if processed == len(lines) {
+		resp.Message = fmt.Sprintf("accepted through line %d", resp.LastLineID)
+	} else {
+		// A gap is out of sync at the next expected ID; a conflict at the
+		// stored line whose content differs.
+		received := lines[processed].ID
+		at := min(received, resp.LastLineID+1)
+		resp.OutOfSyncAt, resp.ReceivedLineID = &at, &received
+		resp.Message = fmt.Sprintf("accepted through line %d, out of sync at %d", resp.LastLineID, at)
+		metrics.ServerOOS.Inc()
+		if errors.Is(err, store.ErrLineConflict) {
+			metrics.ConflictingLines.Inc()
+		}
+		slog.Warn("line id mismatch in batch. Worker must resend or send current state.", "key", cs.Key, "func", "linesHandler", "accepted", len(stored), "outOfSyncAt", at, "receivedLineID", received, "err", err)
+	}
+
+	if time.Since(processStartTime).Seconds() > 1 {
+		slog.Warn("slow processing time", "key", cs.Key, "func", "linesHandler", "uploadTimeMs", time.Since(uploadStartTime).Milliseconds(), "processingTimeMs", time.Since(processStartTime).Milliseconds(), "lines", len(lines))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if processed < len(lines) {
+		w.WriteHeader(http.StatusConflict)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to write JSON response", "err", err)
+	}
+}
+
 // uploadFile streams a local file into storage under key.
 func (app *App) uploadFile(ctx context.Context, key, path string) error {
 	f, err := os.Open(path)
@@ -164,10 +309,26 @@ func (app *App) uploadFile(ctx context.Context, key, path string) error {
 	return nil
 }
 
+// savePeaks stores a chunk's waveform peaks under key as a JSON array.
+func (app *App) savePeaks(ctx context.Context, key string, peaks []int) error {
+	if peaks == nil {
+		peaks = []int{}
+	}
+	data, err := json.Marshal(peaks)
+	if err != nil {
+		return err
+	}
+	if _, err := app.Storage.Save(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("save %s: %w", key, err)
+	}
+	return nil
+}
+
 // mediaHandler handles a media file upload from the worker: save to a temp
-// file, convert to m4a, upload raw + m4a (+ a frame for video streams) to
-// storage, then mark the line's media available. The DB commit happens BEFORE
-// the response is written so a 200 always means the media is actually
+// file, convert to the stream's audio format, upload raw + audio + waveform
+// peaks (+ a frame for video streams) to storage, record the chunk's probed
+// metadata, then mark the line's media available. The DB commit happens
+// BEFORE the response is written so a 200 always means the media is actually
 // retrievable.
 func (app *App) mediaHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
 	observe := func(step string, since time.Time) {
@@ -228,7 +389,8 @@ func (app *App) mediaHandler(w http.ResponseWriter, r *http.Request, cs *Channel
 		app.report500(r, err, "unable to create temp raw file", "key", cs.Key, "func", "mediaHandler")
 		return
 	}
-	if _, err := io.Copy(dst, file); err != nil {
+	rawSize, err := io.Copy(dst, file)
+	if err != nil {
 		dst.Close()
 		os.Remove(tempRawHost)
 		http.Error(w, "Unable to save file", http.StatusInternalServerError)
@@ -242,16 +404,26 @@ func (app *App) mediaHandler(w http.ResponseWriter, r *http.Request, cs *Channel
 	// verification, which was interleaved.
 	metrics.MediaProcessingDuration.WithLabelValues("retrieve_file", cs.Key).Observe((time.Since(retrieveStart) - verifDuration).Seconds())
 
-	// Convert to m4a
+	// The stream's format is normally claimed when it activates; claiming
+	// here too covers streams that only arrived through /sync.
+	audioExt, err := app.Store.ClaimStreamAudioFormat(r.Context(), cs.Key, streamID, cs.AudioProfile.Ext())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to claim stream audio format", "key", cs.Key, "func", "mediaHandler")
+		return
+	}
+
+	// Convert to the stream's audio format
 	convertStart := time.Now()
-	tempM4aHost := media.ChangeExtension(tempRawHost, ".m4a")
-	if err := app.Media.Convert(tempRawHost, tempM4aHost); err != nil {
+	tempAudioHost := media.ChangeExtension(tempRawHost, audioExt)
+	// Tied to the request: if the worker gives up, so does ffmpeg.
+	if err := app.Media.Convert(r.Context(), tempRawHost, tempAudioHost, cs.AudioProfile.For(audioExt)); err != nil {
 		http.Error(w, "Unable to convert media", http.StatusInternalServerError)
 		app.report500(r, err, "unable to convert media", "key", cs.Key, "func", "mediaHandler")
 		return
 	}
 	observe("convert_m4a", convertStart)
-	defer os.Remove(tempM4aHost)
+	defer os.Remove(tempAudioHost)
 
 	// Use a detached context for uploads and the DB commit so they complete
 	// even if the worker disconnects mid-request.
@@ -266,13 +438,41 @@ func (app *App) mediaHandler(w http.ResponseWriter, r *http.Request, cs *Channel
 	}
 	observe("upload_raw", uploadRawStart)
 
-	uploadM4aStart := time.Now()
-	if err := app.uploadFile(uploadCtx, storage.AudioKey(cs.Key, streamID, fileID), tempM4aHost); err != nil {
+	uploadAudioStart := time.Now()
+	if err := app.uploadFile(uploadCtx, storage.AudioKey(cs.Key, streamID, fileID, audioExt), tempAudioHost); err != nil {
 		http.Error(w, "Storage Error", http.StatusInternalServerError)
-		app.report500(r, err, "failed to upload m4a file", "key", cs.Key)
+		app.report500(r, err, "failed to upload audio file", "key", cs.Key)
 		return
 	}
-	observe("upload_m4a", uploadM4aStart)
+	observe("upload_m4a", uploadAudioStart)
+
+	// Waveform peaks for the clip and trim UI. Like the frame, they are
+	// optional: a chunk without them is still served.
+	peaksStart := time.Now()
+	if peaks, err := app.Media.Peaks(uploadCtx, tempAudioHost); err != nil {
+		slog.Warn("failed to compute peaks", "key", cs.Key, "streamID", streamID, "err", err)
+	} else {
+		observe("compute_peaks", peaksStart)
+		uploadPeaksStart := time.Now()
+		if err := app.savePeaks(uploadCtx, storage.PeaksKey(cs.Key, streamID, fileID), peaks); err != nil {
+			slog.Error("failed to upload peaks", "key", cs.Key, "err", err)
+		}
+		observe("upload_peaks", uploadPeaksStart)
+	}
+
+	// Record what the chunk holds, for clip math and storage accounting.
+	// Also optional: a chunk that cannot be probed keeps just its size.
+	chunk := model.ChunkMedia{SizeBytes: rawSize}
+	probeStart := time.Now()
+	if info, err := app.Media.Probe(uploadCtx, tempRawHost); err != nil {
+		slog.Warn("failed to probe media", "key", cs.Key, "streamID", streamID, "err", err)
+	} else {
+		observe("probe", probeStart)
+		chunk.Duration, chunk.Codec, chunk.Width, chunk.Height = info.Duration, info.Codec, info.Width, info.Height
+	}
+	if err := app.Store.SetChunkMedia(uploadCtx, cs.Key, streamID, fileID, chunk); err != nil {
+		slog.Error("failed to record chunk media", "key", cs.Key, "streamID", streamID, "err", err)
+	}
 
 	// Extract a preview frame for video streams.
 	stream, err := app.Store.GetStreamByID(uploadCtx, cs.Key, streamID)
@@ -282,7 +482,7 @@ func (app *App) mediaHandler(w http.ResponseWriter, r *http.Request, cs *Channel
 	if stream != nil && stream.MediaType == "video" {
 		extractFrameStart := time.Now()
 		tempJpgHost := media.ChangeExtension(tempRawHost, ".jpg")
-		if err := app.Media.ExtractFrame(tempRawHost, tempJpgHost, 480); err == nil {
+		if err := app.Media.ExtractFrame(uploadCtx, tempRawHost, tempJpgHost, 480); err == nil {
 			observe("extract_frame", extractFrameStart)
 			defer os.Remove(tempJpgHost)
 
@@ -408,9 +608,119 @@ func (app *App) workerStatusHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// StatusCheckResponse is returned by GET /{channel}/statuscheck when the
+// worker asks for JSON. StreamID, LastLineID and Checksum describe the
+// server's copy of the current stream (see transcriptChecksum). InSync and
+// Drift are set only when the worker sent its own state to compare: Drift
+// names what differed — "stream", "lines" or "checksum" — and is empty when
+// InSync is true.
+type StatusCheckResponse struct {
+	Clients    int    `json:"clients"`
+	StreamID   string `json:"streamId"`
+	LastLineID int    `json:"lastLineId"`
+	Checksum   string `json:"checksum"`
+	InSync     *bool  `json:"inSync,omitempty"`
+	Drift      string `json:"drift,omitempty"`
+}
+
+// statuscheckHandler reports the number of connected clients. A worker that
+// sends Accept: application/json, or its own state as the streamId,
+// lastLineId and checksum query params, gets a StatusCheckResponse instead,
+// so it can notice silent divergence (a dropped line, a partial /sync)
+// without uploading its whole transcript. A disagreement is logged and
+// counted. Admin edits are deliberately left out of the comparison: they are
+// kept apart from the worker's copy and survive /sync, so a resync could never
+// reconcile them.
 func (app *App) statuscheckHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
-	w.WriteHeader(http.StatusOK)
-	w.Write(fmt.Appendf(nil, "Current number of clients: %d", cs.Hub.Connections()))
+	query := r.URL.Query()
+	workerStreamID := query.Get("streamId")
+	if workerStreamID == "" && !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.WriteHeader(http.StatusOK)
+		w.Write(fmt.Appendf(nil, "Current number of clients: %d", cs.Hub.Connections()))
+		return
+	}
+
+	resp := StatusCheckResponse{Clients: cs.Hub.Connections(), LastLineID: -1}
+	stream, err := app.Store.GetRecentStream(r.Context(), cs.Key)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get current stream", "key", cs.Key, "func", "statuscheckHandler")
+		return
+	}
+	var lines []model.Line
+	if stream != nil {
+		resp.StreamID = stream.StreamID
+		checksumStart := time.Now()
+		// The worker's own lines, not admin edits: the worker never sees those.
+		lines, err = app.Store.GetWorkerTranscript(r.Context(), cs.Key, stream.StreamID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			app.report500(r, err, "failed to get transcript", "key", cs.Key, "func", "statuscheckHandler", "streamID", stream.StreamID)
+			return
+		}
+		if len(lines) > 0 {
+			resp.LastLineID = lines[len(lines)-1].ID
+		}
+		resp.Checksum = transcriptChecksum(lines)
+		metrics.RequestProcessingDuration.WithLabelValues("statuscheckHandler", "checksum", cs.Key).Observe(time.Since(checksumStart).Seconds())
+	} else {
+		resp.Checksum = transcriptChecksum(nil)
+	}
+
+	if workerStreamID != "" {
+		workerLastLineID, err := strconv.Atoi(query.Get("lastLineId"))
+		if err != nil {
+			http.Error(w, "Invalid lastLineId", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		switch {
+		case workerStreamID != resp.StreamID:
+			resp.Drift = "stream"
+		case workerLastLineID != resp.LastLineID:
+			resp.Drift = "lines"
+		case query.Get("checksum") != resp.Checksum:
+			resp.Drift = "checksum"
+		}
+		inSync := resp.Drift == ""
+		resp.InSync = &inSync
+		if !inSync {
+			metrics.WorkerDrift.WithLabelValues(cs.Key, resp.Drift).Inc()
+			slog.Warn("worker state differs from server", "key", cs.Key, "func", "statuscheckHandler", "drift", resp.Drift,
+				"workerStreamID", workerStreamID, "serverStreamID", resp.StreamID,
+				"workerLastLineID", workerLastLineID, "serverLastLineID", resp.LastLineID,
+				"workerChecksum", query.Get("checksum"), "serverChecksum", resp.Checksum)
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+// transcriptChecksum is a cumulative hash of a transcript's content, which a
+// worker can reproduce from its own copy: the hex SHA-256 of, for each line
+// in order,
+//
+//	<lineId> 0x1F <timestamp> [0x1F <segment text>]... 0x1E
+//
+// with IDs and timestamps in decimal and one 0x1F-prefixed text per segment.
+// Only the text of each segment counts, so differences in JSON encoding
+// between worker and server do not register as drift. Segments that are not
+// valid JSON contribute no text. The lines are the worker's copy, without
+// admin edits (see statuscheckHandler).
+func transcriptChecksum(lines []model.Line) string {
+	h := sha256.New()
+	for _, line := range lines {
+		var segments []struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal(line.Segments, &segments)
+		fmt.Fprintf(h, "%d\x1f%d", line.ID, line.Timestamp)
+		for _, seg := range segments {
+			fmt.Fprintf(h, "\x1f%s", seg.Text)
+		}
+		h.Write([]byte{0x1e})
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // IncomingStreamsResponse is returned by GET /{channel}/incoming.
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"live-transcript-server/internal/metrics"
+	"live-transcript-server/internal/model"
+	"live-transcript-server/internal/storage"
+)
+
+// getHlsPlaylistHandler serves an HLS media playlist of a stream's per-line
+// audio, one segment per line with media, in line order. start=<lineId>
+// (optional) begins the playlist at that line, so a late viewer can listen
+// from any point onward. While the stream is live the playlist is an EVENT
+// playlist that players re-fetch as lines arrive; once it ends it is a VOD
+// playlist.
+//
+// Segments point at the audio objects as stored: through streamHandler with
+// local storage (as paths relative to the playlist, so a proxy prefix is kept)
+// and at the public bucket URL with R2.
+func (app *App) getHlsPlaylistHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	streamID := r.PathValue("streamID")
+	if !isValidID(streamID) {
+		http.Error(w, "Invalid stream ID", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	start := 0
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		parsed, err := strconv.Atoi(startStr)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid start line ID", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		start = parsed
+	}
+
+	stream, err := app.Store.GetStreamByID(r.Context(), cs.Key, streamID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get stream for playlist", "key", cs.Key, "func", "getHlsPlaylistHandler")
+		return
+	}
+	if stream == nil {
+		http.Error(w, "Stream not found", http.StatusNotFound)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	timings, err := app.Store.GetLineTimings(r.Context(), cs.Key, streamID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get lines for playlist", "key", cs.Key, "func", "getHlsPlaylistHandler")
+		return
+	}
+
+	ext := stream.AudioFormat
+	if ext == "" {
+		ext = ".m4a"
+	}
+	segmentURL := func(fileID string) string {
+		if app.Storage.IsLocal() {
+			return fmt.Sprintf("../../stream/%s/audio/%s%s", streamID, fileID, ext)
+		}
+		return app.Storage.GetURL(storage.AudioKey(cs.Key, streamID, fileID, ext))
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	if stream.IsLive {
+		// Players poll a live playlist; a cached copy would stall them.
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Write([]byte(hlsPlaylist(timings, start, stream.IsLive, cs.ChunkSeconds, segmentURL)))
+}
+
+// hlsPlaylist renders lines (as returned by GetLineTimings) from line ID start
+// onward as an HLS media playlist. A segment lasts its chunk's probed duration
+// or, for chunks from before probing, the gap to the next line's timestamp, up
+// to chunkSeconds. Lines without media are skipped, and the gap they leave
+// between two segments is marked as a discontinuity.
+//
+// The target duration is chunkSeconds, not the longest segment so far, since
+// a live playlist's target must not change between reloads. For the same
+// reason a live playlist leaves out its newest segment until its duration is
+// known: once it is probed or the next line arrives. An ended stream's last
+// unprobed chunk is taken to last chunkSeconds.
+func hlsPlaylist(lines []model.Line, start int, live bool, chunkSeconds int, segmentURL func(fileID string) string) string {
+	type segment struct {
+		duration      float64
+		url           string
+		discontinuity bool
+	}
+	var segments []segment
+	gap := false
+	for i, l := range lines {
+		if l.ID < start {
+			continue
+		}
+		if !l.MediaAvailable || l.FileID == "" {
+			gap = len(segments) > 0
+			continue
+		}
+		duration := l.Duration
+		if duration <= 0 && i+1 < len(lines) {
+			duration = min(float64(lines[i+1].Timestamp-l.Timestamp), float64(chunkSeconds))
+		}
+		if duration <= 0 {
+			if live && i+1 == len(lines) {
+				break
+			}
+			duration = float64(chunkSeconds)
+		}
+		segments = append(segments, segment{duration: duration, url: segmentURL(l.FileID), discontinuity: gap})
+		gap = false
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", chunkSeconds)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	if live {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	} else {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	for _, s := range segments {
+		if s.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", s.duration, s.url)
+	}
+	if !live {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"live-transcript-server/internal/model"
+)
+
+func TestGetHlsPlaylist(t *testing.T) {
+	key := "test-hls"
+	app, mux := setupTestApp(t, []string{key})
+	// Lines 0-2 have media, line 3 has none.
+	seedVodStream(t, app, key, "s1", "audio", 4, 3)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+key+"/hls/"+path, nil))
+		return rr
+	}
+
+	rr := get("s1/playlist.m3u8")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/vnd.apple.mpegurl" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	// Local storage: segments go through the stream route, relative to the
+	// playlist. The ended stream's playlist is closed.
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n" +
+		"#EXTINF:10.000,\n../../stream/s1/audio/file0.m4a\n" +
+		"#EXTINF:10.000,\n../../stream/s1/audio/file1.m4a\n" +
+		"#EXTINF:10.000,\n../../stream/s1/audio/file2.m4a\n" +
+		"#EXT-X-ENDLIST\n"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("playlist:\n%s\nwant:\n%s", got, want)
+	}
+
+	if rr := get("s1/playlist.m3u8?start=2"); !strings.HasSuffix(rr.Body.String(), "#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:10.000,\n../../stream/s1/audio/file2.m4a\n#EXT-X-ENDLIST\n") {
+		t.Errorf("start=2: expected only line 2, got:\n%s", rr.Body.String())
+	}
+
+	if rr := get("missing/playlist.m3u8"); rr.Code != http.StatusNotFound {
+		t.Errorf("missing stream: expected 404, got %d", rr.Code)
+	}
+	for _, path := range []string{"s1/playlist.m3u8?start=-1", "s1/playlist.m3u8?start=x", "bad.id/playlist.m3u8"} {
+		if rr := get(path); rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestHlsPlaylist(t *testing.T) {
+	lines := []model.Line{
+		{ID: 0, Timestamp: 100, FileID: "a", MediaAvailable: true, ChunkMedia: model.ChunkMedia{Duration: 9.5}},
+		{ID: 1, Timestamp: 110},
+		{ID: 2, Timestamp: 120, FileID: "c", MediaAvailable: true},
+		{ID: 3, Timestamp: 132, FileID: "d", MediaAvailable: true},
+		{ID: 4, Timestamp: 140},
+	}
+	url := func(fileID string) string { return "https://media.example/" + fileID + ".opus" }
+
+	// Probed durations win; unprobed chunks last until the next line, but
+	// no longer than the chunk length, which is also the target duration.
+	// The gap at line 1 is a discontinuity, the trailing one at line 4 is
+	// just left out, and a live playlist stays open.
+	header := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n"
+	want := header + "#EXT-X-PLAYLIST-TYPE:EVENT\n" +
+		"#EXTINF:9.500,\nhttps://media.example/a.opus\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:10.000,\nhttps://media.example/c.opus\n" +
+		"#EXTINF:8.000,\nhttps://media.example/d.opus\n"
+	if got := hlsPlaylist(lines, 0, true, 10, url); got != want {
+		t.Errorf("live playlist:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A live stream's newest chunk waits until its duration is known; once
+	// the stream ends it is taken to last the chunk length.
+	newest := lines[:4]
+	want = header + "#EXT-X-PLAYLIST-TYPE:EVENT\n" +
+		"#EXTINF:9.500,\nhttps://media.example/a.opus\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:10.000,\nhttps://media.example/c.opus\n"
+	if got := hlsPlaylist(newest, 0, true, 10, url); got != want {
+		t.Errorf("live playlist with an unmeasured newest chunk:\n%s\nwant:\n%s", got, want)
+	}
+	if got := hlsPlaylist(newest, 3, false, 10, url); got != header+"#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:10.000,\nhttps://media.example/d.opus\n#EXT-X-ENDLIST\n" {
+		t.Errorf("ended playlist from line 3:\n%s", got)
+	}
+	probed := slices.Clone(newest)
+	probed[3].Duration = 7.25
+	if got := hlsPlaylist(probed, 3, true, 10, url); got != header+"#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:7.250,\nhttps://media.example/d.opus\n" {
+		t.Errorf("live playlist with a probed newest chunk:\n%s", got)
+	}
+
+	// A playlist starting at a gap does not open with a discontinuity.
+	if got := hlsPlaylist(lines, 1, false, 10, url); strings.Contains(got, "DISCONTINUITY") || !strings.HasSuffix(got, "#EXT-X-ENDLIST\n") {
+		t.Errorf("ended playlist from line 1:\n%s", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"time"
 
+	"live-transcript-server/internal/export"
 	"live-transcript-server/internal/metrics"
 	"live-transcript-server/internal/model"
 	"live-transcript-server/internal/storage"
@@ -52,10 +53,13 @@ func (app *App) activateStream(ctx context.Context, cs *ChannelState, streamID s
 			ActivatedTime: time.Now().UnixMicro(),
 		}
 
-		// Deactivate previous stream if it was live
+		// Deactivate previous stream if it was live. It has ended as surely
+		// as one ended through deactivateStream, so its VOD is built too.
 		if currentStream != nil && currentStream.IsLive {
 			if err := app.Store.SetStreamLive(ctx, cs.Key, currentStream.StreamID, false); err != nil {
 				slog.Error("failed to deactivate previous stream", "key", cs.Key, "streamID", currentStream.StreamID, "err", err)
+			} else {
+				app.scheduleAutoVod(cs, currentStream.StreamID)
 			}
 		}
 
@@ -63,6 +67,13 @@ func (app *App) activateStream(ctx context.Context, cs *ChannelState, streamID s
 			slog.Error("failed to upsert new stream", "key", cs.Key, "err", err)
 			return false
 		}
+		// Fix the stream's audio format now, so clients learn it with the
+		// stream rather than with its first chunk.
+		audioFormat, err := app.Store.ClaimStreamAudioFormat(ctx, cs.Key, streamID, cs.AudioProfile.Ext())
+		if err != nil {
+			slog.Error("failed to claim stream audio format", "key", cs.Key, "streamID", streamID, "err", err)
+		}
+		newStream.AudioFormat = audioFormat
 
 		app.Discord.NotifyStreamStart(cs.Key, streamID, streamTitle, startTime)
 
@@ -89,6 +100,7 @@ func (app *App) activateStream(ctx context.Context, cs *ChannelState, streamID s
 				MediaType:    newStream.MediaType,
 				MediaBaseURL: app.Storage.GetURL(""),
 				IsLive:       newStream.IsLive,
+				AudioFormat:  newStream.AudioFormat,
 			},
 		}
 		slog.Debug("received new stream id, sending newstream event", "key", cs.Key, "func", "activateStream", "streamID", streamID)
@@ -157,6 +169,7 @@ func (app *App) applyRetention(ctx context.Context, cs *ChannelState, activeStre
 				slog.Error("failed to delete stream from db", "key", cs.Key, "streamID", stream.StreamID, "err", err)
 				continue
 			}
+			app.Clips.forgetStream(cs.Key, stream.StreamID)
 			app.deleteStreamStorageAsync(cs.Key, stream.StreamID)
 		}
 		return
@@ -180,7 +193,9 @@ func (app *App) applyRetention(ctx context.Context, cs *ChannelState, activeStre
 			slog.Info("stream not found in storage (likely deleted by lifecycle), removing from db", "key", cs.Key, "streamID", stream.StreamID)
 			if err := app.Store.DeleteStreamCascade(ctx, cs.Key, stream.StreamID); err != nil {
 				slog.Error("failed to delete stream from db", "key", cs.Key, "streamID", stream.StreamID, "err", err)
+				continue
 			}
+			app.Clips.forgetStream(cs.Key, stream.StreamID)
 		}
 	}
 }
@@ -238,7 +253,8 @@ func (app *App) broadcastPastStreams(ctx context.Context, cs *ChannelState) {
 	})
 }
 
-// deactivateStream deactivates a stream and notifies all clients.
+// deactivateStream deactivates a stream, notifies all clients and, on
+// channels with automatic VOD builds, schedules the stream's build.
 // Returns true if the stream was deactivated and a message was sent, false otherwise.
 func (app *App) deactivateStream(ctx context.Context, cs *ChannelState, streamID string) bool {
 	currentStream, err := app.Store.GetRecentStream(ctx, cs.Key)
@@ -273,6 +289,7 @@ func (app *App) deactivateStream(ctx context.Context, cs *ChannelState, streamID
 		},
 	})
 	app.bumpAdminChange(cs.Key)
+	app.scheduleAutoVod(cs, streamID)
 	return true
 }
 
@@ -286,9 +303,11 @@ func (app *App) removeStream(ctx context.Context, cs *ChannelState, stream *mode
 	if err := app.Store.DeleteStreamCascade(ctx, cs.Key, stream.StreamID); err != nil {
 		return fmt.Errorf("delete stream: %w", err)
 	}
-	// The stream is gone, so its VOD build record is meaningless. Dropping it
-	// keeps the registry from accumulating entries forever.
+	// The stream is gone, so its VOD build record and clip index entries are
+	// meaningless. Dropping them keeps the registries from accumulating
+	// entries forever.
 	app.Vods.forget(cs.Key, stream.StreamID)
+	app.Clips.forgetStream(cs.Key, stream.StreamID)
 	if stream.StreamTitle != "" {
 		metrics.ActivatedStreams.DeleteLabelValues(cs.Key, stream.StreamID, stream.StreamTitle)
 	}
@@ -333,10 +352,86 @@ func (app *App) broadcastNewLine(ctx context.Context, cs *ChannelState, activeID
 			UploadTime:  uploadTime,
 			Segments:    newLine.Segments,
 			VodAccurate: newLine.VodAccurate,
+			ResumeToken: cs.resumeToken(activeID, newLine.ID),
+		},
+	})
+}
+
+// broadcastLinesChanged sends a linesChanged event describing a resync of a
+// stream's transcript. It bumps the channel's transcript revision first, so
+// resume tokens issued before the change are refused.
+func (app *App) broadcastLinesChanged(ctx context.Context, cs *ChannelState, streamID string, upserted []model.Line, deleted []int) {
+	cs.TranscriptRevision.Add(1)
+	lastLineID, err := app.Store.GetLastLineID(ctx, cs.Key, streamID)
+	if err != nil {
+		slog.Error("failed to get last line id for lines changed", "key", cs.Key, "err", err)
+	}
+	if upserted == nil {
+		upserted = []model.Line{}
+	}
+	if deleted == nil {
+		deleted = []int{}
+	}
+	cs.Hub.Broadcast(ws.Message{
+		Event: ws.EventLinesChanged,
+		Data: ws.EventLinesChangedData{
+			StreamID:    streamID,
+			Upserted:    upserted,
+			Deleted:     deleted,
+			ResumeToken: cs.resumeToken(streamID, lastLineID),
 		},
 	})
 }
 
+// broadcastUpdatedLine sends an updatedLine event for an admin edit. An edit
+// to the current stream changes a line clients may already hold, so, like a
+// resync, it bumps the transcript revision and carries a fresh resume token.
+// Edits to past streams carry no token: clients' positions are in the current
+// stream and stay valid.
+func (app *App) broadcastUpdatedLine(ctx context.Context, cs *ChannelState, streamID string, line model.Line) {
+	data := ws.EventUpdatedLineData{StreamID: streamID, Line: line}
+	recent, err := app.Store.GetRecentStream(ctx, cs.Key)
+	if err != nil {
+		slog.Error("failed to get current stream for updated line", "key", cs.Key, "err", err)
+	}
+	if recent != nil && recent.StreamID == streamID {
+		cs.TranscriptRevision.Add(1)
+		lastLineID, err := app.Store.GetLastLineID(ctx, cs.Key, streamID)
+		if err != nil {
+			slog.Error("failed to get last line id for updated line", "key", cs.Key, "err", err)
+		}
+		data.ResumeToken = cs.resumeToken(streamID, lastLineID)
+	}
+	cs.Hub.Broadcast(ws.Message{Event: ws.EventUpdatedLine, Data: data})
+}
+
+// checkWatchlist matches a newly inserted line against the channel's
+// watchlist and, for each entry that is not cooling down, sends a Discord
+// alert and, if the watchlist broadcasts, a keywordHit event.
+func (app *App) checkWatchlist(cs *ChannelState, streamID string, line model.Line) {
+	if cs.Watchlist == nil {
+		return
+	}
+	text := export.LineText(line)
+	for _, hit := range cs.Watchlist.Match(text, time.Now()) {
+		metrics.KeywordHits.WithLabelValues(cs.Key, hit.Keyword).Inc()
+		slog.Info("watchlist keyword hit", "key", cs.Key, "streamID", streamID, "lineID", line.ID, "keyword", hit.Keyword)
+		app.Discord.NotifyKeywordHit(cs.Key, streamID, line.ID, line.Timestamp, hit.Keyword, hit.Matched, text)
+		if cs.Watchlist.Broadcast {
+			cs.Hub.Broadcast(ws.Message{
+				Event: ws.EventKeywordHit,
+				Data: ws.EventKeywordHitData{
+					StreamID:  streamID,
+					LineID:    line.ID,
+					Timestamp: line.Timestamp,
+					Keyword:   hit.Keyword,
+					Matched:   hit.Matched,
+				},
+			})
+		}
+	}
+}
+
 // broadcastNewMedia sends a newMedia event to all clients with the map of
 // latest available media files.
 func (app *App) broadcastNewMedia(cs *ChannelState, streamID string, files map[int]string) {
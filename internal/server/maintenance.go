@@ -147,6 +147,7 @@ func (app *App) pruneExpiredStreams() {
 					slog.Error("Pruning: failed to delete stream", "key", cs.Key, "streamID", stream.StreamID, "err", err)
 					continue
 				}
+				app.Clips.forgetStream(cs.Key, stream.StreamID)
 				updatesMade = true
 			}
 		}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"live-transcript-server/internal/media"
+	"live-transcript-server/internal/metrics"
+	"live-transcript-server/internal/storage"
+)
+
+// PeaksResponse is returned by GET /{channel}/peaks/{streamID}.
+type PeaksResponse struct {
+	// PerSecond is how many peaks there are for each second of audio.
+	PerSecond int `json:"perSecond"`
+	// Peaks is the range's loudness envelope, line after line, each value
+	// 0-100 of full scale.
+	Peaks []int `json:"peaks"`
+	// Lines says where each line of the range sits in Peaks.
+	Lines []PeaksLine `json:"lines"`
+}
+
+// PeaksLine locates one line's peaks in PeaksResponse.Peaks. A line without
+// peaks (no media yet, or media from before peaks were generated) has a
+// Count of 0.
+type PeaksLine struct {
+	ID     int `json:"id"`
+	Offset int `json:"offset"`
+	Count  int `json:"count"`
+}
+
+// getPeaksHandler returns the waveform peaks of lines start-end (query
+// params, inclusive) of a stream, concatenated in line order, so the clip and
+// trim UI can show where speech starts and stops. Ranges are bounded like
+// clips, by MaxClipSize.
+func (app *App) getPeaksHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	streamID := r.PathValue("streamID")
+	if !isValidID(streamID) {
+		http.Error(w, "Invalid stream ID", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+	start, startErr := strconv.Atoi(r.URL.Query().Get("start"))
+	end, endErr := strconv.Atoi(r.URL.Query().Get("end"))
+	if startErr != nil || endErr != nil || start < 0 || end < start || end-start >= app.MaxClipSize {
+		http.Error(w, "Invalid start or end", http.StatusBadRequest)
+		metrics.Http400Errors.Inc()
+		return
+	}
+
+	timings, err := app.Store.GetLineTimingsRange(r.Context(), cs.Key, streamID, start, end)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		app.report500(r, err, "failed to get lines for peaks", "key", cs.Key, "func", "getPeaksHandler")
+		return
+	}
+
+	type linePeaks struct {
+		id     int
+		fileID string
+		peaks  []int
+	}
+	var lines []linePeaks
+	for _, l := range timings {
+		lp := linePeaks{id: l.ID}
+		if l.MediaAvailable {
+			lp.fileID = l.FileID
+		}
+		lines = append(lines, lp)
+	}
+
+	// A range is at most MaxClipSize chunks, so they are all fetched at
+	// once; with R2 each is a round trip.
+	var wg sync.WaitGroup
+	for i := range lines {
+		if lines[i].fileID == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(lp *linePeaks) {
+			defer wg.Done()
+			lp.peaks = app.loadPeaks(r.Context(), storage.PeaksKey(cs.Key, streamID, lp.fileID))
+		}(&lines[i])
+	}
+	wg.Wait()
+
+	resp := PeaksResponse{PerSecond: media.PeaksPerSecond, Peaks: []int{}, Lines: []PeaksLine{}}
+	for _, lp := range lines {
+		resp.Lines = append(resp.Lines, PeaksLine{ID: lp.id, Offset: len(resp.Peaks), Count: len(lp.peaks)})
+		resp.Peaks = append(resp.Peaks, lp.peaks...)
+	}
+	writeJSON(w, resp)
+}
+
+// loadPeaks reads the peaks stored under key. Peaks are optional, so a chunk
+// whose peaks are missing or unreadable just has none.
+func (app *App) loadPeaks(ctx context.Context, key string) []int {
+	reader, err := app.Storage.Get(ctx, key)
+	if err != nil {
+		slog.Debug("no peaks for chunk", "key", key, "err", err)
+		return nil
+	}
+	defer reader.Close()
+	var peaks []int
+	if err := json.NewDecoder(reader).Decode(&peaks); err != nil {
+		slog.Warn("unreadable peaks for chunk", "key", key, "err", err)
+		return nil
+	}
+	return peaks
+}
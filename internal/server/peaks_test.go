@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"live-transcript-server/internal/media"
+	"live-transcript-server/internal/storage"
+)
+
+func TestGetPeaks(t *testing.T) {
+	key := "test-peaks"
+	app, mux := setupTestApp(t, []string{key})
+	// Lines 0-2 have media; line 1's predates peaks and line 3 has none.
+	seedVodStream(t, app, key, "s1", "audio", 4, 3)
+	for fileID, peaks := range map[string][]int{"file0": {1, 2}, "file2": {3}} {
+		if err := app.savePeaks(t.Context(), storage.PeaksKey(key, "s1", fileID), peaks); err != nil {
+			t.Fatalf("save peaks: %v", err)
+		}
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+key+"/peaks/s1"+query, nil))
+		return rr
+	}
+
+	rr := get("?start=0&end=3")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp PeaksResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.PerSecond != media.PeaksPerSecond || !slices.Equal(resp.Peaks, []int{1, 2, 3}) {
+		t.Errorf("got %d/s peaks %v, want %d/s [1 2 3]", resp.PerSecond, resp.Peaks, media.PeaksPerSecond)
+	}
+	wantLines := []PeaksLine{{0, 0, 2}, {1, 2, 0}, {2, 2, 1}, {3, 3, 0}}
+	if !slices.Equal(resp.Lines, wantLines) {
+		t.Errorf("lines = %+v, want %+v", resp.Lines, wantLines)
+	}
+
+	if rr := get("?start=10&end=12"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"peaks":[]`) {
+		t.Errorf("range past the stream: expected 200 with no peaks, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, query := range []string{"", "?start=2&end=1", "?start=-1&end=0", "?start=0&end=x", "?start=0&end=40"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
@@ -34,6 +34,7 @@ func (app *App) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /{channel}/deactivate", app.apiKeyMiddleware(app.withChannel(app.deactivateHandler)))
 	mux.HandleFunc("POST /{channel}/sync", app.apiKeyMiddleware(app.withChannel(app.syncHandler)))
 	mux.HandleFunc("POST /{channel}/line/{streamID}", app.apiKeyMiddleware(app.withChannel(app.lineHandler)))
+	mux.HandleFunc("POST /{channel}/lines/{streamID}", app.apiKeyMiddleware(app.withChannel(app.linesHandler)))
 	mux.HandleFunc("POST /{channel}/media/{streamID}/{id}", app.apiKeyMiddleware(app.withChannel(app.mediaHandler)))
 	mux.HandleFunc("GET /{channel}/statuscheck", app.apiKeyMiddleware(app.withChannel(app.statuscheckHandler)))
 	mux.HandleFunc("POST /status", app.apiKeyMiddleware(app.workerStatusHandler))
@@ -55,6 +56,9 @@ func (app *App) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("DELETE /{channel}/admin/stream/{streamID}", app.withAdminChannel(app.deleteAdminStreamHandler))
 	mux.HandleFunc("POST /{channel}/admin/stream/{streamID}", app.withAdminChannel(app.postAdminStreamHandler))
 	mux.HandleFunc("POST /{channel}/admin/stop", app.withAdminChannel(app.postAdminStopHandler))
+	mux.HandleFunc("POST /{channel}/admin/line/{streamID}/{lineID}", app.withAdminChannel(app.postAdminLineHandler))
+	mux.HandleFunc("GET /{channel}/admin/line/{streamID}/{lineID}/history", app.withAdminChannel(app.getAdminLineHistoryHandler))
+	mux.HandleFunc("POST /{channel}/admin/line/{streamID}/{lineID}/revert", app.withAdminChannel(app.postAdminLineRevertHandler))
 	mux.HandleFunc("GET /{channel}/admin/vod/{streamID}", app.withAdminChannel(app.getAdminVodHandler))
 	mux.HandleFunc("POST /{channel}/admin/vod/{streamID}", app.withAdminChannel(app.postAdminVodHandler))
 	mux.HandleFunc("GET /{channel}/admin/membership", app.withAdminChannel(app.getAdminMembershipHandler))
@@ -64,12 +68,19 @@ func (app *App) RegisterRoutes(mux *http.ServeMux) {
 	// Public routes
 	mux.HandleFunc("GET /status", app.getStatusHandler)
 	mux.HandleFunc("GET /{channel}/websocket", app.withChannel(app.wsHandler))
+	mux.HandleFunc("GET /{channel}/events/stream", app.withChannel(app.sseHandler))
 	mux.HandleFunc("GET /{channel}/stream/{streamID}/{type}/{filename}", app.withChannel(app.streamHandler))
 	mux.HandleFunc("GET /{channel}/download/{streamID}/{type}/{filename}", app.withChannel(app.downloadHandler))
 	mux.HandleFunc("GET /{channel}/frame/{streamID}/{filename}", app.withChannel(app.getFrameHandler))
+	mux.HandleFunc("GET /{channel}/peaks/{streamID}", app.withChannel(app.getPeaksHandler))
+	mux.HandleFunc("GET /{channel}/hls/{streamID}/playlist.m3u8", app.withChannel(app.getHlsPlaylistHandler))
 	mux.HandleFunc("GET /{channel}/transcript/{streamID}", app.withChannel(app.getTranscriptHandler))
+	mux.HandleFunc("GET /{channel}/search", app.withChannel(app.searchHandler))
 	mux.HandleFunc("POST /{channel}/clip", app.withChannel(app.postClipHandler))
 	mux.HandleFunc("POST /{channel}/trim", app.withChannel(app.postTrimHandler))
+	mux.HandleFunc("GET /{channel}/clips/{streamID}", app.withChannel(app.getClipsHandler))
+	mux.HandleFunc("GET /{channel}/clip/{clipID}", app.withChannel(app.getClipHandler))
+	mux.HandleFunc("GET /{channel}/clip/job/{id}", app.withChannel(app.getClipJobHandler))
 }
 
 // channelHandler is an http.HandlerFunc that additionally receives the
@@ -136,7 +147,7 @@ func CorsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Admin-Key, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Admin-Key, Authorization, Last-Event-ID")
 		// Expose headers so clients can read them (e.g. filename from Content-Disposition)
 		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
 		// Cache the preflight response for 24 hours to reduce OPTIONS requests
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"live-transcript-server/internal/config"
+	"live-transcript-server/internal/media"
 	"live-transcript-server/internal/metrics"
 	"live-transcript-server/internal/model"
 	"live-transcript-server/internal/storage"
@@ -72,9 +74,7 @@ func TestServer_ActivateDeactivate(t *testing.T) {
 		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"text": "Third"}]`)},
 		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "Second"}]`)},
 	}
-	if err := app.Store.ReplaceTranscript(ctx, key, "stream1", lines); err != nil {
-		t.Fatalf("failed to replace transcript: %v", err)
-	}
+	seedTranscript(t, app, key, "stream1", lines)
 	// Verify transcripts are there
 	transcripts, err := app.Store.GetTranscript(ctx, key, "stream1")
 	if err != nil {
@@ -328,6 +328,187 @@ func TestServer_LineUpdate(t *testing.T) {
 	}
 }
 
+func TestServer_LineRetry(t *testing.T) {
+	key := "test-channel-line-retry"
+	app, mux := setupTestApp(t, []string{key})
+	app.activateStream(context.Background(), app.Channels[key], "stream1", "Retry", fmt.Sprintf("%d", time.Now().Unix()), "audio")
+
+	post := func(line model.Line) int {
+		body, _ := json.Marshal(line)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/%s/line/stream1", key), bytes.NewBuffer(body))
+		req.Header.Set("X-API-Key", app.ApiKey)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	line := model.Line{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hello"}]`)}
+	if code := post(line); code != http.StatusOK {
+		t.Fatalf("first send: expected 200, got %d", code)
+	}
+
+	duplicates := testutil.ToFloat64(metrics.DuplicateLines)
+	if code := post(line); code != http.StatusOK {
+		t.Errorf("identical resend: expected 200, got %d", code)
+	}
+	if got := testutil.ToFloat64(metrics.DuplicateLines); got != duplicates+1 {
+		t.Errorf("expected duplicate metric to increase by 1, got %v -> %v", duplicates, got)
+	}
+
+	conflicts, oos := testutil.ToFloat64(metrics.ConflictingLines), testutil.ToFloat64(metrics.ServerOOS)
+	line.Segments = json.RawMessage(`[{"timestamp": 100, "text": "Different"}]`)
+	if code := post(line); code != http.StatusConflict {
+		t.Errorf("conflicting resend: expected 409, got %d", code)
+	}
+	if testutil.ToFloat64(metrics.ConflictingLines) != conflicts+1 || testutil.ToFloat64(metrics.ServerOOS) != oos+1 {
+		t.Error("expected conflict and OOS metrics to increase")
+	}
+
+	if lines, _ := app.Store.GetTranscript(context.Background(), key, "stream1"); len(lines) != 1 {
+		t.Errorf("expected 1 stored line, got %d", len(lines))
+	}
+}
+
+func TestServer_LinesBatch(t *testing.T) {
+	key := "test-channel-lines"
+	app, mux := setupTestApp(t, []string{key})
+	app.activateStream(context.Background(), app.Channels[key], "stream1", "Batch", fmt.Sprintf("%d", time.Now().Unix()), "audio")
+
+	post := func(ids ...int) (*httptest.ResponseRecorder, LinesResponse) {
+		var lines []model.Line
+		for _, id := range ids {
+			lines = append(lines, model.Line{ID: id, Timestamp: 100 * id, Segments: json.RawMessage(`[]`)})
+		}
+		body, _ := json.Marshal(lines)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/%s/lines/stream1", key), bytes.NewBuffer(body))
+		req.Header.Set("X-API-Key", app.ApiKey)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		var resp LinesResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr, resp
+	}
+
+	rr, resp := post(0, 1, 2)
+	if rr.Code != http.StatusOK || resp.Accepted != 3 || resp.LastLineID != 2 || resp.OutOfSyncAt != nil {
+		t.Fatalf("full batch: got %d %+v", rr.Code, resp)
+	}
+
+	// Partial success: 3 and 4 are stored, 6 is where the batch broke.
+	rr, resp = post(3, 4, 6, 7)
+	if rr.Code != http.StatusConflict || resp.Accepted != 2 || resp.LastLineID != 4 {
+		t.Fatalf("partial batch: got %d %+v", rr.Code, resp)
+	}
+	if resp.OutOfSyncAt == nil || *resp.OutOfSyncAt != 5 || resp.ReceivedLineID == nil || *resp.ReceivedLineID != 6 {
+		t.Errorf("partial batch: unexpected out-of-sync report %+v", resp)
+	}
+	if resp.Message != "accepted through line 4, out of sync at 5" {
+		t.Errorf("unexpected message %q", resp.Message)
+	}
+
+	// A retried batch reports the stored lines as duplicates.
+	rr, resp = post(3, 4)
+	if rr.Code != http.StatusOK || resp.Accepted != 0 || resp.Duplicates != 2 || resp.LastLineID != 4 {
+		t.Errorf("retried batch: got %d %+v", rr.Code, resp)
+	}
+
+	// Nothing accepted still reports the stored position.
+	rr, resp = post(9)
+	if rr.Code != http.StatusConflict || resp.Accepted != 0 || resp.LastLineID != 4 || *resp.OutOfSyncAt != 5 {
+		t.Errorf("rejected batch: got %d %+v", rr.Code, resp)
+	}
+
+	lines, _ := app.Store.GetTranscript(context.Background(), key, "stream1")
+	if len(lines) != 5 || lines[4].ID != 4 {
+		t.Errorf("expected lines 0-4 stored, got %d", len(lines))
+	}
+
+	if rr, _ := post(); rr.Code != http.StatusBadRequest {
+		t.Errorf("empty batch: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestServer_StatusCheckChecksum(t *testing.T) {
+	key := "test-statuscheck-checksum"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	get := func(query string, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/"+key+"/statuscheck"+query, nil)
+		req.Header.Set("X-API-Key", app.ApiKey)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Without asking for JSON the response is unchanged.
+	if rr := get("", ""); rr.Code != http.StatusOK || rr.Body.String() != "Current number of clients: 0" {
+		t.Errorf("plain statuscheck: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr := get("", "application/json")
+	var resp StatusCheckResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rr.Body.String())
+	}
+	lines, _ := app.Store.GetTranscript(context.Background(), key, "stream-1")
+	if resp.StreamID != "stream-1" || resp.LastLineID != 1 || resp.Checksum != transcriptChecksum(lines) || resp.InSync != nil {
+		t.Fatalf("unexpected status: %+v", resp)
+	}
+
+	check := func(streamID string, lastLineID int, checksum string) StatusCheckResponse {
+		t.Helper()
+		rr := get(fmt.Sprintf("?streamId=%s&lastLineId=%d&checksum=%s", streamID, lastLineID, checksum), "")
+		var resp StatusCheckResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.InSync == nil {
+			t.Fatalf("decode: %v (%s)", err, rr.Body.String())
+		}
+		return resp
+	}
+
+	if resp := check("stream-1", 1, resp.Checksum); !*resp.InSync {
+		t.Errorf("expected in sync, got %+v", resp)
+	}
+
+	drift := testutil.ToFloat64(metrics.WorkerDrift.WithLabelValues(key, "checksum"))
+	if resp := check("stream-1", 1, "deadbeef"); *resp.InSync || resp.Drift != "checksum" {
+		t.Errorf("expected checksum drift, got %+v", resp)
+	}
+	if got := testutil.ToFloat64(metrics.WorkerDrift.WithLabelValues(key, "checksum")); got != drift+1 {
+		t.Errorf("expected drift metric to increase, got %v -> %v", drift, got)
+	}
+	if resp := check("stream-1", 0, resp.Checksum); resp.Drift != "lines" {
+		t.Errorf("expected lines drift, got %+v", resp)
+	}
+	if resp := check("other", 1, resp.Checksum); resp.Drift != "stream" {
+		t.Errorf("expected stream drift, got %+v", resp)
+	}
+
+	if rr := get("?streamId=stream-1&lastLineId=x", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad lastLineId, got %d", rr.Code)
+	}
+}
+
+func TestTranscriptChecksum(t *testing.T) {
+	a := []model.Line{{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hello"}]`)}}
+	// Encoding differences do not change the checksum; content does.
+	b := []model.Line{{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text":"Hello","timestamp":100}]`)}}
+	c := []model.Line{{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hellp"}]`)}}
+	if transcriptChecksum(a) != transcriptChecksum(b) {
+		t.Error("expected re-encoded segments to hash the same")
+	}
+	if transcriptChecksum(a) == transcriptChecksum(c) {
+		t.Error("expected changed text to change the checksum")
+	}
+	// Known value, so the documented format stays what workers implement.
+	if got, want := transcriptChecksum(a), fmt.Sprintf("%x", sha256.Sum256([]byte("0\x1f100\x1fHello\x1e"))); got != want {
+		t.Errorf("checksum = %s, want %s", got, want)
+	}
+}
+
 func TestServer_MediaUpload(t *testing.T) {
 	key := "test-channel-media"
 	app, mux := setupTestApp(t, []string{key})
@@ -390,6 +571,10 @@ func TestServer_MediaUpload(t *testing.T) {
 	if lines[0].FileID == "" {
 		t.Error("expected fileID to be set")
 	}
+	// The chunk's probed metadata rides along with the line.
+	if want := (model.ChunkMedia{Duration: 10, Codec: "aac", SizeBytes: int64(len("dummy audio data"))}); lines[0].ChunkMedia != want {
+		t.Errorf("chunk media = %+v, want %+v", lines[0].ChunkMedia, want)
+	}
 
 	// Check file existence
 	fileID := lines[0].FileID
@@ -397,6 +582,71 @@ func TestServer_MediaUpload(t *testing.T) {
 	if _, err := os.Stat(rawPath); os.IsNotExist(err) {
 		t.Errorf("expected raw file to exist at %s", rawPath)
 	}
+	peaksPath := filepath.Join(app.Channels[key].BaseMediaFolder, "stream_media", "peaks", fileID+".json")
+	if data, err := os.ReadFile(peaksPath); err != nil || string(data) != "[0,100]" {
+		t.Errorf("expected the fake peaks at %s, got %q (err %v)", peaksPath, data, err)
+	}
+}
+
+func TestServer_MediaUploadEncodingProfile(t *testing.T) {
+	key := "test-channel-media-profile"
+	app, mux := setupTestApp(t, []string{key})
+	ctx := context.Background()
+
+	opus := media.AudioProfile{Codec: media.CodecOpus, BitrateKbps: 24, Channels: 1}
+	app.Channels[key].AudioProfile = opus
+	encoded := make(chan media.AudioProfile, 1)
+	app.Media = fakeProcessor{encode: func(out string, profile media.AudioProfile) { encoded <- profile }}
+
+	if err := app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: "s1", IsLive: true, MediaType: "audio"}); err != nil {
+		t.Fatalf("failed to insert stream: %v", err)
+	}
+	app.Store.InsertNextLine(ctx, key, "s1", model.Line{ID: 0, Timestamp: 100})
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "test.raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("dummy audio data"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/%s/media/s1/0", key), body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-API-Key", app.ApiKey)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %v body: %s", rr.Code, rr.Body.String())
+	}
+	if got := <-encoded; got != opus {
+		t.Errorf("encoded with %+v, want %+v", got, opus)
+	}
+
+	// The audio is stored and served as opus, and the stream says so.
+	lines, err := app.Store.GetTranscript(ctx, key, "s1")
+	if err != nil || len(lines) != 1 {
+		t.Fatalf("failed to get transcript: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", fmt.Sprintf("/%s/stream/s1/audio/%s.opus", key, lines[0].FileID), nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "audio/ogg" {
+		t.Errorf("stream: expected 200 audio/ogg, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	stream, err := app.Store.GetStreamByID(ctx, key, "s1")
+	if err != nil || stream == nil {
+		t.Fatalf("failed to get stream: %v", err)
+	}
+	if stream.AudioFormat != ".opus" {
+		t.Errorf("AudioFormat = %q, want .opus", stream.AudioFormat)
+	}
+
+	// A profile change does not switch a stream's format midway.
+	app.Channels[key].AudioProfile = media.AudioProfile{}
+	if got, err := app.Store.ClaimStreamAudioFormat(ctx, key, "s1", ".m4a"); err != nil || got != ".opus" {
+		t.Errorf("reclaim = %q, %v; want .opus", got, err)
+	}
 }
 
 func TestServer_Sync(t *testing.T) {
@@ -625,13 +875,13 @@ func TestServer_GetTranscriptEndpoint(t *testing.T) {
 	// Seed data
 	stream1ID := "stream1"
 	app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: stream1ID, IsLive: false})
-	app.Store.ReplaceTranscript(ctx, key, stream1ID, []model.Line{
+	seedTranscript(t, app, key, stream1ID, []model.Line{
 		{ID: 0, Segments: json.RawMessage(`[{"text": "Stream 1 Line 0"}]`)},
 	})
 
 	stream2ID := "stream2"
 	app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: stream2ID, IsLive: true})
-	app.Store.ReplaceTranscript(ctx, key, stream2ID, []model.Line{
+	seedTranscript(t, app, key, stream2ID, []model.Line{
 		{ID: 0, Segments: json.RawMessage(`[{"text": "Stream 2 Line 0"}]`)},
 	})
 
@@ -686,6 +936,174 @@ func TestServer_GetTranscriptEndpoint(t *testing.T) {
 	}
 }
 
+func TestServer_SearchEndpoint(t *testing.T) {
+	key := "test-search-endpoint"
+	app, mux := setupTestApp(t, []string{key})
+	ctx := context.Background()
+
+	app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: "stream1"})
+	seedTranscript(t, app, key, "stream1", []model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text": "nothing to see"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "a memorable quote"}]`)},
+		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"text": "another memorable line"}]`)},
+	})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/"+key+"/search"+query, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("?q=memorable+quote")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp SearchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %+v", resp.Results)
+	}
+	hit := resp.Results[0]
+	if hit.StreamID != "stream1" || hit.LineID != 1 || hit.Timestamp != 200 || !strings.Contains(hit.Snippet, "<mark>quote</mark>") {
+		t.Errorf("unexpected hit: %+v", hit)
+	}
+
+	// limit caps the result count.
+	rr = get("?q=memorable&limit=1")
+	resp = SearchResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || len(resp.Results) != 1 || resp.Results[0].LineID != 1 {
+		t.Errorf("expected only line 1 with limit=1, got %d %+v", rr.Code, resp.Results)
+	}
+
+	// No match is an empty array, not null.
+	rr = get("?q=absent")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"results":[]`) {
+		t.Errorf("expected empty results, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	for _, query := range []string{"", "?q=+", "?q=x&limit=0", "?q=x&limit=abc", "?q=" + strings.Repeat("x", maxSearchQueryLength+1)} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("query %q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestServer_TranscriptExportFormats(t *testing.T) {
+	key := "test-transcript-export"
+	app, mux := setupTestApp(t, []string{key})
+	ctx := context.Background()
+
+	app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: "stream1", StreamTitle: "Big: Stream/Title", StartTime: "1000"})
+	seedTranscript(t, app, key, "stream1", []model.Line{
+		{ID: 0, Timestamp: 1000, Segments: json.RawMessage(`[{"timestamp": 1000, "text": "Hello world"}, {"timestamp": 1002, "text": "again"}]`)},
+		{ID: 1, Timestamp: 1065, Segments: json.RawMessage(`[{"timestamp": 1065, "text": "later"}]`)},
+	})
+
+	get := func(query string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/"+key+"/transcript/stream1"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The default fetch is unchanged: inline JSON.
+	rr := get("", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Disposition") != "" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("default fetch: got %d %v", rr.Code, rr.Header())
+	}
+
+	rr = get("?format=srt", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("srt: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if want := "1\n00:00:00,000 --> 00:00:02,000\nHello world\n\n2\n00:00:02,000 --> 00:00:12,000\nagain\n\n3\n00:01:05,000 --> 00:01:10,000\nlater\n\n"; rr.Body.String() != want {
+		t.Errorf("srt body:\n%q\nwant\n%q", rr.Body.String(), want)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="Big-Stream-Title.srt"` {
+		t.Errorf("unexpected srt Content-Disposition: %q", cd)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/x-subrip") {
+		t.Errorf("unexpected srt Content-Type: %q", ct)
+	}
+
+	// Accept picks the format when no param is given.
+	rr = get("", "text/vtt")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Body.String(), "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\nHello world\n") {
+		t.Errorf("vtt via Accept: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// The name param overrides the filename, sanitized as in downloadHandler.
+	rr = get("?format=txt&name=../my+notes", "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "[00:00:00] Hello world again\n[00:01:05] later\n" {
+		t.Errorf("txt: got %d %q", rr.Code, rr.Body.String())
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="-my-notes.txt"` {
+		t.Errorf("unexpected txt Content-Disposition: %q", cd)
+	}
+
+	// An explicit json format is an attachment with the same body.
+	rr = get("?format=json", "")
+	var lines []model.Line
+	if err := json.Unmarshal(rr.Body.Bytes(), &lines); err != nil || len(lines) != 2 {
+		t.Errorf("json export: %v %s", err, rr.Body.String())
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="Big-Stream-Title.json"` {
+		t.Errorf("unexpected json Content-Disposition: %q", cd)
+	}
+
+	if rr := get("?format=docx", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestServer_TranscriptAfter(t *testing.T) {
+	key := "test-transcript-after"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/"+key+"/transcript/stream-1"+query, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("?after=0")
+	var lines []model.Line
+	if err := json.Unmarshal(rr.Body.Bytes(), &lines); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rr.Body.String())
+	}
+	if rr.Code != http.StatusOK || len(lines) != 1 || lines[0].ID != 1 {
+		t.Errorf("expected only line 1, got %d %+v", rr.Code, lines)
+	}
+
+	// A caught-up client gets an empty array, not null.
+	if rr := get("?after=1"); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("expected [], got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// -1 is the whole transcript.
+	lines = nil
+	rr = get("?after=-1")
+	json.Unmarshal(rr.Body.Bytes(), &lines)
+	if len(lines) != 2 {
+		t.Errorf("expected 2 lines for after=-1, got %d", len(lines))
+	}
+
+	for _, query := range []string{"?after=abc", "?after=-2", "?after=1.5"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("query %q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
 func TestServer_GetTranscriptEndpointMetrics(t *testing.T) {
 	// The key is unique to this test so the per-key metrics are not shared
 	// with any other test in the package.
@@ -695,7 +1113,7 @@ func TestServer_GetTranscriptEndpointMetrics(t *testing.T) {
 
 	streamID := "stream1"
 	app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: streamID, IsLive: false})
-	app.Store.ReplaceTranscript(ctx, key, streamID, []model.Line{
+	seedTranscript(t, app, key, streamID, []model.Line{
 		{ID: 0, Segments: json.RawMessage(`[{"text": "Line 0"}]`)},
 		{ID: 1, Segments: json.RawMessage(`[{"text": "Line 1"}]`)},
 	})
@@ -957,13 +1375,9 @@ func TestServer_MediaEndpoints(t *testing.T) {
 	req.Header.Set("X-API-Key", apiKey)
 	rr = httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
-	if rr.Code != http.StatusOK {
-		t.Errorf("clipHandler: expected OK, got %v body: %s", rr.Code, rr.Body.String())
-	}
-	var clipResp map[string]string
-	json.NewDecoder(rr.Body).Decode(&clipResp)
-	if clipResp["clip_id"] == "" {
-		t.Error("clipHandler: expected clip_id in response")
+	clipJob := waitClipJob(t, mux, key, rr)
+	if clipJob.State != clipJobStateDone || clipJob.ClipID == "" {
+		t.Errorf("clipHandler: expected a done job with a clip_id, got %+v", clipJob)
 	}
 
 	// 6. Test trimHandler (POST)
@@ -980,19 +1394,15 @@ func TestServer_MediaEndpoints(t *testing.T) {
 	req.Header.Set("X-API-Key", apiKey)
 	rr = httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
-	if rr.Code != http.StatusOK {
-		t.Errorf("trimHandler: expected OK, got %v body: %s", rr.Code, rr.Body.String())
-	}
-	var trimResp map[string]string
-	json.NewDecoder(rr.Body).Decode(&trimResp)
-	if trimResp["status"] != "success" {
-		t.Errorf("trimHandler: expected success, got %s", trimResp["status"])
+	trimJob := waitClipJob(t, mux, key, rr)
+	if trimJob.State != clipJobStateDone {
+		t.Errorf("trimHandler: expected done, got %+v", trimJob)
 	}
-	if trimResp["clip_id"] == "" {
+	if trimJob.ClipID == "" {
 		t.Errorf("trimHandler: expected valid clip_id, got empty")
 	}
-	if trimResp["clip_id"] == "1" {
-		t.Errorf("trimHandler: expected new clip_id, got same as input '1'")
+	if trimJob.ClipID == "file_1" {
+		t.Errorf("trimHandler: expected new clip_id, got same as input 'file_1'")
 	}
 
 	// 7. Test clipHandler Missing Media
@@ -1024,12 +1434,7 @@ func TestServer_MediaEndpoints(t *testing.T) {
 	req.Header.Set("X-API-Key", apiKey)
 	rr = httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
-	if rr.Code != http.StatusOK {
-		t.Errorf("clipHandler mp4: expected OK, got %v body: %s", rr.Code, rr.Body.String())
-	}
-	var mp4Resp map[string]string
-	json.NewDecoder(rr.Body).Decode(&mp4Resp)
-	clipID := mp4Resp["clip_id"]
+	clipID := waitClipJob(t, mux, key, rr).ClipID
 	if clipID == "" {
 		t.Fatal("clipHandler mp4: expected clip_id")
 	}
@@ -1432,3 +1837,86 @@ func TestPostClipHandler_StreamID(t *testing.T) {
 		t.Errorf("Handler used wrong stream media type. Expected video (s1), got audio (s2 behavior).")
 	}
 }
+
+func TestServer_ClipCatalog(t *testing.T) {
+	key := "test-clip-catalog"
+	app, mux := setupTestApp(t, []string{key})
+	ctx := context.Background()
+
+	app.Store.UpsertStream(ctx, &model.Stream{ChannelID: key, StreamID: "s1", StreamTitle: "Stream 1", StartTime: "12345", IsLive: true, MediaType: "video"})
+	rawFolder := filepath.Join(app.Channels[key].BaseMediaFolder, "s1", "raw")
+	os.MkdirAll(rawFolder, 0755)
+	for i := 0; i <= 3; i++ {
+		fileID := fmt.Sprintf("file_%d", i)
+		app.Store.InsertNextLine(ctx, key, "s1", model.Line{ID: i, Timestamp: i * 1000, MediaAvailable: true, FileID: fileID, Segments: json.RawMessage(`[{"text": "test"}]`)})
+		os.WriteFile(filepath.Join(rawFolder, fileID+".raw"), []byte("raw_audio"), 0644)
+	}
+	app.Media = fakeProcessor{}
+
+	post := func(path string, body map[string]any) *httptest.ResponseRecorder {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/"+key+path, bytes.NewBuffer(b))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	get := func(path string, out any) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/"+key+path, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if out != nil && rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+				t.Fatalf("GET %s: decode: %v", path, err)
+			}
+		}
+		return rr.Code
+	}
+
+	clipID := waitClipJob(t, mux, key, post("/clip", map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "mp4", "title": "  Best bit  "})).ClipID
+	trimID := waitClipJob(t, mux, key, post("/trim", map[string]any{"stream_id": "s1", "clip_id": clipID, "file_format": "mp4", "start": 0.5, "end": 1.5})).ClipID
+
+	size := int64(len("converted")) // what fakeProcessor writes
+	wantClip := model.Clip{ClipID: clipID, StreamID: "s1", StartLine: 1, EndLine: 2, Format: "mp4", Title: "Best bit", SizeBytes: size, HasSidecar: true}
+	wantTrim := model.Clip{ClipID: trimID, StreamID: "s1", StartLine: 1, EndLine: 2, Format: "mp4", SourceClipID: clipID, SizeBytes: size}
+
+	var clips []model.Clip
+	if code := get("/clips/s1", &clips); code != http.StatusOK {
+		t.Fatalf("list clips: expected OK, got %v", code)
+	}
+	if len(clips) != 2 {
+		t.Fatalf("list clips: expected 2 clips, got %+v", clips)
+	}
+	for i, want := range []model.Clip{wantTrim, wantClip} {
+		got := clips[i]
+		if got.CreatedAt == 0 {
+			t.Errorf("clip %d: expected createdAt to be set", i)
+		}
+		got.CreatedAt = 0
+		if got != want {
+			t.Errorf("clip %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	var one model.Clip
+	if code := get("/clip/"+trimID, &one); code != http.StatusOK || one.SourceClipID != clipID {
+		t.Errorf("get clip: code %d, clip %+v", code, one)
+	}
+	if code := get("/clip/missing", nil); code != http.StatusNotFound {
+		t.Errorf("get unknown clip: expected 404, got %d", code)
+	}
+	if code := get("/clip/bad.id", nil); code != http.StatusBadRequest {
+		t.Errorf("get clip with bad id: expected 400, got %d", code)
+	}
+
+	var none []model.Clip
+	if code := get("/clips/s2", &none); code != http.StatusOK || none == nil || len(none) != 0 {
+		t.Errorf("list clips of a stream without clips: code %d, got %+v, want []", code, none)
+	}
+
+	rr := post("/clip", map[string]any{"stream_id": "s1", "start": 1, "end": 2, "type": "m4a", "title": strings.Repeat("x", maxClipTitleLength+1)})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("clip with overlong title: expected 400, got %v", rr.Code)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	"live-transcript-server/internal/config"
+	"live-transcript-server/internal/media"
 	"live-transcript-server/internal/model"
 	"live-transcript-server/internal/store"
 )
@@ -88,11 +90,50 @@ func seedExampleData(tb testing.TB, app *App, channelID string) {
 			Segments:  json.RawMessage(`[{"timestamp": 200, "text": "This is a test"}]`),
 		},
 	}
-	if err := app.Store.ReplaceTranscript(ctx, channelID, "stream-1", lines); err != nil {
+	seedTranscript(tb, app, channelID, "stream-1", lines)
+}
+
+// seedTranscript stores lines as a stream's transcript, as a worker's /sync
+// would.
+func seedTranscript(tb testing.TB, app *App, channelID, streamID string, lines []model.Line) {
+	tb.Helper()
+	if _, _, err := app.Store.SyncTranscript(context.Background(), channelID, streamID, lines); err != nil {
 		tb.Fatalf("failed to seed transcript: %v", err)
 	}
 }
 
+// waitClipJob takes the 202 response of a clip or trim request and polls the
+// job until it finishes, returning its final state.
+func waitClipJob(tb testing.TB, mux *http.ServeMux, channel string, rr *httptest.ResponseRecorder) ClipJobResponse {
+	tb.Helper()
+	if rr.Code != http.StatusAccepted {
+		tb.Fatalf("expected 202 for a queued clip job, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var job ClipJobResponse
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil || job.JobID == "" {
+		tb.Fatalf("expected a job in the response (err %v)", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State == clipJobStateQueued || job.State == clipJobStateRunning {
+		if time.Now().After(deadline) {
+			tb.Fatalf("clip job %s still %s after 5s", job.JobID, job.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+		req := httptest.NewRequest(http.MethodGet, "/"+channel+"/clip/job/"+job.JobID, nil)
+		poll := httptest.NewRecorder()
+		mux.ServeHTTP(poll, req)
+		if poll.Code != http.StatusOK {
+			tb.Fatalf("poll clip job %s: expected 200, got %d: %s", job.JobID, poll.Code, poll.Body.String())
+		}
+		job = ClipJobResponse{}
+		if err := json.NewDecoder(poll.Body).Decode(&job); err != nil {
+			tb.Fatalf("decode clip job: %v", err)
+		}
+	}
+	return job
+}
+
 // segment mirrors the worker's segment JSON for decoding line segments in
 // assertions.
 type segment struct {
@@ -109,40 +150,116 @@ type fakeProcessor struct {
 	remux   func(in, out string) error
 	trim    func(in, out string, start, end float64) error
 	frame   func(in, out string, height int) error
+	cut     func(in, out string, start, end float64, keepVideo bool) error
+	burn    func(in, subtitles, out string) error
+	embed   func(in, subtitles, out string) error
+	animate func(in, out string, maxWidth, fps int) error
+	peaks   func(in string) ([]int, error)
+	// encode observes the audio profile of every Convert and Cut call, and
+	// of every Remux and Trim call that re-encodes audio, before the call's
+	// own hook runs.
+	encode func(out string, profile media.AudioProfile)
+	// duration overrides the probed duration of every chunk, which is
+	// otherwise 10 seconds to match seedVodStream's line spacing.
+	duration func(in string) (float64, error)
 }
 
 func writePlaceholder(out string) error {
 	return os.WriteFile(out, []byte("converted"), 0644)
 }
 
-func (f fakeProcessor) Convert(in, out string) error {
+func (f fakeProcessor) Convert(ctx context.Context, in, out string, profile media.AudioProfile) error {
+	if f.encode != nil {
+		f.encode(out, profile)
+	}
 	if f.convert != nil {
 		return f.convert(in, out)
 	}
 	return writePlaceholder(out)
 }
 
-func (f fakeProcessor) Remux(in, out string) error {
+func (f fakeProcessor) Remux(ctx context.Context, in, out string, profile media.AudioProfile) error {
+	if f.encode != nil && profile != (media.AudioProfile{}) {
+		f.encode(out, profile)
+	}
 	if f.remux != nil {
 		return f.remux(in, out)
 	}
 	return writePlaceholder(out)
 }
 
-func (f fakeProcessor) Trim(in, out string, start, end float64) error {
+func (f fakeProcessor) Trim(ctx context.Context, in, out string, start, end float64, profile media.AudioProfile) error {
+	if f.encode != nil && profile != (media.AudioProfile{}) {
+		f.encode(out, profile)
+	}
 	if f.trim != nil {
 		return f.trim(in, out, start, end)
 	}
 	return writePlaceholder(out)
 }
 
-func (f fakeProcessor) ExtractFrame(in, out string, height int) error {
+func (f fakeProcessor) ExtractFrame(ctx context.Context, in, out string, height int) error {
 	if f.frame != nil {
 		return f.frame(in, out, height)
 	}
 	return writePlaceholder(out)
 }
 
+func (f fakeProcessor) Cut(ctx context.Context, in, out string, start, end float64, keepVideo bool, profile media.AudioProfile) error {
+	if f.encode != nil {
+		f.encode(out, profile)
+	}
+	if f.cut != nil {
+		return f.cut(in, out, start, end, keepVideo)
+	}
+	return writePlaceholder(out)
+}
+
+func (f fakeProcessor) BurnSubtitles(ctx context.Context, in, subtitles, out string) error {
+	if f.burn != nil {
+		return f.burn(in, subtitles, out)
+	}
+	return writePlaceholder(out)
+}
+
+func (f fakeProcessor) EmbedSubtitles(ctx context.Context, in, subtitles, out string) error {
+	if f.embed != nil {
+		return f.embed(in, subtitles, out)
+	}
+	return writePlaceholder(out)
+}
+
+func (f fakeProcessor) Animate(ctx context.Context, in, out string, maxWidth, fps int) error {
+	if f.animate != nil {
+		return f.animate(in, out, maxWidth, fps)
+	}
+	return writePlaceholder(out)
+}
+
+// Peaks returns one quiet and one loud peak unless the peaks hook is set.
+func (f fakeProcessor) Peaks(ctx context.Context, in string) ([]int, error) {
+	if f.peaks != nil {
+		return f.peaks(in)
+	}
+	return []int{0, 100}, nil
+}
+
+func (f fakeProcessor) Duration(ctx context.Context, in string) (float64, error) {
+	if f.duration != nil {
+		return f.duration(in)
+	}
+	return 10, nil
+}
+
+// Probe reports AAC audio lasting the probed duration.
+func (f fakeProcessor) Probe(ctx context.Context, in string) (media.ProbeInfo, error) {
+	d, err := f.Duration(ctx, in)
+	if err != nil {
+		return media.ProbeInfo{}, err
+	}
+	return media.ProbeInfo{Duration: d, Codec: "aac"}, nil
+}
+
 // waitFor polls cond every 10ms until it returns true or the timeout elapses,
 // failing the test on timeout. Replaces the hand-rolled poll loops the suite
 // accumulated.
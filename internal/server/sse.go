@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"live-transcript-server/internal/metrics"
+	"live-transcript-server/internal/ws"
+)
+
+// sseKeepAliveInterval is how often an idle event stream gets a comment line,
+// so proxies that drop quiet connections leave it open.
+const sseKeepAliveInterval = 30 * time.Second
+
+// sseHandler streams the channel's hub messages as Server-Sent Events, for
+// clients whose network blocks WebSocket upgrades. Each event's name is the
+// ws.EventType and its data is the same JSON message a WebSocket client would
+// receive, so clients can share one message handler between transports.
+//
+// Events that carry a resume token (sync, newLine, linesChanged, updatedLine,
+// resumed) use it as their event ID. A reconnecting EventSource sends it back
+// as Last-Event-ID (or the client passes ?resume=), and gets the same delta a
+// WebSocket resume does.
+//
+// SSE clients share the hub's connection cap and connection metrics.
+func (app *App) sseHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	if !cs.Hub.Reserve() {
+		http.Error(w, "Max number of connection already reached", http.StatusBadRequest)
+		slog.Error("max number of connections already reached", "key", cs.Key, "func", "sseHandler", "maxConn", app.MaxConn)
+		metrics.WebsocketError.Inc()
+		return
+	}
+
+	startTime := time.Now()
+	client := cs.Hub.AddListener()
+	defer func() {
+		metrics.ConnectionDuration.Observe(time.Since(startTime).Seconds())
+		cs.Hub.Remove(client)
+	}()
+
+	rc := http.NewResponseController(w)
+	// The stream outlives any server-wide write timeout.
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Ask nginx-style proxies not to buffer the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("event stream cannot be flushed", "key", cs.Key, "func", "sseHandler", "err", err)
+		return
+	}
+
+	token := r.Header.Get("Last-Event-ID")
+	if token == "" {
+		token = r.URL.Query().Get("resume")
+	}
+	if token == "" || !app.resumeClient(r.Context(), cs, client, token) {
+		app.syncClient(r.Context(), cs, client)
+	}
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case msg := <-client.Messages():
+			err = writeSSE(w, msg)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-client.Done():
+			return
+		case <-r.Context().Done():
+			return
+		case <-app.ctx.Done():
+			return
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			if !ws.IsClientDisconnectError(err) {
+				slog.Error("failed to write event to client", "key", cs.Key, "func", "sseHandler", "err", err)
+				metrics.WebsocketError.Inc()
+			}
+			return
+		}
+	}
+}
+
+// writeSSE writes one message as an event-stream event.
+func writeSSE(w http.ResponseWriter, msg ws.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if id := sseEventID(msg); id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	// json.Marshal escapes newlines inside strings, so data is one line.
+	fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", msg.Event, data)
+	_, err = fmt.Fprint(w, b.String())
+	return err
+}
+
+// sseEventID returns the resume token carried by msg, or "" for events that
+// do not move the client's position. EventSource keeps the last ID it saw, so
+// events without one leave the reconnect point unchanged.
+func sseEventID(msg ws.Message) string {
+	switch data := msg.Data.(type) {
+	case ws.EventSyncData:
+		return data.ResumeToken
+	case *ws.EventSyncData:
+		return data.ResumeToken
+	case ws.EventNewLineData:
+		return data.ResumeToken
+	case ws.EventResumedData:
+		return data.ResumeToken
+	case ws.EventLinesChangedData:
+		return data.ResumeToken
+	case ws.EventUpdatedLineData:
+		return data.ResumeToken
+	}
+	return ""
+}
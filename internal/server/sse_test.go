@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"live-transcript-server/internal/model"
+	"live-transcript-server/internal/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+// sseEvent is one parsed event-stream event.
+type sseEvent struct {
+	id    string
+	event string
+	msg   ws.Message
+}
+
+// openSSE connects to the channel's event stream and returns a function that
+// reads the next event, skipping keep-alive comments.
+func openSSE(t *testing.T, url string, lastEventID string) (*http.Response, func() sseEvent) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	next := func() sseEvent {
+		t.Helper()
+		var ev sseEvent
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatal("event stream closed")
+				}
+				switch {
+				case line == "":
+					if ev.event != "" {
+						return ev
+					}
+				case strings.HasPrefix(line, "id: "):
+					ev.id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					ev.event = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.msg); err != nil {
+						t.Fatalf("bad event data %q: %v", line, err)
+					}
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for event")
+			}
+		}
+	}
+	return resp, next
+}
+
+func TestSSEStream(t *testing.T) {
+	key := "test-sse-stream"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	// Registered before openSSE's cleanup so the stream is closed first:
+	// server.Close waits for in-flight requests.
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	resp, next := openSSE(t, server.URL+"/"+key+"/events/stream", "")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	ev := next()
+	if ev.event != string(ws.EventSync) || ev.msg.Event != ws.EventSync {
+		t.Fatalf("expected sync first, got %+v", ev)
+	}
+	if ev.id != app.Channels[key].resumeToken("stream-1", 1) {
+		t.Errorf("expected sync id to be the resume token, got %q", ev.id)
+	}
+
+	cs := app.Channels[key]
+	if got := cs.Hub.Connections(); got != 1 {
+		t.Errorf("expected the SSE client to hold a hub slot, got %d", got)
+	}
+}
+
+func TestSSEBroadcastAndResume(t *testing.T) {
+	key := "test-sse-resume"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+	cs := app.Channels[key]
+
+	// Registered before openSSE's cleanup so the stream is closed first:
+	// server.Close waits for in-flight requests.
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	url := server.URL + "/" + key + "/events/stream"
+
+	_, next := openSSE(t, url, "")
+	if ev := next(); ev.event != string(ws.EventSync) {
+		t.Fatalf("expected sync, got %+v", ev)
+	}
+
+	line := &model.Line{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"timestamp": 300, "text": "live"}]`)}
+	if err := app.Store.InsertNextLine(context.Background(), key, "stream-1", *line); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	app.broadcastNewLine(context.Background(), cs, "stream-1", 0, line)
+
+	ev := next()
+	if ev.event != string(ws.EventNewLine) || ev.id != app.Channels[key].resumeToken("stream-1", 2) {
+		t.Fatalf("expected newLine with id, got %+v", ev)
+	}
+
+	// Reconnecting with the last event ID gets only the delta.
+	if err := app.Store.InsertNextLine(context.Background(), key, "stream-1", model.Line{ID: 3, Timestamp: 400, Segments: json.RawMessage(`[]`)}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	_, next = openSSE(t, url, ev.id)
+	var events []string
+	for {
+		ev := next()
+		events = append(events, ev.event)
+		if ev.event == string(ws.EventResumed) {
+			if ev.id != app.Channels[key].resumeToken("stream-1", 3) {
+				t.Errorf("unexpected resumed id %q", ev.id)
+			}
+			break
+		}
+	}
+	if want := "updatedStream status newLine resumed"; strings.Join(events, " ") != want {
+		t.Errorf("expected %q, got %q", want, strings.Join(events, " "))
+	}
+}
+
+func TestSSESharesConnectionCap(t *testing.T) {
+	key := "test-sse-cap"
+	app, mux := setupTestApp(t, []string{key})
+	app.Channels[key].Hub = ws.NewHub(key, 1)
+	cs := app.Channels[key]
+
+	// Registered before openSSE's cleanup so the stream is closed first:
+	// server.Close waits for in-flight requests.
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	resp, next := openSSE(t, server.URL+"/"+key+"/events/stream", "")
+	next() // sync
+	waitFor(t, time.Second, "SSE client to register", func() bool {
+		return cs.Hub.ClientCount() == 1
+	})
+
+	// The slot is taken, so a WebSocket client is turned away...
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + key + "/websocket"
+	if _, _, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil {
+		t.Error("expected WebSocket dial to fail while SSE holds the only slot")
+	}
+	// ...and so is another SSE client.
+	if resp2, _ := openSSE(t, server.URL+"/"+key+"/events/stream", ""); resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for second SSE client, got %d", resp2.StatusCode)
+	}
+
+	// Disconnecting releases the slot.
+	resp.Body.Close()
+	waitFor(t, time.Second, "slot to be released", func() bool {
+		return cs.Hub.Connections() == 0 && cs.Hub.ClientCount() == 0
+	})
+}
@@ -155,7 +155,8 @@ type AdminVodResponse struct {
 	Phase string `json:"phase,omitempty"`
 	// Error is the failure message of the last build, when state is "failed".
 	Error string `json:"error,omitempty"`
-	// Format is the container the VOD is rendered into ("mp4" or "m4a").
+	// Format is the container the VOD is rendered into ("mp4", or the
+	// channel's VOD encoding profile's "m4a" or "opus").
 	Format string `json:"format"`
 	// TotalLines is the transcript's length; MediaLines is how many of those
 	// lines have media that can go into the VOD, and MissingLines is the gap.
@@ -172,14 +173,15 @@ type AdminVodResponse struct {
 }
 
 // vodExtension picks the container a stream renders into: video streams are
-// remuxed to mp4, everything else is encoded to m4a — the same split the clip
-// endpoint makes. An empty return means the stream has no media at all.
-func vodExtension(stream *model.Stream) string {
+// remuxed to mp4, everything else is encoded per the VOD encoding profile —
+// the same split the clip endpoint makes. An empty return means the stream
+// has no media at all.
+func vodExtension(stream *model.Stream, profile media.AudioProfile) string {
 	switch stream.MediaType {
 	case "video":
 		return ".mp4"
 	case "audio":
-		return ".m4a"
+		return profile.Ext()
 	default:
 		return ""
 	}
@@ -192,6 +194,9 @@ type vodTarget struct {
 	ext        string
 	totalLines int
 	mediaLines int
+	// normalize loudness-normalises the build's audio. Only set for the
+	// target of a build request.
+	normalize bool
 }
 
 // resolveVodTarget validates the stream in the request path and loads its line
@@ -223,7 +228,7 @@ func (app *App) resolveVodTarget(w http.ResponseWriter, r *http.Request, cs *Cha
 		return vodTarget{}, false
 	}
 
-	ext := vodExtension(stream)
+	ext := vodExtension(stream, cs.VodProfile)
 	if ext == "" {
 		http.Error(w, "This stream has no media stored, so it has no VOD to build.", http.StatusConflict)
 		return vodTarget{}, false
@@ -357,11 +362,26 @@ func (app *App) getAdminVodHandler(w http.ResponseWriter, r *http.Request, cs *C
 // postAdminVodHandler starts a full-VOD build, or joins the one already in
 // flight. It never produces a second copy: an existing artifact is returned
 // as-is, and a running build is reported back rather than duplicated.
+//
+// The normalize query param (true or false) overrides the channel's
+// normalizeLoudness default for a build this request starts.
 func (app *App) postAdminVodHandler(w http.ResponseWriter, r *http.Request, cs *ChannelState) {
+	var normalize *bool
+	if v := r.URL.Query().Get("normalize"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid normalize value", http.StatusBadRequest)
+			metrics.Http400Errors.Inc()
+			return
+		}
+		normalize = &parsed
+	}
+
 	target, ok := app.resolveVodTarget(w, r, cs)
 	if !ok {
 		return
 	}
+	target.normalize = wantsNormalize(cs, normalize)
 	streamID := target.stream.StreamID
 
 	// Nothing to stitch together.
@@ -415,6 +435,7 @@ func (app *App) postAdminVodHandler(w http.ResponseWriter, r *http.Request, cs *
 			discord.AdminField{Name: "Stream ID", Value: streamID, Inline: true},
 			discord.AdminField{Name: "Format", Value: target.ext[1:], Inline: true},
 			discord.AdminField{Name: "Chunks", Value: strconv.Itoa(len(fileIDs)), Inline: true},
+			discord.AdminField{Name: "Normalized", Value: strconv.FormatBool(target.normalize), Inline: true},
 			discord.AdminField{Name: "Lines Without Media", Value: strconv.Itoa(max(target.totalLines-target.mediaLines, 0)), Inline: true},
 			discord.AdminField{Name: "Stream Title", Value: target.stream.StreamTitle},
 		)
@@ -442,9 +463,10 @@ func (app *App) postAdminVodHandler(w http.ResponseWriter, r *http.Request, cs *
 // stream's VOD container, and uploads it under the stream's fixed VOD key.
 //
 // It runs detached from both the request and app.wg: a build takes minutes on
-// a long stream, and neither a client disconnect nor a shutdown should be able
-// to interrupt shutdown waiting on ffmpeg. Cancellation still reaches the
-// downloads through app.ctx, and because the upload is the last step and is
+// a long stream, and neither a client disconnect should interrupt it nor a
+// shutdown wait on it. Cancellation still reaches the downloads and ffmpeg
+// through app.ctx, which kills ffmpeg on shutdown, and the encoding is
+// bounded by app.VodTimeout. Because the upload is the last step and is
 // atomic, an interrupted build simply leaves no artifact to find.
 func (app *App) buildVod(job *vodJob, cs *ChannelState, target vodTarget, fileIDs []string) {
 	streamID := target.stream.StreamID
@@ -474,13 +496,18 @@ func (app *App) buildVod(job *vodJob, cs *ChannelState, target vodTarget, fileID
 	defer os.Remove(mergedRawPath)
 
 	job.setPhase(vodPhaseEncoding)
+	encodeCtx, cancel := context.WithTimeout(app.ctx, app.VodTimeout)
+	defer cancel()
 	tempOut := filepath.Join(app.TempDir, tempName+ext)
-	// Video only needs a container rewrite; audio has to be re-encoded to m4a
-	// or the result is broken. Same rule as clip creation.
+	// Video only needs a container rewrite; audio has to be re-encoded or the
+	// result is broken. Same rule as clip creation. Either way the audio is
+	// normalised in the same pass.
+	profile := cs.VodProfile
+	profile.Normalize = target.normalize
 	if ext == ".mp4" {
-		err = app.Media.Remux(mergedRawPath, tempOut)
+		err = app.Media.Remux(encodeCtx, mergedRawPath, tempOut, profile.For(ext))
 	} else {
-		err = app.Media.Convert(mergedRawPath, tempOut)
+		err = app.Media.Convert(encodeCtx, mergedRawPath, tempOut, profile.For(ext))
 	}
 	if err != nil {
 		os.Remove(tempOut)
@@ -506,3 +533,95 @@ func (app *App) buildVod(job *vodJob, cs *ChannelState, target vodTarget, fileID
 		discord.AdminField{Name: "Stream Title", Value: target.stream.StreamTitle},
 	)
 }
+
+// scheduleAutoVod builds a just-ended stream's full VOD once the channel's
+// AutoVodDelay has passed, so archivists no longer have to remember the
+// button before the raw chunks expire. The delay lets the worker finish
+// uploading the stream's last chunks. It does nothing for channels without
+// automatic builds.
+//
+// The wait is tracked by app.wg and ends with a shutdown, so a build still
+// waiting when the server restarts is not made; an admin can start it by
+// hand. The build itself runs detached, like one an admin starts.
+func (app *App) scheduleAutoVod(cs *ChannelState, streamID string) {
+	if cs.AutoVodDelay <= 0 {
+		return
+	}
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		timer := time.NewTimer(cs.AutoVodDelay)
+		defer timer.Stop()
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if err := app.startAutoVod(app.ctx, cs, streamID); err != nil {
+			slog.Error("failed to start automatic vod build", "key", cs.Key, "func", "scheduleAutoVod", "streamID", streamID, "err", err)
+		}
+	}()
+}
+
+// startAutoVod starts a stream's full-VOD build on its own, with the same
+// guarantees as postAdminVodHandler: a stream without media, one whose VOD
+// already exists and one whose build is already running are left alone. So is
+// a stream that went live again or was deleted during the delay. The build
+// uses the channel's normalizeLoudness default.
+func (app *App) startAutoVod(ctx context.Context, cs *ChannelState, streamID string) error {
+	stream, err := app.Store.GetStreamByID(ctx, cs.Key, streamID)
+	if err != nil {
+		return fmt.Errorf("look up stream: %w", err)
+	}
+	if stream == nil || stream.IsLive {
+		return nil
+	}
+	ext := vodExtension(stream, cs.VodProfile)
+	if ext == "" {
+		return nil
+	}
+
+	total, withMedia, err := app.Store.CountTranscriptMedia(ctx, cs.Key, streamID)
+	if err != nil {
+		return fmt.Errorf("count transcript media: %w", err)
+	}
+	if withMedia == 0 {
+		slog.Info("skipping automatic vod build: no media stored", "key", cs.Key, "func", "startAutoVod", "streamID", streamID)
+		return nil
+	}
+	// A failed lookup stops the build, as it does for the button: building
+	// anyway could add a second copy under a different random name.
+	key, err := app.findVodArtifact(ctx, cs.Key, streamID, ext)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		slog.Info("skipping automatic vod build: already built", "key", cs.Key, "func", "startAutoVod", "streamID", streamID)
+		return nil
+	}
+	fileIDs, err := app.Store.GetAllMediaFileIDs(ctx, cs.Key, streamID)
+	if err != nil {
+		return fmt.Errorf("get media file ids: %w", err)
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	job, started := app.Vods.claim(cs.Key, streamID)
+	if !started {
+		return nil
+	}
+	target := vodTarget{stream: stream, ext: ext, totalLines: total, mediaLines: withMedia, normalize: wantsNormalize(cs, nil)}
+	go app.buildVod(job, cs, target, fileIDs)
+
+	app.Discord.NotifyAdminAction(cs.Key, "Started automatic full VOD build",
+		discord.AdminField{Name: "Stream ID", Value: streamID, Inline: true},
+		discord.AdminField{Name: "Format", Value: ext[1:], Inline: true},
+		discord.AdminField{Name: "Chunks", Value: strconv.Itoa(len(fileIDs)), Inline: true},
+		discord.AdminField{Name: "Normalized", Value: strconv.FormatBool(target.normalize), Inline: true},
+		discord.AdminField{Name: "Lines Without Media", Value: strconv.Itoa(max(total-withMedia, 0)), Inline: true},
+		discord.AdminField{Name: "Stream Title", Value: stream.StreamTitle},
+	)
+	slog.Info("started automatic full vod build", "key", cs.Key, "func", "startAutoVod", "streamID", streamID, "chunks", len(fileIDs), "totalLines", total, "mediaLines", withMedia)
+	return nil
+}
@@ -10,9 +10,11 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"live-transcript-server/internal/media"
 	"live-transcript-server/internal/model"
 	"live-transcript-server/internal/storage"
 )
@@ -54,9 +56,7 @@ func seedVodStream(tb testing.TB, app *App, channel, streamID, mediaType string,
 		}
 		transcript = append(transcript, l)
 	}
-	if err := app.Store.ReplaceTranscript(ctx, channel, streamID, transcript); err != nil {
-		tb.Fatalf("seed transcript: %v", err)
-	}
+	seedTranscript(tb, app, channel, streamID, transcript)
 	return streamID
 }
 
@@ -484,6 +484,40 @@ func TestVodVideoStreamRendersMp4(t *testing.T) {
 	}
 }
 
+func TestVodBuildNormalizesLoudness(t *testing.T) {
+	app, mux := setupTestApp(t, []string{"doki"})
+	var normalized atomic.Int32
+	app.Media = fakeProcessor{encode: func(out string, profile media.AudioProfile) {
+		if profile.Normalize {
+			normalized.Add(1)
+		}
+	}}
+	app.Channels["doki"].NormalizeLoudness = true
+	seedVodStream(t, app, "doki", "on", "audio", 2, 2)
+	seedVodStream(t, app, "doki", "off", "audio", 2, 2)
+
+	if rec := adminReq(t, mux, http.MethodPost, "/doki/admin/vod/on?normalize=maybe", "admin-doki", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad normalize: status=%d want 400", rec.Code)
+	}
+
+	// The channel default applies unless the request overrides it.
+	adminReq(t, mux, http.MethodPost, "/doki/admin/vod/on", "admin-doki", nil)
+	if final := waitVodState(t, mux, "doki", "on"); final.State != vodStateDone {
+		t.Fatalf("default build state=%q want done (error=%q)", final.State, final.Error)
+	}
+	if n := normalized.Load(); n != 1 {
+		t.Errorf("default build normalized %d times, want once", n)
+	}
+
+	adminReq(t, mux, http.MethodPost, "/doki/admin/vod/off?normalize=false", "admin-doki", nil)
+	if final := waitVodState(t, mux, "doki", "off"); final.State != vodStateDone {
+		t.Fatalf("overridden build state=%q want done (error=%q)", final.State, final.Error)
+	}
+	if n := normalized.Load(); n != 1 {
+		t.Errorf("normalize=false build was normalized (%d calls in total)", n)
+	}
+}
+
 // Deleting a stream must not leave its build record behind in the registry.
 func TestVodJobForgottenOnStreamDelete(t *testing.T) {
 	app, mux := setupTestApp(t, []string{"doki"})
@@ -504,3 +538,84 @@ func TestVodJobForgottenOnStreamDelete(t *testing.T) {
 		t.Errorf("job record survived the stream delete: %+v", job.status())
 	}
 }
+
+func TestVodBuiltAutomaticallyWhenStreamEnds(t *testing.T) {
+	app, mux := setupTestApp(t, []string{"doki"})
+	var builds atomic.Int32
+	app.Media = fakeProcessor{convert: func(in, out string) error {
+		builds.Add(1)
+		return writePlaceholder(out)
+	}}
+	app.Channels["doki"].AutoVodDelay = 10 * time.Millisecond
+	seedVodStream(t, app, "doki", "stream-vod", "audio", 3, 2)
+	if err := app.Store.SetStreamLive(context.Background(), "doki", "stream-vod", true); err != nil {
+		t.Fatalf("set live: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/doki/deactivate?id=stream-vod", nil)
+	req.Header.Set("X-API-Key", app.ApiKey)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deactivate: status=%d want 200, body=%s", rec.Code, rec.Body.String())
+	}
+
+	waitFor(t, 5*time.Second, "automatic vod build", func() bool {
+		rec := adminReq(t, mux, http.MethodGet, "/doki/admin/vod/stream-vod", "admin-doki", nil)
+		return decodeVod(t, rec).State == vodStateDone
+	})
+
+	// Once built, it is not built again.
+	if err := app.startAutoVod(t.Context(), app.Channels["doki"], "stream-vod"); err != nil {
+		t.Fatalf("startAutoVod on a built stream: %v", err)
+	}
+	if n := builds.Load(); n != 1 {
+		t.Errorf("ran %d builds, want 1", n)
+	}
+}
+
+func TestVodBuiltAutomaticallyWhenStreamReplaced(t *testing.T) {
+	app, mux := setupTestApp(t, []string{"doki"})
+	app.Media = fakeProcessor{}
+	app.Channels["doki"].AutoVodDelay = 10 * time.Millisecond
+	seedVodStream(t, app, "doki", "stream-vod", "audio", 3, 2)
+	if err := app.Store.SetStreamLive(context.Background(), "doki", "stream-vod", true); err != nil {
+		t.Fatalf("set live: %v", err)
+	}
+
+	// A new stream activated while the old one is still live ends it
+	// without a deactivate call.
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/doki/activate?id=stream-next&title=Next&startTime=%d&mediaType=audio", time.Now().Unix()), nil)
+	req.Header.Set("X-API-Key", app.ApiKey)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("activate: status=%d want 200, body=%s", rec.Code, rec.Body.String())
+	}
+
+	waitFor(t, 5*time.Second, "automatic vod build of the replaced stream", func() bool {
+		rec := adminReq(t, mux, http.MethodGet, "/doki/admin/vod/stream-vod", "admin-doki", nil)
+		return decodeVod(t, rec).State == vodStateDone
+	})
+}
+
+func TestAutoVodSkipsStreamsWithoutMedia(t *testing.T) {
+	app, _ := setupTestApp(t, []string{"doki"})
+	app.Media = fakeProcessor{}
+	cs := app.Channels["doki"]
+	seedVodStream(t, app, "doki", "no-media", "audio", 3, 0)
+	seedVodStream(t, app, "doki", "text-only", "none", 3, 0)
+	seedVodStream(t, app, "doki", "live-again", "audio", 2, 2)
+	if err := app.Store.SetStreamLive(context.Background(), "doki", "live-again", true); err != nil {
+		t.Fatalf("set live: %v", err)
+	}
+
+	for _, streamID := range []string{"no-media", "text-only", "live-again", "deleted"} {
+		if err := app.startAutoVod(t.Context(), cs, streamID); err != nil {
+			t.Errorf("%s: startAutoVod: %v", streamID, err)
+		}
+		if job := app.Vods.get("doki", streamID); job != nil {
+			t.Errorf("%s: started a build: %+v", streamID, job.status())
+		}
+	}
+}
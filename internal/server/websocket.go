@@ -3,8 +3,11 @@ package server
 import (
 	"compress/flate"
 	"context"
+	"encoding/base64"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"live-transcript-server/internal/metrics"
@@ -52,7 +55,9 @@ func (app *App) wsHandler(w http.ResponseWriter, r *http.Request, cs *ChannelSta
 		cs.Hub.Remove(client)
 	}()
 
-	app.syncClient(r.Context(), cs, client)
+	if token := r.URL.Query().Get("resume"); token == "" || !app.resumeClient(r.Context(), cs, client, token) {
+		app.syncClient(r.Context(), cs, client)
+	}
 
 	if err := cs.Hub.ReadLoop(client); err != nil {
 		slog.Error("error in clients readloop", "key", cs.Key, "func", "wsHandler", "err", err)
@@ -85,6 +90,7 @@ func (app *App) syncClient(ctx context.Context, cs *ChannelState, client *ws.Cli
 		MediaType:    stream.MediaType,
 		MediaBaseURL: app.Storage.GetURL(""),
 		IsLive:       stream.IsLive,
+		AudioFormat:  stream.AudioFormat,
 		Transcript:   make([]model.Line, 0),
 	}
 
@@ -94,6 +100,13 @@ func (app *App) syncClient(ctx context.Context, cs *ChannelState, client *ws.Cli
 		return
 	}
 	syncData.Transcript = transcript
+	if stream.StreamID != "" {
+		lastLineID := -1
+		if len(transcript) > 0 {
+			lastLineID = transcript[len(transcript)-1].ID
+		}
+		syncData.ResumeToken = cs.resumeToken(stream.StreamID, lastLineID)
+	}
 
 	// Send a partial sync first if the transcript is large, so the client can
 	// render the tail immediately while the full payload transfers.
@@ -128,3 +141,155 @@ func (app *App) syncClient(ctx context.Context, cs *ChannelState, client *ws.Cli
 
 	metrics.MessageProcessingDuration.Observe(time.Since(startTime).Seconds())
 }
+
+// maxResumeLines caps how many missed lines a resume replays as individual
+// newLine messages. It keeps the whole delta well inside a client's send
+// buffer (see ws.Hub.Add); a client further behind than this gets a full sync,
+// which is cheaper at that size anyway.
+const maxResumeLines = 200
+
+// resumeMediaFiles is how many of the latest media files a resume reports,
+// matching the window the live newMedia broadcast uses.
+const resumeMediaFiles = 100
+
+// resumeToken encodes the position of a client in a stream: the stream ID,
+// the last line ID it was sent (-1 for none) and the channel's current
+// transcript revision. Clients treat it as opaque.
+func (cs *ChannelState) resumeToken(streamID string, lastLineID int) string {
+	raw := streamID + ":" + strconv.Itoa(lastLineID) + ":" + strconv.FormatInt(cs.TranscriptRevision.Load(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseResumeToken decodes a token made by resumeToken. ok is false for
+// anything that is not a well-formed token.
+func parseResumeToken(token string) (streamID string, lastLineID int, revision int64, ok bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, 0, false
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || !isValidID(parts[0]) {
+		return "", 0, 0, false
+	}
+	lastLineID, err = strconv.Atoi(parts[1])
+	if err != nil || lastLineID < -1 {
+		return "", 0, 0, false
+	}
+	revision, err = strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return parts[0], lastLineID, revision, true
+}
+
+// resumeClient tries to bring a reconnecting client up to date from its
+// resume token, sending only what it missed: the stream's current details as
+// updatedStream and status, the missed lines as newLine, the latest media as
+// newMedia, and finally resumed. It returns false when the token cannot be
+// honoured — malformed, for a stream that is no longer current, issued before
+// the transcript was rewritten, or too far behind — and the caller should
+// fall back to syncClient. A token for a deleted stream also gets that
+// stream's deletedStream first.
+func (app *App) resumeClient(ctx context.Context, cs *ChannelState, client *ws.Client, token string) bool {
+	streamID, lastLineID, revision, ok := parseResumeToken(token)
+	if !ok {
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+
+	stream, err := app.Store.GetStreamByID(ctx, cs.Key, streamID)
+	if err != nil {
+		slog.Error("failed to get stream for resume", "key", cs.Key, "streamID", streamID, "err", err)
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+	if stream == nil {
+		client.TrySend(ws.Message{Event: ws.EventDeletedStream, Data: ws.EventDeletedStreamData{StreamID: streamID}})
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+
+	recent, err := app.Store.GetRecentStream(ctx, cs.Key)
+	if err != nil || recent == nil || recent.StreamID != streamID || revision != cs.TranscriptRevision.Load() {
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+
+	// A token past the stored last line means the transcript was replaced by
+	// a shorter one (worker re-upload); the client's lines are not ours.
+	lastStoredID, err := app.Store.GetLastLineID(ctx, cs.Key, streamID)
+	if err != nil || lastStoredID < lastLineID {
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+	missed, err := app.Store.GetTranscriptAfter(ctx, cs.Key, streamID, lastLineID)
+	if err != nil || len(missed) > maxResumeLines {
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+	files, err := app.Store.GetLastAvailableMediaFiles(ctx, cs.Key, streamID, resumeMediaFiles)
+	if err != nil {
+		slog.Error("failed to get media files for resume", "key", cs.Key, "streamID", streamID, "err", err)
+		metrics.WebsocketResumes.WithLabelValues("rejected").Inc()
+		return false
+	}
+
+	startTime := time.Now()
+	metrics.MessagesTotal.Inc()
+
+	msgs := []ws.Message{{
+		Event: ws.EventUpdatedStream,
+		Data: ws.EventUpdatedStreamData{
+			StreamID:    stream.StreamID,
+			StreamTitle: stream.StreamTitle,
+			StartTime:   stream.StartTime,
+			MediaType:   stream.MediaType,
+			IsLive:      stream.IsLive,
+		},
+	}, {
+		// Clients take live/ended transitions from status only.
+		Event: ws.EventStatus,
+		Data: ws.EventStatusData{
+			StreamID:    stream.StreamID,
+			StreamTitle: stream.StreamTitle,
+			IsLive:      stream.IsLive,
+		},
+	}}
+	for _, line := range missed {
+		msgs = append(msgs, ws.Message{
+			Event: ws.EventNewLine,
+			Data: ws.EventNewLineData{
+				LineID:         line.ID,
+				Timestamp:      line.Timestamp,
+				MediaAvailable: line.MediaAvailable,
+				Segments:       line.Segments,
+				VodAccurate:    line.VodAccurate,
+				ResumeToken:    cs.resumeToken(streamID, line.ID),
+			},
+		})
+		lastLineID = line.ID
+	}
+	if len(files) > 0 {
+		msgs = append(msgs, ws.Message{Event: ws.EventNewMedia, Data: ws.EventNewMediaData{StreamID: streamID, Files: files}})
+	}
+	msgs = append(msgs, ws.Message{
+		Event: ws.EventResumed,
+		Data: ws.EventResumedData{
+			StreamID:    streamID,
+			ResumeToken: cs.resumeToken(streamID, lastLineID),
+			MissedLines: len(missed),
+		},
+	})
+
+	for _, msg := range msgs {
+		if !client.TrySend(msg) {
+			slog.Error("failed to send resume message: buffer full or closed", "key", cs.Key, "event", msg.Event)
+			cs.Hub.Remove(client)
+			return true
+		}
+	}
+
+	metrics.WebsocketResumes.WithLabelValues("resumed").Inc()
+	metrics.MessageProcessingDuration.Observe(time.Since(startTime).Seconds())
+	return true
+}
@@ -1,16 +1,20 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"live-transcript-server/internal/config"
 	"live-transcript-server/internal/model"
+	"live-transcript-server/internal/watchlist"
 	"live-transcript-server/internal/ws"
 
 	"github.com/gorilla/websocket"
@@ -254,7 +258,7 @@ func TestWebsocketPartialSync(t *testing.T) {
 			Segments:  json.RawMessage(fmt.Sprintf(`[{"timestamp": %d, "text": "Line %d"}]`, i*1000, i)),
 		}
 	}
-	app.Store.ReplaceTranscript(ctx, key, "stream-partial", lines)
+	seedTranscript(t, app, key, "stream-partial", lines)
 
 	server := httptest.NewServer(mux)
 	defer server.Close()
@@ -316,3 +320,380 @@ func TestWebsocketPartialSync(t *testing.T) {
 		t.Errorf("expected 150 lines in full sync, got %d", len(fullTranscript))
 	}
 }
+
+func TestWebsocketResume(t *testing.T) {
+	key := "test-ws-resume"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + key + "/websocket"
+
+	// The initial sync carries a token for the last line sent.
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	var syncMsg struct {
+		Event ws.EventType     `json:"event"`
+		Data  ws.EventSyncData `json:"data"`
+	}
+	if err := conn.ReadJSON(&syncMsg); err != nil || syncMsg.Event != ws.EventSync {
+		t.Fatalf("expected sync, got %s (err %v)", syncMsg.Event, err)
+	}
+	conn.Close()
+	token := syncMsg.Data.ResumeToken
+	if token != app.Channels[key].resumeToken("stream-1", 1) {
+		t.Fatalf("unexpected sync token %q", token)
+	}
+
+	// A line arrives while the client is away.
+	line := model.Line{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"timestamp": 300, "text": "missed"}]`)}
+	if err := app.Store.InsertNextLine(context.Background(), key, "stream-1", line); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL+"?resume="+token, nil)
+	if err != nil {
+		t.Fatalf("failed to dial with resume: %v", err)
+	}
+	defer conn.Close()
+
+	var events []ws.EventType
+	var msg ws.Message
+	for msg.Event != ws.EventResumed {
+		msg = ws.Message{}
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read after %v: %v", events, err)
+		}
+		events = append(events, msg.Event)
+		if msg.Event == ws.EventNewLine {
+			data := msg.Data.(map[string]any)
+			if data["lineId"] != float64(2) || data["resumeToken"] != app.Channels[key].resumeToken("stream-1", 2) {
+				t.Errorf("unexpected newLine: %v", data)
+			}
+		}
+	}
+	want := []ws.EventType{ws.EventUpdatedStream, ws.EventStatus, ws.EventNewLine, ws.EventResumed}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, events)
+	}
+	data := msg.Data.(map[string]any)
+	if data["resumeToken"] != app.Channels[key].resumeToken("stream-1", 2) || data["missedLines"] != float64(1) {
+		t.Errorf("unexpected resumed data: %v", data)
+	}
+}
+
+func TestWebsocketResumeRejected(t *testing.T) {
+	key := "test-ws-resume-rejected"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + key + "/websocket"
+
+	firstEvents := func(token string, n int) []ws.EventType {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?resume="+token, nil)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer conn.Close()
+		var events []ws.EventType
+		for range n {
+			var msg ws.Message
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read after %v: %v", events, err)
+			}
+			events = append(events, msg.Event)
+		}
+		return events
+	}
+
+	// Malformed tokens and tokens past the stored transcript fall back to sync.
+	for _, token := range []string{"garbage!", app.Channels[key].resumeToken("stream-1", 99)} {
+		if got := firstEvents(token, 1); got[0] != ws.EventSync {
+			t.Errorf("token %q: expected sync, got %v", token, got)
+		}
+	}
+
+	// A deleted stream is announced before the sync.
+	if got := firstEvents(app.Channels[key].resumeToken("gone", 3), 2); got[0] != ws.EventDeletedStream || got[1] != ws.EventSync {
+		t.Errorf("deleted stream: expected deletedStream then sync, got %v", got)
+	}
+
+	// Once a new stream has started, the old stream's token is stale.
+	app.activateStream(context.Background(), app.Channels[key], "stream-2", "next", fmt.Sprintf("%d", time.Now().Unix()+10), "audio")
+	if got := firstEvents(app.Channels[key].resumeToken("stream-1", 1), 1); got[0] != ws.EventSync {
+		t.Errorf("changed stream: expected sync, got %v", got)
+	}
+}
+
+func TestWebsocketSyncLinesChanged(t *testing.T) {
+	key := "test-ws-lines-changed"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + key + "/websocket"
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	var syncMsg struct {
+		Event ws.EventType     `json:"event"`
+		Data  ws.EventSyncData `json:"data"`
+	}
+	if err := conn.ReadJSON(&syncMsg); err != nil || syncMsg.Event != ws.EventSync {
+		t.Fatalf("expected sync, got %s (err %v)", syncMsg.Event, err)
+	}
+	staleToken := syncMsg.Data.ResumeToken
+
+	postSync := func(transcript []model.Line) {
+		t.Helper()
+		body, _ := json.Marshal(model.WorkerData{
+			StreamID:    "stream-1",
+			StreamTitle: "Test Stream Title",
+			StartTime:   syncMsg.Data.StartTime,
+			IsLive:      true,
+			MediaType:   "audio",
+			Transcript:  transcript,
+		})
+		req, _ := http.NewRequest("POST", "/"+key+"/sync", bytes.NewBuffer(body))
+		req.Header.Set("X-API-Key", app.ApiKey)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("sync: expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	// Line 0 is unchanged, line 1 is corrected and line 2 is new.
+	postSync([]model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hello world"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"timestamp": 200, "text": "This is the test"}]`)},
+		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"timestamp": 300, "text": "New"}]`)},
+	})
+
+	var changed struct {
+		Event ws.EventType             `json:"event"`
+		Data  ws.EventLinesChangedData `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&changed); err != nil {
+		t.Fatalf("failed to read linesChanged: %v", err)
+	}
+	if changed.Event != ws.EventLinesChanged {
+		t.Fatalf("expected linesChanged, got %s", changed.Event)
+	}
+	up := changed.Data.Upserted
+	if len(up) != 2 || up[0].ID != 1 || up[1].ID != 2 || len(changed.Data.Deleted) != 0 {
+		t.Errorf("unexpected linesChanged data: %+v", changed.Data)
+	}
+	if changed.Data.ResumeToken != app.Channels[key].resumeToken("stream-1", 2) {
+		t.Errorf("unexpected resume token %q", changed.Data.ResumeToken)
+	}
+
+	// A token issued before the rewrite no longer resumes.
+	stale, _, err := websocket.DefaultDialer.Dial(wsURL+"?resume="+staleToken, nil)
+	if err != nil {
+		t.Fatalf("failed to dial with resume: %v", err)
+	}
+	defer stale.Close()
+	var msg ws.Message
+	stale.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := stale.ReadJSON(&msg); err != nil || msg.Event != ws.EventSync {
+		t.Errorf("stale token: expected sync, got %s (err %v)", msg.Event, err)
+	}
+
+	// Resyncing the same transcript sends nothing; dropping a line sends
+	// just the deletion.
+	postSync([]model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hello world"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"timestamp": 200, "text": "This is the test"}]`)},
+		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"timestamp": 300, "text": "New"}]`)},
+	})
+	postSync([]model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"timestamp": 100, "text": "Hello world"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"timestamp": 200, "text": "This is the test"}]`)},
+	})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&changed); err != nil {
+		t.Fatalf("failed to read linesChanged: %v", err)
+	}
+	if len(changed.Data.Upserted) != 0 || len(changed.Data.Deleted) != 1 || changed.Data.Deleted[0] != 2 {
+		t.Errorf("expected only line 2 deleted, got %+v", changed.Data)
+	}
+}
+
+func TestWebsocketKeywordHit(t *testing.T) {
+	key := "test-ws-keyword-hit"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	wl, err := watchlist.New(config.WatchlistConfig{Keywords: []string{"giveaway"}, Broadcast: true})
+	if err != nil {
+		t.Fatalf("watchlist.New: %v", err)
+	}
+	app.Channels[key].Watchlist = wl
+
+	webhook := make(chan map[string]any, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]any
+		json.NewDecoder(r.Body).Decode(&p)
+		webhook <- p
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	app.Discord.WebhookURL = hook.URL
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + key + "/websocket"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	var msg ws.Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.Event != ws.EventSync {
+		t.Fatalf("expected sync, got %s (err %v)", msg.Event, err)
+	}
+
+	postLine := func(id int, text string) {
+		t.Helper()
+		body := fmt.Sprintf(`{"id": %d, "timestamp": %d, "segments": [{"timestamp": %d, "text": %q}]}`, id, 1000+id, 1000+id, text)
+		req, _ := http.NewRequest("POST", server.URL+"/"+key+"/line/stream-1", strings.NewReader(body))
+		req.Header.Set("X-API-Key", app.ApiKey)
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("failed to post line: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("post line %d: %s", id, resp.Status)
+		}
+	}
+	readEvent := func() ws.EventType {
+		t.Helper()
+		var msg ws.Message
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		return msg.Event
+	}
+
+	postLine(2, "Big GIVEAWAY tonight")
+	if evt := readEvent(); evt != ws.EventNewLine {
+		t.Fatalf("expected newLine, got %s", evt)
+	}
+	var hit struct {
+		Event ws.EventType           `json:"event"`
+		Data  ws.EventKeywordHitData `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&hit); err != nil || hit.Event != ws.EventKeywordHit {
+		t.Fatalf("expected keywordHit, got %s (err %v)", hit.Event, err)
+	}
+	want := ws.EventKeywordHitData{StreamID: "stream-1", LineID: 2, Timestamp: 1002, Keyword: "giveaway", Matched: "GIVEAWAY"}
+	if hit.Data != want {
+		t.Errorf("keywordHit data = %+v, want %+v", hit.Data, want)
+	}
+
+	select {
+	case p := <-webhook:
+		embeds, _ := p["embeds"].([]any)
+		if len(embeds) != 1 {
+			t.Fatalf("webhook embeds = %#v, want one", p["embeds"])
+		}
+		embed, _ := embeds[0].(map[string]any)
+		if url, _ := embed["url"].(string); !strings.HasSuffix(url, "?streamId=stream-1&lineId=2") {
+			t.Errorf("webhook url = %q, want a deep link to line 2", url)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for keyword webhook")
+	}
+
+	// The keyword is cooling down: the next mention only gets its newLine.
+	postLine(3, "another giveaway")
+	postLine(4, "nothing here")
+	for _, id := range []int{3, 4} {
+		if evt := readEvent(); evt != ws.EventNewLine {
+			t.Fatalf("line %d: expected newLine, got %s", id, evt)
+		}
+	}
+	select {
+	case p := <-webhook:
+		t.Errorf("unexpected webhook during cooldown: %#v", p)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebsocketKeywordHitInBatch(t *testing.T) {
+	key := "test-ws-keyword-hit-batch"
+	app, mux := setupTestApp(t, []string{key})
+	seedExampleData(t, app, key)
+
+	wl, err := watchlist.New(config.WatchlistConfig{Keywords: []string{"giveaway"}, Broadcast: true})
+	if err != nil {
+		t.Fatalf("watchlist.New: %v", err)
+	}
+	app.Channels[key].Watchlist = wl
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + key + "/websocket"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	var msg ws.Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.Event != ws.EventSync {
+		t.Fatalf("expected sync, got %s (err %v)", msg.Event, err)
+	}
+
+	// Every stored line is checked, not just the first of the batch.
+	body := `[
+		{"id": 2, "timestamp": 1002, "segments": [{"timestamp": 1002, "text": "nothing here"}]},
+		{"id": 3, "timestamp": 1003, "segments": [{"timestamp": 1003, "text": "Big GIVEAWAY tonight"}]}
+	]`
+	req, _ := http.NewRequest("POST", server.URL+"/"+key+"/lines/stream-1", strings.NewReader(body))
+	req.Header.Set("X-API-Key", app.ApiKey)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to post lines: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("post lines: %s", resp.Status)
+	}
+
+	var events []ws.EventType
+	var hit struct {
+		Event ws.EventType           `json:"event"`
+		Data  ws.EventKeywordHitData `json:"data"`
+	}
+	for len(events) < 3 {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&hit); err != nil {
+			t.Fatalf("failed to read event (got %v so far): %v", events, err)
+		}
+		events = append(events, hit.Event)
+	}
+	if want := []ws.EventType{ws.EventNewLine, ws.EventNewLine, ws.EventKeywordHit}; !slices.Equal(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	want := ws.EventKeywordHitData{StreamID: "stream-1", LineID: 3, Timestamp: 1003, Keyword: "giveaway", Matched: "GIVEAWAY"}
+	if hit.Data != want {
+		t.Errorf("keywordHit data = %+v, want %+v", hit.Data, want)
+	}
+}
@@ -12,9 +12,10 @@ func RawKey(channel, stream, fileID string) string {
 	return fmt.Sprintf("%s/%s/raw/%s.raw", channel, stream, fileID)
 }
 
-// AudioKey returns the key for a converted m4a audio chunk.
-func AudioKey(channel, stream, fileID string) string {
-	return fmt.Sprintf("%s/%s/audio/%s.m4a", channel, stream, fileID)
+// AudioKey returns the key for a converted audio chunk. ext includes the
+// leading dot (e.g. ".m4a" or ".opus").
+func AudioKey(channel, stream, fileID, ext string) string {
+	return fmt.Sprintf("%s/%s/audio/%s%s", channel, stream, fileID, ext)
 }
 
 // FrameKey returns the key for an extracted video frame.
@@ -22,6 +23,12 @@ func FrameKey(channel, stream, fileID string) string {
 	return fmt.Sprintf("%s/%s/frame/%s.jpg", channel, stream, fileID)
 }
 
+// PeaksKey returns the key for an audio chunk's waveform peaks, a JSON array
+// of media.PeaksPerSecond values a second.
+func PeaksKey(channel, stream, fileID string) string {
+	return fmt.Sprintf("%s/%s/peaks/%s.json", channel, stream, fileID)
+}
+
 // ClipKey returns the key for a clip. ext includes the leading dot
 // (e.g. ".mp4").
 func ClipKey(channel, stream, id, ext string) string {
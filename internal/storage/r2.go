@@ -30,8 +30,14 @@ func getContentType(filename string) string {
 		return "audio/mpeg"
 	case ".m4a":
 		return "audio/mp4"
+	case ".opus":
+		return "audio/ogg"
 	case ".mp4":
 		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".gif":
+		return "image/gif"
 	case ".jpg", ".jpeg":
 		return "image/jpeg"
 	case ".raw":
@@ -238,8 +238,10 @@ func TestKeyBuilders(t *testing.T) {
 		want string
 	}{
 		{"RawKey", RawKey("chan", "s1", "f1"), "chan/s1/raw/f1.raw"},
-		{"AudioKey", AudioKey("chan", "s1", "f1"), "chan/s1/audio/f1.m4a"},
+		{"AudioKey", AudioKey("chan", "s1", "f1", ".m4a"), "chan/s1/audio/f1.m4a"},
+		{"AudioKey opus", AudioKey("chan", "s1", "f1", ".opus"), "chan/s1/audio/f1.opus"},
 		{"FrameKey", FrameKey("chan", "s1", "f1"), "chan/s1/frame/f1.jpg"},
+		{"PeaksKey", PeaksKey("chan", "s1", "f1"), "chan/s1/peaks/f1.json"},
 		{"ClipKey", ClipKey("chan", "s1", "clip1", ".mp4"), "chan/s1/clips/clip1.mp4"},
 		{"StreamPrefix", StreamPrefix("chan", "s1"), "chan/s1/"},
 		{"RawPrefix", RawPrefix("chan", "s1"), "chan/s1/raw/"},
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"live-transcript-server/internal/model"
+)
+
+const clipColumns = "clip_id, stream_id, start_line, end_line, format, title, created_at, source_clip_id, size_bytes, has_sidecar, start_time, end_time, subtitles, normalized, encoding"
+
+// InsertClip adds a clip to the channel's catalog.
+func (s *Store) InsertClip(ctx context.Context, channelID string, clip model.Clip) error {
+	_, err := s.db.ExecContext(ctx, `
+	INSERT INTO clips (channel_id, `+clipColumns+`)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, channelID, clip.ClipID, clip.StreamID, clip.StartLine, clip.EndLine, clip.Format, clip.Title, clip.CreatedAt, clip.SourceClipID, clip.SizeBytes, clip.HasSidecar, clip.StartTime, clip.EndTime, clip.Subtitles, clip.Normalized, clip.Encoding)
+	return err
+}
+
+// GetClip returns a catalogued clip by ID.
+// Returns nil, nil if no clip is found.
+func (s *Store) GetClip(ctx context.Context, channelID string, clipID string) (*model.Clip, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+clipColumns+" FROM clips WHERE channel_id = ? AND clip_id = ?", channelID, clipID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clips, err := scanClips(rows)
+	if err != nil || len(clips) == 0 {
+		return nil, err
+	}
+	return &clips[0], nil
+}
+
+// ListClips returns a stream's catalogued clips, newest first.
+func (s *Store) ListClips(ctx context.Context, channelID string, streamID string) ([]model.Clip, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+clipColumns+" FROM clips WHERE channel_id = ? AND stream_id = ? ORDER BY created_at DESC, rowid DESC", channelID, streamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanClips(rows)
+}
+
+// FindClip returns the newest catalogued clip without subtitles cut from the
+// given range of lines of a stream in the given format, with the given
+// loudness normalisation and encoding. Trims, subtitled clips and clips cut by
+// time are not matched, even when they cover the same lines: a subtitled
+// clip's captions may no longer match the lines' text.
+// Returns nil, nil if there is none.
+func (s *Store) FindClip(ctx context.Context, channelID string, streamID string, startLine int, endLine int, format string, normalized bool, encoding string) (*model.Clip, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+clipColumns+" FROM clips WHERE channel_id = ? AND stream_id = ? AND start_line = ? AND end_line = ? AND format = ? AND subtitles = '' AND normalized = ? AND encoding = ? AND source_clip_id = '' AND start_time IS NULL ORDER BY created_at DESC, rowid DESC LIMIT 1", channelID, streamID, startLine, endLine, format, normalized, encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clips, err := scanClips(rows)
+	if err != nil || len(clips) == 0 {
+		return nil, err
+	}
+	return &clips[0], nil
+}
+
+// FindTimeClip is FindClip for clips cut by time: the newest catalogued clip
+// without subtitles of exactly the given span (seconds into the stream) in
+// the given format, with the given loudness normalisation and encoding.
+func (s *Store) FindTimeClip(ctx context.Context, channelID string, streamID string, startTime float64, endTime float64, format string, normalized bool, encoding string) (*model.Clip, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+clipColumns+" FROM clips WHERE channel_id = ? AND stream_id = ? AND start_time = ? AND end_time = ? AND format = ? AND subtitles = '' AND normalized = ? AND encoding = ? AND source_clip_id = '' ORDER BY created_at DESC, rowid DESC LIMIT 1", channelID, streamID, startTime, endTime, format, normalized, encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clips, err := scanClips(rows)
+	if err != nil || len(clips) == 0 {
+		return nil, err
+	}
+	return &clips[0], nil
+}
+
+func scanClips(rows *sql.Rows) ([]model.Clip, error) {
+	var clips []model.Clip
+	for rows.Next() {
+		var c model.Clip
+		var startTime, endTime sql.NullFloat64
+		if err := rows.Scan(&c.ClipID, &c.StreamID, &c.StartLine, &c.EndLine, &c.Format, &c.Title, &c.CreatedAt, &c.SourceClipID, &c.SizeBytes, &c.HasSidecar, &startTime, &endTime, &c.Subtitles, &c.Normalized, &c.Encoding); err != nil {
+			return nil, err
+		}
+		if startTime.Valid && endTime.Valid {
+			c.StartTime = &startTime.Float64
+			c.EndTime = &endTime.Float64
+		}
+		clips = append(clips, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return clips, nil
+}
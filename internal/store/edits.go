@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"live-transcript-server/internal/model"
+)
+
+// Admin edits live in line_edits, one row per edited line, and are applied
+// over the worker's copy in transcripts whenever lines are read for clients
+// (see lineColumns). Keeping them apart means a worker /sync, which rewrites
+// transcripts to match the worker, leaves corrections in place, and the
+// worker's own text stays available for drift checks and duplicate detection.
+
+// queryer is the subset of *sql.DB and *sql.Tx getLine needs.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// getLine reads one line as clients see it. Returns an error wrapping
+// ErrNotFound when the line does not exist.
+func getLine(ctx context.Context, q queryer, channelID, streamID string, lineID int) (*model.Line, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+lineColumns+" FROM transcripts t "+lineJoins+" WHERE t.channel_id = ? AND t.stream_id = ? AND t.line_id = ?", channelID, streamID, lineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines, err := scanLines(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("line %d for stream %s/%s: %w", lineID, channelID, streamID, ErrNotFound)
+	}
+	return &lines[0], nil
+}
+
+// EditLine replaces the segments clients see for a line with an admin
+// correction, keeping its hidden flag. It returns the line as clients now see
+// it, or an error wrapping ErrNotFound when the line does not exist.
+func (s *Store) EditLine(ctx context.Context, channelID string, streamID string, lineID int, segments json.RawMessage) (*model.Line, error) {
+	return s.ApplyLineEdit(ctx, channelID, streamID, lineID, segments, nil)
+}
+
+// SetLineHidden hides or unhides a line, keeping any text correction. It
+// returns the line as clients now see it, or an error wrapping ErrNotFound
+// when the line does not exist.
+func (s *Store) SetLineHidden(ctx context.Context, channelID string, streamID string, lineID int, hidden bool) (*model.Line, error) {
+	return s.ApplyLineEdit(ctx, channelID, streamID, lineID, nil, &hidden)
+}
+
+// ApplyLineEdit is EditLine and SetLineHidden in one: it sets whichever of a
+// text correction (segments) and the hidden flag is given, keeping the other
+// as it was, in a single edit recorded as a single revision. It returns the
+// line as clients now see it, or an error wrapping ErrNotFound when the line
+// does not exist.
+func (s *Store) ApplyLineEdit(ctx context.Context, channelID string, streamID string, lineID int, segments json.RawMessage, hidden *bool) (*model.Line, error) {
+	var segmentsArg, hiddenArg any
+	if segments != nil {
+		segmentsArg = string(segments)
+	}
+	if hidden != nil {
+		hiddenArg = *hidden
+	}
+	return s.writeEdit(ctx, channelID, streamID, lineID, `
+	INSERT INTO line_edits (channel_id, stream_id, line_id, segments, hidden, edited_at)
+	VALUES (?, ?, ?, ?, COALESCE(?, 0), ?)
+	ON CONFLICT(channel_id, stream_id, line_id) DO UPDATE SET
+		segments = COALESCE(excluded.segments, line_edits.segments),
+		hidden = COALESCE(?, line_edits.hidden),
+		edited_at = excluded.edited_at
+	`, channelID, streamID, lineID, segmentsArg, hiddenArg, time.Now().Unix(), hiddenArg)
+}
+
+// writeEdit runs an upsert into line_edits for an existing line, re-indexes
+// the line and records an admin revision if what clients see changed, in one
+// transaction.
+func (s *Store) writeEdit(ctx context.Context, channelID, streamID string, lineID int, upsert string, args ...any) (*model.Line, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getLine(ctx, tx, channelID, streamID, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, upsert, args...); err != nil {
+		return nil, err
+	}
+	if err := unindexLine(ctx, tx, channelID, streamID, lineID); err != nil {
+		return nil, err
+	}
+	if err := indexLine(ctx, tx, channelID, streamID, lineID); err != nil {
+		return nil, err
+	}
+	line, err := getLine(ctx, tx, channelID, streamID, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if !sameJSON(before.Segments, line.Segments) {
+		if err := recordRevision(ctx, tx, channelID, streamID, lineID, RevisionSourceAdmin, before.Segments); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
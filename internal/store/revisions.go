@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"live-transcript-server/internal/model"
+)
+
+// Revision sources, stored in transcript_revisions.source.
+const (
+	RevisionSourceLine  = "line"
+	RevisionSourceSync  = "sync"
+	RevisionSourceAdmin = "admin"
+)
+
+// recordRevision notes a change to what clients see of a line. prior is the
+// line's segments before the change, nil when the change created it.
+func recordRevision(ctx context.Context, ex execer, channelID, streamID string, lineID int, source string, prior json.RawMessage) error {
+	var priorArg any
+	if prior != nil {
+		priorArg = string(prior)
+	}
+	_, err := ex.ExecContext(ctx, `
+	INSERT INTO transcript_revisions (channel_id, stream_id, line_id, source, changed_at, prior_segments)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`, channelID, streamID, lineID, source, time.Now().Unix(), priorArg)
+	return err
+}
+
+// GetLineHistory returns every recorded change to a line, oldest first, each
+// with the segments before and after it. The line's current segments (as
+// clients see them) are the last revision's Segments; they are null if the
+// line was deleted. Returns an error wrapping ErrNotFound when the line
+// neither exists nor has any history.
+func (s *Store) GetLineHistory(ctx context.Context, channelID string, streamID string, lineID int) ([]model.LineRevision, error) {
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT revision_id, source, changed_at, prior_segments
+	FROM transcript_revisions
+	WHERE channel_id = ? AND stream_id = ? AND line_id = ?
+	ORDER BY revision_id ASC
+	`, channelID, streamID, lineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []model.LineRevision
+	for rows.Next() {
+		r := model.LineRevision{LineID: lineID}
+		var prior sql.NullString
+		if err := rows.Scan(&r.RevisionID, &r.Source, &r.ChangedAt, &prior); err != nil {
+			return nil, err
+		}
+		if prior.Valid {
+			r.Prior = json.RawMessage(prior.String)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var current json.RawMessage
+	line, err := getLine(ctx, s.db, channelID, streamID, lineID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if len(revisions) == 0 {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		current = line.Segments
+	}
+
+	// Each revision's result is what the next one found.
+	for i := range revisions {
+		if i+1 < len(revisions) {
+			revisions[i].Segments = revisions[i+1].Prior
+		} else {
+			revisions[i].Segments = current
+		}
+	}
+	return revisions, nil
+}
+
+// RevertLine undoes a revision by making the line show the segments it had
+// before that revision, unhidden. The revert is an admin edit, so it survives
+// later resyncs and is itself recorded. It returns the line as clients now see
+// it; an error wrapping ErrNotFound when the line or the revision (for this
+// line) does not exist; or ErrNoPriorRevision when the revision created the
+// line.
+func (s *Store) RevertLine(ctx context.Context, channelID string, streamID string, lineID int, revisionID int64) (*model.Line, error) {
+	var prior sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+	SELECT prior_segments FROM transcript_revisions
+	WHERE revision_id = ? AND channel_id = ? AND stream_id = ? AND line_id = ?
+	`, revisionID, channelID, streamID, lineID).Scan(&prior)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("revision %d for line %d of stream %s/%s: %w", revisionID, lineID, channelID, streamID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !prior.Valid {
+		return nil, ErrNoPriorRevision
+	}
+
+	return s.writeEdit(ctx, channelID, streamID, lineID, `
+	INSERT INTO line_edits (channel_id, stream_id, line_id, segments, hidden, edited_at)
+	VALUES (?, ?, ?, ?, 0, ?)
+	ON CONFLICT(channel_id, stream_id, line_id) DO UPDATE SET
+		segments = excluded.segments,
+		hidden = 0,
+		edited_at = excluded.edited_at
+	`, channelID, streamID, lineID, prior.String, time.Now().Unix())
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"live-transcript-server/internal/model"
+)
+
+// The search index is the transcripts_fts virtual table: one row per
+// transcript line, holding the line's segment text joined into a single
+// string plus the line's identity in unindexed columns. It is maintained by
+// hand inside the same transactions that write transcripts rather than by
+// triggers, because every transcript delete in this package removes a whole
+// stream at once and a per-row trigger would rescan the index once per line.
+//
+// FTS5 is preferred, but mattn/go-sqlite3 only compiles it in under the
+// sqlite_fts5 build tag. A binary built without it falls back to FTS4, which
+// the driver always includes; the two differ only in DDL and snippet()
+// argument order, so everything else here is shared.
+
+const (
+	ftsCreate5 = `CREATE VIRTUAL TABLE transcripts_fts USING fts5(
+		text,
+		channel_id UNINDEXED,
+		stream_id UNINDEXED,
+		line_id UNINDEXED
+	);`
+	ftsCreate4 = `CREATE VIRTUAL TABLE transcripts_fts USING fts4(
+		text, channel_id, stream_id, line_id,
+		notindexed=channel_id, notindexed=stream_id, notindexed=line_id,
+		tokenize=unicode61
+	);`
+)
+
+// ftsIndexSelect copies transcript rows into the index. Callers append a
+// WHERE clause over t to choose the rows. A line's text is its segments'
+// "text" fields joined with spaces, as clients see them (admin edits applied,
+// hidden lines empty); segments that are not valid JSON index as empty rather
+// than failing the write that carried them.
+const ftsIndexSelect = `
+	INSERT INTO transcripts_fts (text, channel_id, stream_id, line_id)
+	SELECT CASE WHEN json_valid(t.segments) THEN COALESCE((
+		SELECT group_concat(json_extract(j.value, '$.text'), ' ')
+		FROM json_each(t.segments) j WHERE j.type = 'object'
+	), '') ELSE '' END,
+	t.channel_id, t.stream_id, t.line_id
+	FROM (
+		SELECT tr.channel_id, tr.stream_id, tr.line_id,
+			CASE WHEN e.hidden THEN '[]' ELSE COALESCE(e.segments, tr.segments) END AS segments
+		FROM transcripts tr
+		LEFT JOIN line_edits e ON e.channel_id = tr.channel_id AND e.stream_id = tr.stream_id AND e.line_id = tr.line_id
+	) t`
+
+// Snippet markers wrapped around each matched term in a search hit.
+const (
+	snippetOpen  = "<mark>"
+	snippetClose = "</mark>"
+)
+
+// snippetTokens is roughly how many tokens of context a snippet carries.
+const snippetTokens = 16
+
+// execer is the subset of *sql.DB and *sql.Tx the index helpers need, so they
+// can run inside a caller's transaction or on their own.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// createSearchIndex creates transcripts_fts if it does not exist, preferring
+// FTS5, and fills it from the transcripts already stored. The backfill only
+// runs when the table is new, so an upgraded database becomes searchable on
+// its first start without re-indexing on every later one.
+func createSearchIndex(db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'transcripts_fts')").Scan(&exists); err != nil {
+		return fmt.Errorf("error checking for transcripts_fts table: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := db.Exec(ftsCreate5); err != nil {
+		if !strings.Contains(err.Error(), "no such module") {
+			return fmt.Errorf("error creating transcripts_fts table: %w", err)
+		}
+		if _, err := db.Exec(ftsCreate4); err != nil {
+			return fmt.Errorf("error creating transcripts_fts table: %w", err)
+		}
+	}
+
+	if _, err := db.Exec(ftsIndexSelect); err != nil {
+		return fmt.Errorf("error backfilling transcripts_fts: %w", err)
+	}
+	return nil
+}
+
+// RebuildSearchIndex discards and rebuilds the whole search index from the
+// transcripts table. It is for tooling (cmd/migrate) that writes transcript
+// rows directly instead of going through the Store.
+func RebuildSearchIndex(db *sql.DB) error {
+	if _, err := db.Exec("DELETE FROM transcripts_fts"); err != nil {
+		return fmt.Errorf("error clearing transcripts_fts: %w", err)
+	}
+	if _, err := db.Exec(ftsIndexSelect); err != nil {
+		return fmt.Errorf("error rebuilding transcripts_fts: %w", err)
+	}
+	return nil
+}
+
+// searchUsesFTS5 reports whether the index on db was created as FTS5. The
+// answer is read off the table's DDL rather than the running binary, because
+// the table outlives the build that created it.
+func searchUsesFTS5(db *sql.DB) (bool, error) {
+	var ddl string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'transcripts_fts'").Scan(&ddl); err != nil {
+		return false, fmt.Errorf("error reading transcripts_fts definition: %w", err)
+	}
+	return strings.Contains(strings.ToLower(ddl), "fts5"), nil
+}
+
+// indexLine adds a single stored line to the search index.
+func indexLine(ctx context.Context, ex execer, channelID, streamID string, lineID int) error {
+	_, err := ex.ExecContext(ctx, ftsIndexSelect+" WHERE t.channel_id = ? AND t.stream_id = ? AND t.line_id = ?", channelID, streamID, lineID)
+	return err
+}
+
+// indexStream adds every stored line of a stream to the search index.
+func indexStream(ctx context.Context, ex execer, channelID, streamID string) error {
+	_, err := ex.ExecContext(ctx, ftsIndexSelect+" WHERE t.channel_id = ? AND t.stream_id = ?", channelID, streamID)
+	return err
+}
+
+// unindexLine removes a single line from the search index.
+func unindexLine(ctx context.Context, ex execer, channelID, streamID string, lineID int) error {
+	_, err := ex.ExecContext(ctx, "DELETE FROM transcripts_fts WHERE channel_id = ? AND stream_id = ? AND line_id = ?", channelID, streamID, lineID)
+	return err
+}
+
+// unindexStream removes every line of a stream from the search index.
+func unindexStream(ctx context.Context, ex execer, channelID, streamID string) error {
+	_, err := ex.ExecContext(ctx, "DELETE FROM transcripts_fts WHERE channel_id = ? AND stream_id = ?", channelID, streamID)
+	return err
+}
+
+// ftsQuery turns free text into an FTS query that matches lines containing
+// every word, in any order. Each word is quoted as a phrase so punctuation and
+// FTS operators in the input (AND, NEAR, *, :, ...) are searched for as text
+// instead of being parsed as query syntax. Double quotes are dropped rather
+// than escaped: FTS4 has no escape for them inside a phrase, and the tokenizer
+// discards them anyway. Returns "" when there is nothing to search for.
+func ftsQuery(q string) string {
+	var phrases []string
+	for _, w := range strings.Fields(strings.ReplaceAll(q, `"`, " ")) {
+		phrases = append(phrases, `"`+w+`"`)
+	}
+	return strings.Join(phrases, " ")
+}
+
+// SearchTranscripts finds the lines of a channel's stored transcripts that
+// contain every word of q, newest stream first and in line order within a
+// stream, returning at most limit hits. Each hit's Snippet is an excerpt of
+// the line with matched terms wrapped in <mark>...</mark>; the excerpt is the
+// transcript text verbatim, so callers rendering it as HTML must escape
+// everything outside the markers.
+func (s *Store) SearchTranscripts(ctx context.Context, channelID string, q string, limit int) ([]model.SearchHit, error) {
+	match := ftsQuery(q)
+	if match == "" {
+		return nil, nil
+	}
+
+	snippet := fmt.Sprintf("snippet(transcripts_fts, '%s', '%s', '…', 0, %d)", snippetOpen, snippetClose, snippetTokens)
+	if s.fts5 {
+		snippet = fmt.Sprintf("snippet(transcripts_fts, 0, '%s', '%s', '…', %d)", snippetOpen, snippetClose, snippetTokens)
+	}
+
+	// Joining back to transcripts and streams drops index rows whose line or
+	// stream is gone, and supplies the timestamp and the stream ordering.
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT f.stream_id, f.line_id, t.timestamp, `+snippet+`
+	FROM transcripts_fts f
+	JOIN transcripts t ON t.channel_id = f.channel_id AND t.stream_id = f.stream_id AND t.line_id = f.line_id
+	JOIN streams st ON st.channel_id = f.channel_id AND st.stream_id = f.stream_id
+	WHERE transcripts_fts MATCH ? AND f.channel_id = ?
+	ORDER BY st.activated_time DESC, t.line_id ASC
+	LIMIT ?
+	`, match, channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []model.SearchHit
+	for rows.Next() {
+		var h model.SearchHit
+		if err := rows.Scan(&h.StreamID, &h.LineID, &h.Timestamp, &h.Snippet); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
@@ -26,12 +26,30 @@ var ErrNotFound = errors.New("not found")
 // ID is not exactly one past the last stored line.
 var ErrOutOfSync = errors.New("out of sync")
 
+// ErrDuplicateLine is returned (wrapped) by InsertNextLine when the incoming
+// line is already stored with the same content — a worker retrying a line
+// whose response it never saw. Nothing is written; callers treat it as
+// success.
+var ErrDuplicateLine = errors.New("line already stored")
+
+// ErrLineConflict is returned (wrapped) by InsertNextLine when the incoming
+// line's ID is already stored with different content. It wraps ErrOutOfSync,
+// so callers that only care about being out of sync need not check for it.
+var ErrLineConflict = fmt.Errorf("line stored with different content: %w", ErrOutOfSync)
+
+// ErrNoPriorRevision is returned by RevertLine for a revision that created its
+// line: there is no earlier text to go back to.
+var ErrNoPriorRevision = errors.New("revision has no prior value")
+
 // ErrNoUpdate is returned by UpdateStream when the update carries no fields.
 var ErrNoUpdate = errors.New("no fields to update")
 
 // Store wraps the SQLite database handle.
 type Store struct {
 	db *sql.DB
+	// fts5 records which FTS module the search index was created with; see
+	// search.go.
+	fts5 bool
 }
 
 // dsnConnector opens every pooled connection from the same DSN through a
@@ -121,6 +139,11 @@ func Open(path string, cfg config.DatabaseConfig) (*Store, error) {
 		db.Close()
 		return nil, err
 	}
+	fts5, err := searchUsesFTS5(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	// Connection pool settings. An in-memory database exists per connection,
 	// so it must be pinned to a single never-expiring connection or the pool
@@ -149,7 +172,7 @@ func Open(path string, cfg config.DatabaseConfig) (*Store, error) {
 		}()
 	}
 
-	return &Store{db: db}, nil
+	return &Store{db: db, fts5: fts5}, nil
 }
 
 // Close closes the underlying database handle.
@@ -235,5 +258,117 @@ func createSchema(db *sql.DB) error {
 		return fmt.Errorf("error creating worker_restart_requests table: %w", err)
 	}
 
+	// line_edits holds admin corrections to transcript lines, kept apart from
+	// the worker's copy in transcripts so a resync cannot overwrite them.
+	// segments is NULL when only the hidden flag was changed.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS line_edits (
+		channel_id TEXT,
+		stream_id TEXT,
+		line_id INTEGER,
+		segments TEXT,
+		hidden BOOLEAN DEFAULT 0,
+		edited_at INTEGER NOT NULL,
+		PRIMARY KEY (channel_id, stream_id, line_id)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating line_edits table: %w", err)
+	}
+
+	// transcript_revisions records every change to what clients see of a
+	// line: prior_segments is the line's segments before the change, NULL
+	// when the change created the line. The revision after the last one is
+	// the line as it is now.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS transcript_revisions (
+		revision_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		channel_id TEXT NOT NULL,
+		stream_id TEXT NOT NULL,
+		line_id INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		changed_at INTEGER NOT NULL,
+		prior_segments TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_transcript_revisions_line ON transcript_revisions (channel_id, stream_id, line_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating transcript_revisions table: %w", err)
+	}
+
+	// clips catalogs every clip uploaded to storage (under storage.ClipKey).
+	// start_line and end_line are -1 for a trim whose source clip is not in
+	// the catalog; source_clip_id is empty for clips cut from the stream.
+	// start_time and end_time are NULL unless the clip was cut by time.
+	// subtitles is "burn", "embed" or empty, as in model.Clip. normalized is
+	// set when the clip's audio was loudness-normalised. encoding describes
+	// the encoder settings the clip was made with, as in model.Clip.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS clips (
+		channel_id TEXT NOT NULL,
+		clip_id TEXT NOT NULL,
+		stream_id TEXT NOT NULL,
+		start_line INTEGER NOT NULL,
+		end_line INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		source_clip_id TEXT NOT NULL DEFAULT '',
+		size_bytes INTEGER NOT NULL DEFAULT 0,
+		has_sidecar BOOLEAN NOT NULL DEFAULT 0,
+		start_time REAL,
+		end_time REAL,
+		subtitles TEXT NOT NULL DEFAULT '',
+		normalized BOOLEAN NOT NULL DEFAULT 0,
+		encoding TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (channel_id, clip_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_clips_stream ON clips (channel_id, stream_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_clips_range ON clips (channel_id, stream_id, start_line, end_line, format);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating clips table: %w", err)
+	}
+
+	// stream_audio_formats records the extension (e.g. ".opus") a stream's
+	// per-line audio is encoded into, fixed when the stream is first seen so
+	// a config change cannot mix formats within a stream. Streams without a
+	// row predate encoding profiles and are ".m4a".
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS stream_audio_formats (
+		channel_id TEXT NOT NULL,
+		stream_id TEXT NOT NULL,
+		format TEXT NOT NULL,
+		PRIMARY KEY (channel_id, stream_id)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating stream_audio_formats table: %w", err)
+	}
+
+	// media_chunks records what ffprobe found in each uploaded media chunk,
+	// keyed by the chunk's file ID (see transcripts.file_id). size_bytes is
+	// the raw chunk's size. Chunks uploaded before probing have no row.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS media_chunks (
+		channel_id TEXT NOT NULL,
+		stream_id TEXT NOT NULL,
+		file_id TEXT NOT NULL,
+		duration REAL NOT NULL DEFAULT 0,
+		codec TEXT NOT NULL DEFAULT '',
+		width INTEGER NOT NULL DEFAULT 0,
+		height INTEGER NOT NULL DEFAULT 0,
+		size_bytes INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (channel_id, stream_id, file_id)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating media_chunks table: %w", err)
+	}
+
+	if err := createSearchIndex(db); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 
@@ -26,6 +29,15 @@ func newTestStore(t *testing.T) *Store {
 	return s
 }
 
+// seedTranscript stores lines as a stream's transcript, as a worker's /sync
+// would.
+func seedTranscript(t *testing.T, s *Store, channelID, streamID string, lines []model.Line) {
+	t.Helper()
+	if _, _, err := s.SyncTranscript(context.Background(), channelID, streamID, lines); err != nil {
+		t.Fatalf("failed to seed transcript: %v", err)
+	}
+}
+
 func TestStore_GetLastLine(t *testing.T) {
 	s := newTestStore(t)
 
@@ -47,9 +59,7 @@ func TestStore_GetLastLine(t *testing.T) {
 		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"text": "Third"}]`)},
 		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "Second"}]`)},
 	}
-	if err := s.ReplaceTranscript(ctx, channelID, "test-stream", lines); err != nil {
-		t.Fatalf("failed to replace transcript: %v", err)
-	}
+	seedTranscript(t, s, channelID, "test-stream", lines)
 
 	// Test GetLastLine
 	line, err = s.GetLastLine(ctx, channelID, "test-stream")
@@ -219,14 +229,12 @@ func TestStore_TranscriptOperations(t *testing.T) {
 		t.Errorf("expected last ID 0, got %d", lastID)
 	}
 
-	// 4. ReplaceTranscript
+	// 4. Replace the transcript
 	newLines := []model.Line{
 		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text": "New Hello"}]`)},
 		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "New World"}]`)},
 	}
-	if err := s.ReplaceTranscript(ctx, channelID, "test-stream", newLines); err != nil {
-		t.Fatalf("ReplaceTranscript failed: %v", err)
-	}
+	seedTranscript(t, s, channelID, "test-stream", newLines)
 
 	lines, err = s.GetTranscript(ctx, channelID, "test-stream")
 	if err != nil {
@@ -248,6 +256,345 @@ func TestStore_TranscriptOperations(t *testing.T) {
 	}
 }
 
+func TestStore_InsertNextLines(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-insert-next-lines"
+	streamID := "s1"
+
+	batch := func(ids ...int) []model.Line {
+		var lines []model.Line
+		for _, id := range ids {
+			lines = append(lines, model.Line{ID: id, Timestamp: 100 * id, Segments: json.RawMessage(`[{"text": "batch"}]`)})
+		}
+		return lines
+	}
+
+	stored, processed, err := s.InsertNextLines(ctx, channelID, streamID, batch(0, 1, 2))
+	if err != nil || len(stored) != 3 || processed != 3 {
+		t.Fatalf("expected 3 stored, got %d/%d (err %v)", len(stored), processed, err)
+	}
+
+	// A retried batch skips the lines already stored and appends the rest.
+	stored, processed, err = s.InsertNextLines(ctx, channelID, streamID, batch(1, 2, 3))
+	if err != nil || len(stored) != 1 || stored[0].ID != 3 || processed != 3 {
+		t.Fatalf("expected only line 3 stored, got %+v/%d (err %v)", stored, processed, err)
+	}
+
+	// The prefix before a gap is committed; the rest is reported out of sync.
+	stored, processed, err = s.InsertNextLines(ctx, channelID, streamID, batch(4, 6, 7))
+	if !errors.Is(err, ErrOutOfSync) || len(stored) != 1 || processed != 1 {
+		t.Fatalf("expected 1 stored and ErrOutOfSync, got %d/%d (err %v)", len(stored), processed, err)
+	}
+	if last, _ := s.GetLastLineID(ctx, channelID, streamID); last != 4 {
+		t.Errorf("expected last line 4, got %d", last)
+	}
+
+	// A stored ID with different content stops the batch as a conflict.
+	conflicting := batch(4)
+	conflicting[0].Segments = json.RawMessage(`[{"text": "other"}]`)
+	if _, processed, err = s.InsertNextLines(ctx, channelID, streamID, conflicting); !errors.Is(err, ErrLineConflict) || processed != 0 {
+		t.Errorf("expected ErrLineConflict, got %d (err %v)", processed, err)
+	}
+
+	// Stored lines are indexed for search like single inserts.
+	if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: streamID}); err != nil {
+		t.Fatalf("UpsertStream failed: %v", err)
+	}
+	if hits, err := s.SearchTranscripts(ctx, channelID, "batch", 10); err != nil || len(hits) != 5 {
+		t.Errorf("expected 5 search hits, got %v (err %v)", hits, err)
+	}
+}
+
+func TestStore_GetTranscriptAfter(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-transcript-after"
+
+	var lines []model.Line
+	for i := range 5 {
+		lines = append(lines, model.Line{ID: i, Timestamp: 100 * i, Segments: json.RawMessage(`[{"text": "line"}]`)})
+	}
+	seedTranscript(t, s, channelID, "s1", lines)
+
+	got, err := s.GetTranscriptAfter(ctx, channelID, "s1", 2)
+	if err != nil {
+		t.Fatalf("GetTranscriptAfter failed: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 3 || got[1].ID != 4 || got[0].Timestamp != 300 {
+		t.Errorf("expected lines 3 and 4, got %+v", got)
+	}
+
+	if got, err := s.GetTranscriptAfter(ctx, channelID, "s1", 4); err != nil || len(got) != 0 {
+		t.Errorf("expected no lines after the last one, got %v (err %v)", got, err)
+	}
+	if got, err := s.GetTranscriptAfter(ctx, channelID, "other", 0); err != nil || len(got) != 0 {
+		t.Errorf("expected no lines for another stream, got %v (err %v)", got, err)
+	}
+}
+
+func TestStore_SyncTranscript(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-sync-transcript"
+
+	if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: "s1"}); err != nil {
+		t.Fatalf("UpsertStream failed: %v", err)
+	}
+	seedTranscript(t, s, channelID, "s1", []model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text": "alpha"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "bravo"}]`)},
+		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"text": "charlie"}]`)},
+	})
+
+	// Line 0 is unchanged (only whitespace differs), line 1 is edited, line 2
+	// is gone and line 3 is new.
+	upserted, deleted, err := s.SyncTranscript(ctx, channelID, "s1", []model.Line{
+		{ID: 3, Timestamp: 400, Segments: json.RawMessage(`[{"text": "delta"}]`)},
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text":"alpha"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "bravado"}]`)},
+	})
+	if err != nil {
+		t.Fatalf("SyncTranscript failed: %v", err)
+	}
+	if len(upserted) != 2 || upserted[0].ID != 1 || upserted[1].ID != 3 {
+		t.Errorf("expected lines 1 and 3 upserted, got %+v", upserted)
+	}
+	if len(deleted) != 1 || deleted[0] != 2 {
+		t.Errorf("expected line 2 deleted, got %v", deleted)
+	}
+
+	lines, err := s.GetTranscript(ctx, channelID, "s1")
+	if err != nil {
+		t.Fatalf("GetTranscript failed: %v", err)
+	}
+	if len(lines) != 3 || lines[0].ID != 0 || lines[1].ID != 1 || lines[2].ID != 3 {
+		t.Fatalf("unexpected transcript after sync: %+v", lines)
+	}
+	if string(lines[1].Segments) != `[{"text": "bravado"}]` {
+		t.Errorf("expected line 1 to be updated, got %s", lines[1].Segments)
+	}
+
+	// The search index follows the diff.
+	for q, want := range map[string]int{"alpha": 1, "bravo": 0, "bravado": 1, "charlie": 0, "delta": 1} {
+		hits, err := s.SearchTranscripts(ctx, channelID, q, 10)
+		if err != nil {
+			t.Fatalf("SearchTranscripts(%q) failed: %v", q, err)
+		}
+		if len(hits) != want {
+			t.Errorf("SearchTranscripts(%q): expected %d hits, got %d", q, want, len(hits))
+		}
+	}
+
+	// Syncing the same transcript again changes nothing.
+	upserted, deleted, err = s.SyncTranscript(ctx, channelID, "s1", lines)
+	if err != nil || len(upserted) != 0 || len(deleted) != 0 {
+		t.Errorf("expected no-op sync, got upserted %v, deleted %v (err %v)", upserted, deleted, err)
+	}
+}
+
+func TestStore_EditLine(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-edit-line"
+
+	if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: "s1"}); err != nil {
+		t.Fatalf("UpsertStream failed: %v", err)
+	}
+	worker := []model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text": "alpha"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "misheard"}]`)},
+	}
+	seedTranscript(t, s, channelID, "s1", worker)
+
+	line, err := s.EditLine(ctx, channelID, "s1", 1, json.RawMessage(`[{"text":"corrected"}]`))
+	if err != nil {
+		t.Fatalf("EditLine failed: %v", err)
+	}
+	if string(line.Segments) != `[{"text":"corrected"}]` || line.Hidden {
+		t.Errorf("unexpected edited line: %+v", line)
+	}
+	if _, err := s.EditLine(ctx, channelID, "s1", 5, json.RawMessage(`[]`)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing line, got %v", err)
+	}
+
+	// A resync of the worker's copy keeps the correction, and reports the line
+	// it rewrote as clients see it.
+	worker[1].Timestamp = 201
+	upserted, _, err := s.SyncTranscript(ctx, channelID, "s1", worker)
+	if err != nil {
+		t.Fatalf("SyncTranscript failed: %v", err)
+	}
+	if len(upserted) != 1 || string(upserted[0].Segments) != `[{"text":"corrected"}]` || upserted[0].Timestamp != 201 {
+		t.Errorf("unexpected upserted lines: %+v", upserted)
+	}
+
+	line, err = s.SetLineHidden(ctx, channelID, "s1", 1, true)
+	if err != nil {
+		t.Fatalf("SetLineHidden failed: %v", err)
+	}
+	if !line.Hidden || string(line.Segments) != `[]` {
+		t.Errorf("unexpected hidden line: %+v", line)
+	}
+	lines, err := s.GetTranscript(ctx, channelID, "s1")
+	if err != nil || len(lines) != 2 || !lines[1].Hidden {
+		t.Errorf("expected hidden line to keep its place, got %+v (err %v)", lines, err)
+	}
+	if hits, err := s.SearchTranscripts(ctx, channelID, "corrected", 10); err != nil || len(hits) != 0 {
+		t.Errorf("expected hidden line out of search, got %v (err %v)", hits, err)
+	}
+
+	// Unhiding brings the correction back, and the worker's copy is untouched.
+	if _, err := s.SetLineHidden(ctx, channelID, "s1", 1, false); err != nil {
+		t.Fatalf("SetLineHidden failed: %v", err)
+	}
+	if hits, err := s.SearchTranscripts(ctx, channelID, "corrected", 10); err != nil || len(hits) != 1 {
+		t.Errorf("expected corrected line in search, got %v (err %v)", hits, err)
+	}
+	if hits, err := s.SearchTranscripts(ctx, channelID, "misheard", 10); err != nil || len(hits) != 0 {
+		t.Errorf("expected worker text out of search, got %v (err %v)", hits, err)
+	}
+	raw, err := s.GetWorkerTranscript(ctx, channelID, "s1")
+	if err != nil || len(raw) != 2 || string(raw[1].Segments) != `[{"text": "misheard"}]` {
+		t.Errorf("expected worker copy unchanged, got %+v (err %v)", raw, err)
+	}
+
+	// Deleting the transcript drops its edits too.
+	if err := s.DeleteTranscript(ctx, channelID, "s1"); err != nil {
+		t.Fatalf("DeleteTranscript failed: %v", err)
+	}
+	seedTranscript(t, s, channelID, "s1", worker)
+	if line, err := s.GetLastLine(ctx, channelID, "s1"); err != nil || string(line.Segments) != `[{"text": "misheard"}]` {
+		t.Errorf("expected edit gone after delete, got %+v (err %v)", line, err)
+	}
+}
+
+func TestStore_ApplyLineEdit(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-apply-line-edit"
+
+	seedTranscript(t, s, channelID, "s1", []model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text":"misheard"}]`)},
+	})
+
+	// Correcting and hiding a line at once is one edit with one revision.
+	hidden := true
+	line, err := s.ApplyLineEdit(ctx, channelID, "s1", 0, json.RawMessage(`[{"text":"corrected"}]`), &hidden)
+	if err != nil {
+		t.Fatalf("ApplyLineEdit failed: %v", err)
+	}
+	if !line.Hidden || string(line.Segments) != `[]` {
+		t.Errorf("unexpected edited line: %+v", line)
+	}
+	history, err := s.GetLineHistory(ctx, channelID, "s1", 0)
+	if err != nil {
+		t.Fatalf("GetLineHistory failed: %v", err)
+	}
+	admin := 0
+	for _, h := range history {
+		if h.Source == RevisionSourceAdmin {
+			admin++
+		}
+	}
+	if admin != 1 {
+		t.Errorf("expected a single admin revision, got %+v", history)
+	}
+
+	// Leaving both fields out keeps the edit as it was.
+	if _, err := s.ApplyLineEdit(ctx, channelID, "s1", 0, nil, nil); err != nil {
+		t.Fatalf("ApplyLineEdit failed: %v", err)
+	}
+	hidden = false
+	line, err = s.ApplyLineEdit(ctx, channelID, "s1", 0, nil, &hidden)
+	if err != nil {
+		t.Fatalf("ApplyLineEdit failed: %v", err)
+	}
+	if line.Hidden || string(line.Segments) != `[{"text":"corrected"}]` {
+		t.Errorf("expected correction kept after unhiding, got %+v", line)
+	}
+	if _, err := s.ApplyLineEdit(ctx, channelID, "s1", 3, nil, &hidden); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing line, got %v", err)
+	}
+}
+
+func TestStore_LineHistory(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-line-history"
+
+	if err := s.InsertNextLine(ctx, channelID, "s1", model.Line{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text":"first"}]`)}); err != nil {
+		t.Fatalf("InsertNextLine failed: %v", err)
+	}
+	if _, _, err := s.SyncTranscript(ctx, channelID, "s1", []model.Line{{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text":"second"}]`)}}); err != nil {
+		t.Fatalf("SyncTranscript failed: %v", err)
+	}
+	if _, err := s.EditLine(ctx, channelID, "s1", 0, json.RawMessage(`[{"text":"third"}]`)); err != nil {
+		t.Fatalf("EditLine failed: %v", err)
+	}
+	// Re-syncing the same worker text changes nothing clients see.
+	if _, _, err := s.SyncTranscript(ctx, channelID, "s1", []model.Line{{ID: 0, Timestamp: 101, Segments: json.RawMessage(`[{"text":"second"}]`)}}); err != nil {
+		t.Fatalf("SyncTranscript failed: %v", err)
+	}
+
+	history, err := s.GetLineHistory(ctx, channelID, "s1", 0)
+	if err != nil {
+		t.Fatalf("GetLineHistory failed: %v", err)
+	}
+	type step struct{ source, prior, segments string }
+	want := []step{
+		{RevisionSourceLine, "", `[{"text":"first"}]`},
+		{RevisionSourceSync, `[{"text":"first"}]`, `[{"text":"second"}]`},
+		{RevisionSourceAdmin, `[{"text":"second"}]`, `[{"text":"third"}]`},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d revisions, got %+v", len(want), history)
+	}
+	for i, w := range want {
+		h := history[i]
+		if h.Source != w.source || string(h.Prior) != w.prior || string(h.Segments) != w.segments {
+			t.Errorf("revision %d: got %s %s -> %s, want %+v", i, h.Source, h.Prior, h.Segments, w)
+		}
+	}
+
+	// Undoing the sync brings the original text back, as a new admin revision.
+	line, err := s.RevertLine(ctx, channelID, "s1", 0, history[1].RevisionID)
+	if err != nil {
+		t.Fatalf("RevertLine failed: %v", err)
+	}
+	if string(line.Segments) != `[{"text":"first"}]` {
+		t.Errorf("expected reverted text, got %s", line.Segments)
+	}
+	if history, _ := s.GetLineHistory(ctx, channelID, "s1", 0); len(history) != 4 || history[3].Source != RevisionSourceAdmin {
+		t.Errorf("expected the revert recorded, got %+v", history)
+	}
+
+	if _, err := s.RevertLine(ctx, channelID, "s1", 0, history[0].RevisionID); !errors.Is(err, ErrNoPriorRevision) {
+		t.Errorf("expected ErrNoPriorRevision for the creating revision, got %v", err)
+	}
+	if _, err := s.RevertLine(ctx, channelID, "s1", 1, history[1].RevisionID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another line's revision, got %v", err)
+	}
+
+	// A line deleted by a sync keeps its history, ending in null.
+	if _, _, err := s.SyncTranscript(ctx, channelID, "s1", nil); err != nil {
+		t.Fatalf("SyncTranscript failed: %v", err)
+	}
+	history, err = s.GetLineHistory(ctx, channelID, "s1", 0)
+	if err != nil || len(history) != 5 || history[4].Segments != nil || string(history[4].Prior) != `[{"text":"first"}]` {
+		t.Errorf("unexpected history after delete: %+v (err %v)", history, err)
+	}
+	if _, err := s.GetLineHistory(ctx, channelID, "s1", 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown line, got %v", err)
+	}
+}
+
 func TestStore_InsertNextLine(t *testing.T) {
 	s := newTestStore(t)
 
@@ -271,10 +618,21 @@ func TestStore_InsertNextLine(t *testing.T) {
 		t.Fatalf("expected ErrOutOfSync for gapped ID, got %v", err)
 	}
 
-	// A duplicate is rejected with ErrOutOfSync.
-	err = s.InsertNextLine(ctx, channelID, streamID, model.Line{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[]`)})
-	if !errors.Is(err, ErrOutOfSync) {
-		t.Fatalf("expected ErrOutOfSync for duplicate ID, got %v", err)
+	// A resend of a stored line with the same content is a duplicate, not a
+	// conflict; whitespace differences in the segments do not matter.
+	err = s.InsertNextLine(ctx, channelID, streamID, model.Line{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[ ]`)})
+	if !errors.Is(err, ErrDuplicateLine) || errors.Is(err, ErrOutOfSync) {
+		t.Fatalf("expected ErrDuplicateLine for identical resend, got %v", err)
+	}
+
+	// The same ID with different content is a conflict, which is out of sync.
+	err = s.InsertNextLine(ctx, channelID, streamID, model.Line{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "changed"}]`)})
+	if !errors.Is(err, ErrLineConflict) || !errors.Is(err, ErrOutOfSync) {
+		t.Fatalf("expected ErrLineConflict for changed content, got %v", err)
+	}
+	err = s.InsertNextLine(ctx, channelID, streamID, model.Line{ID: 1, Timestamp: 201, Segments: json.RawMessage(`[]`)})
+	if !errors.Is(err, ErrLineConflict) {
+		t.Fatalf("expected ErrLineConflict for changed timestamp, got %v", err)
 	}
 
 	// Wrong first ID on an empty transcript is rejected too.
@@ -308,9 +666,7 @@ func TestStore_DeleteStreamCascade(t *testing.T) {
 			{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[]`)},
 			{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[]`)},
 		}
-		if err := s.ReplaceTranscript(ctx, channelID, streamID, lines); err != nil {
-			t.Fatalf("ReplaceTranscript failed: %v", err)
-		}
+		seedTranscript(t, s, channelID, streamID, lines)
 	}
 
 	if err := s.DeleteStreamCascade(ctx, channelID, "doomed"); err != nil {
@@ -350,6 +706,221 @@ func TestStore_DeleteStreamCascade(t *testing.T) {
 	}
 }
 
+func TestStore_ClaimStreamAudioFormat(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	channelID := "test-audio-format"
+
+	for _, streamID := range []string{"legacy", "opus"} {
+		if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: streamID, MediaType: "audio"}); err != nil {
+			t.Fatalf("UpsertStream failed: %v", err)
+		}
+	}
+
+	// The first claim fixes the format; later claims get it back.
+	if got, err := s.ClaimStreamAudioFormat(ctx, channelID, "opus", ".opus"); err != nil || got != ".opus" {
+		t.Fatalf("ClaimStreamAudioFormat = %q, %v; want .opus", got, err)
+	}
+	if got, err := s.ClaimStreamAudioFormat(ctx, channelID, "opus", ".m4a"); err != nil || got != ".opus" {
+		t.Errorf("second ClaimStreamAudioFormat = %q, %v; want the first claim's .opus", got, err)
+	}
+
+	st, err := s.GetStreamByID(ctx, channelID, "opus")
+	if err != nil || st == nil {
+		t.Fatalf("GetStreamByID failed: %v", err)
+	}
+	if st.AudioFormat != ".opus" {
+		t.Errorf("AudioFormat = %q, want .opus", st.AudioFormat)
+	}
+	streams, err := s.GetAllStreams(ctx, channelID)
+	if err != nil {
+		t.Fatalf("GetAllStreams failed: %v", err)
+	}
+	for _, st := range streams {
+		if want := map[string]string{"legacy": "", "opus": ".opus"}[st.StreamID]; st.AudioFormat != want {
+			t.Errorf("stream %s: AudioFormat = %q, want %q", st.StreamID, st.AudioFormat, want)
+		}
+	}
+
+	// Deleting the stream forgets its format, so a stream reusing the ID
+	// claims afresh.
+	if err := s.DeleteStreamCascade(ctx, channelID, "opus"); err != nil {
+		t.Fatalf("DeleteStreamCascade failed: %v", err)
+	}
+	if got, err := s.ClaimStreamAudioFormat(ctx, channelID, "opus", ".m4a"); err != nil || got != ".m4a" {
+		t.Errorf("ClaimStreamAudioFormat after delete = %q, %v; want .m4a", got, err)
+	}
+}
+
+func TestStore_ChunkMedia(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	channelID, streamID := "test-chunk-media", "stream"
+
+	if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: streamID, MediaType: "video"}); err != nil {
+		t.Fatalf("UpsertStream failed: %v", err)
+	}
+	for id := range 2 {
+		if err := s.InsertNextLine(ctx, channelID, streamID, model.Line{ID: id, Timestamp: 100 + 10*id, Segments: json.RawMessage("[]")}); err != nil {
+			t.Fatalf("InsertNextLine(%d) failed: %v", id, err)
+		}
+	}
+
+	// Metadata can be recorded before the line points at the chunk; the
+	// last record wins.
+	chunk := model.ChunkMedia{Duration: 10.02, Codec: "aac", Width: 1280, Height: 720, SizeBytes: 4096}
+	if err := s.SetChunkMedia(ctx, channelID, streamID, "file0", model.ChunkMedia{SizeBytes: 1}); err != nil {
+		t.Fatalf("SetChunkMedia failed: %v", err)
+	}
+	if err := s.SetChunkMedia(ctx, channelID, streamID, "file0", chunk); err != nil {
+		t.Fatalf("SetChunkMedia failed: %v", err)
+	}
+	if err := s.SetMediaAvailable(ctx, channelID, streamID, 0, "file0", true); err != nil {
+		t.Fatalf("SetMediaAvailable failed: %v", err)
+	}
+
+	lines, err := s.GetTranscript(ctx, channelID, streamID)
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("GetTranscript = %d lines, %v; want 2", len(lines), err)
+	}
+	if lines[0].ChunkMedia != chunk {
+		t.Errorf("line 0 chunk media = %+v, want %+v", lines[0].ChunkMedia, chunk)
+	}
+	if lines[1].ChunkMedia != (model.ChunkMedia{}) {
+		t.Errorf("line 1 has no media, got chunk media %+v", lines[1].ChunkMedia)
+	}
+	timings, err := s.GetLineTimings(ctx, channelID, streamID)
+	if err != nil || len(timings) != 2 {
+		t.Fatalf("GetLineTimings = %d lines, %v; want 2", len(timings), err)
+	}
+	if timings[0].Duration != chunk.Duration || timings[1].Duration != 0 {
+		t.Errorf("GetLineTimings durations = %v, %v; want %v, 0", timings[0].Duration, timings[1].Duration, chunk.Duration)
+	}
+
+	// The worker's copy never carries server-side metadata.
+	worker, err := s.GetWorkerTranscript(ctx, channelID, streamID)
+	if err != nil || len(worker) != 2 {
+		t.Fatalf("GetWorkerTranscript = %d lines, %v; want 2", len(worker), err)
+	}
+	if worker[0].ChunkMedia != (model.ChunkMedia{}) {
+		t.Errorf("worker line 0 chunk media = %+v, want none", worker[0].ChunkMedia)
+	}
+
+	// Deleting the stream forgets its chunks.
+	if err := s.DeleteStreamCascade(ctx, channelID, streamID); err != nil {
+		t.Fatalf("DeleteStreamCascade failed: %v", err)
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM media_chunks WHERE channel_id = ?", channelID).Scan(&n); err != nil || n != 0 {
+		t.Errorf("media_chunks rows after delete = %d, %v; want 0", n, err)
+	}
+}
+
+func TestStore_Clips(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-clips"
+
+	for _, streamID := range []string{"s1", "s2"} {
+		if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: streamID}); err != nil {
+			t.Fatalf("UpsertStream failed: %v", err)
+		}
+	}
+	clips := []model.Clip{
+		{ClipID: "a", StreamID: "s1", StartLine: 1, EndLine: 3, Format: "m4a", CreatedAt: 100, SizeBytes: 10, Encoding: "aac/96k"},
+		{ClipID: "b", StreamID: "s1", StartLine: 2, EndLine: 5, Format: "mp4", Title: "Big moment", CreatedAt: 200, SizeBytes: 20, HasSidecar: true},
+		{ClipID: "c", StreamID: "s1", StartLine: 2, EndLine: 5, Format: "mp4", CreatedAt: 300, SourceClipID: "b", SizeBytes: 5},
+		{ClipID: "d", StreamID: "s2", StartLine: 0, EndLine: 0, Format: "mp3", CreatedAt: 150, SizeBytes: 1, Normalized: true},
+	}
+	for _, c := range clips {
+		if err := s.InsertClip(ctx, channelID, c); err != nil {
+			t.Fatalf("InsertClip(%s) failed: %v", c.ClipID, err)
+		}
+	}
+	if err := s.InsertClip(ctx, channelID, clips[0]); err == nil {
+		t.Error("expected inserting a duplicate clip ID to fail")
+	}
+
+	got, err := s.ListClips(ctx, channelID, "s1")
+	if err != nil {
+		t.Fatalf("ListClips failed: %v", err)
+	}
+	if len(got) != 3 || got[0] != clips[2] || got[1] != clips[1] || got[2] != clips[0] {
+		t.Errorf("ListClips = %+v, want s1's clips newest first", got)
+	}
+
+	clip, err := s.GetClip(ctx, channelID, "b")
+	if err != nil {
+		t.Fatalf("GetClip failed: %v", err)
+	}
+	if clip == nil || *clip != clips[1] {
+		t.Errorf("GetClip = %+v, want %+v", clip, clips[1])
+	}
+	if clip, err := s.GetClip(ctx, "other-channel", "b"); err != nil || clip != nil {
+		t.Errorf("GetClip on another channel = %+v, %v; want nil, nil", clip, err)
+	}
+
+	// A clip cut by time from the same lines as clip a, and newer.
+	startTime, endTime := 12.5, 24.25
+	timed := model.Clip{ClipID: "e", StreamID: "s1", StartLine: 1, EndLine: 3, Format: "m4a", CreatedAt: 400, StartTime: &startTime, EndTime: &endTime}
+	if err := s.InsertClip(ctx, channelID, timed); err != nil {
+		t.Fatalf("InsertClip(e) failed: %v", err)
+	}
+	if clip, err := s.GetClip(ctx, channelID, "e"); err != nil || clip == nil || clip.StartTime == nil || *clip.StartTime != startTime || clip.EndTime == nil || *clip.EndTime != endTime {
+		t.Errorf("GetClip(e) = %+v, %v; want its time span kept", clip, err)
+	}
+
+	// FindClip matches a range and format, never a trim of it or a clip cut
+	// by time; FindTimeClip matches only the latter.
+	if clip, err := s.FindClip(ctx, channelID, "s1", 2, 5, "mp4", false, ""); err != nil || clip == nil || clip.ClipID != "b" {
+		t.Errorf("FindClip(s1, 2-5, mp4) = %+v, %v; want clip b", clip, err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s1", 1, 3, "m4a", false, "aac/96k"); err != nil || clip == nil || clip.ClipID != "a" {
+		t.Errorf("FindClip(s1, 1-3, m4a) = %+v, %v; want clip a", clip, err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s1", 1, 3, "m4a", false, ""); err != nil || clip != nil {
+		t.Errorf("FindClip with another encoding = %+v, %v; want nil, nil", clip, err)
+	}
+	if clip, err := s.FindTimeClip(ctx, channelID, "s1", startTime, endTime, "m4a", false, ""); err != nil || clip == nil || clip.ClipID != "e" {
+		t.Errorf("FindTimeClip(s1, 12.5-24.25, m4a) = %+v, %v; want clip e", clip, err)
+	}
+	if clip, err := s.FindTimeClip(ctx, channelID, "s1", startTime, endTime+1, "m4a", false, ""); err != nil || clip != nil {
+		t.Errorf("FindTimeClip with another span = %+v, %v; want nil, nil", clip, err)
+	}
+	// A subtitled clip of the same lines, newer still, is never matched.
+	subtitled := model.Clip{ClipID: "f", StreamID: "s1", StartLine: 2, EndLine: 5, Format: "mp4", CreatedAt: 500, Subtitles: "burn"}
+	if err := s.InsertClip(ctx, channelID, subtitled); err != nil {
+		t.Fatalf("InsertClip(f) failed: %v", err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s1", 2, 5, "mp4", false, ""); err != nil || clip == nil || clip.ClipID != "b" {
+		t.Errorf("FindClip with a subtitled clip = %+v, %v; want clip b", clip, err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s1", 2, 5, "mp4", true, ""); err != nil || clip != nil {
+		t.Errorf("FindClip normalised = %+v, %v; want nil, nil", clip, err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s2", 0, 0, "mp3", true, ""); err != nil || clip == nil || *clip != clips[3] {
+		t.Errorf("FindClip(s2, 0-0, mp3) normalised = %+v, %v; want clip d", clip, err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s1", 2, 5, "m4a", false, ""); err != nil || clip != nil {
+		t.Errorf("FindClip with another format = %+v, %v; want nil, nil", clip, err)
+	}
+	if clip, err := s.FindClip(ctx, channelID, "s2", 1, 3, "m4a", false, ""); err != nil || clip != nil {
+		t.Errorf("FindClip on another stream = %+v, %v; want nil, nil", clip, err)
+	}
+
+	// Deleting a stream drops its catalog and nothing else.
+	if err := s.DeleteStreamCascade(ctx, channelID, "s1"); err != nil {
+		t.Fatalf("DeleteStreamCascade failed: %v", err)
+	}
+	if got, _ := s.ListClips(ctx, channelID, "s1"); len(got) != 0 {
+		t.Errorf("expected s1 clips to be deleted, got %+v", got)
+	}
+	if clip, _ := s.GetClip(ctx, channelID, "d"); clip == nil {
+		t.Error("expected s2 clip to remain")
+	}
+}
+
 func TestStore_SetMediaAvailable(t *testing.T) {
 	s := newTestStore(t)
 
@@ -543,9 +1114,7 @@ func TestStore_GetLastAvailableMediaFiles(t *testing.T) {
 		{ID: 2, Timestamp: 300, Segments: json.RawMessage(`[{"text": "Third"}]`)},
 		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "Second"}]`)},
 	}
-	if err := s.ReplaceTranscript(ctx, channelID, "test-stream", lines); err != nil {
-		t.Fatalf("failed to replace transcript: %v", err)
-	}
+	seedTranscript(t, s, channelID, "test-stream", lines)
 
 	files, err = s.GetLastAvailableMediaFiles(ctx, channelID, "test-stream", 10)
 	if err != nil {
@@ -561,9 +1130,7 @@ func TestStore_GetLastAvailableMediaFiles(t *testing.T) {
 		{ID: 2, Timestamp: 300, MediaAvailable: false, Segments: json.RawMessage(`[{"text": "Third"}]`)},
 		{ID: 1, Timestamp: 200, MediaAvailable: true, FileID: "f1", Segments: json.RawMessage(`[{"text": "Second"}]`)},
 	}
-	if err := s.ReplaceTranscript(ctx, channelID, "test-stream", lines); err != nil {
-		t.Fatalf("failed to replace transcript: %v", err)
-	}
+	seedTranscript(t, s, channelID, "test-stream", lines)
 
 	files, err = s.GetLastAvailableMediaFiles(ctx, channelID, "test-stream", 10)
 	if err != nil {
@@ -851,9 +1418,7 @@ func TestStore_GetFileIDsInRange_Ordering(t *testing.T) {
 		{ID: 1, Timestamp: 100, FileID: "file2", MediaAvailable: true, Segments: json.RawMessage(`[{"text": "1"}]`)},
 		{ID: 2, Timestamp: 200, FileID: "file3", MediaAvailable: true, Segments: json.RawMessage(`[{"text": "2"}]`)},
 	}
-	if err := s.ReplaceTranscript(ctx, channelID, activeID, lines); err != nil {
-		t.Fatalf("ReplaceTranscript failed: %v", err)
-	}
+	seedTranscript(t, s, channelID, activeID, lines)
 
 	// 2. Query range 1-3
 	fileIDs, err := s.GetFileIDsInRange(ctx, channelID, activeID, 1, 3)
@@ -876,6 +1441,42 @@ func TestStore_GetFileIDsInRange_Ordering(t *testing.T) {
 	}
 }
 
+func TestStore_GetLineTimings(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-line-timings"
+	lines := []model.Line{
+		{ID: 1, Timestamp: 110, Segments: json.RawMessage(`[{"text": "1"}]`)},
+		{ID: 0, Timestamp: 100, FileID: "file0", MediaAvailable: true, Segments: json.RawMessage(`[{"text": "0"}]`)},
+	}
+	seedTranscript(t, s, channelID, "s1", lines)
+
+	got, err := s.GetLineTimings(ctx, channelID, "s1")
+	if err != nil {
+		t.Fatalf("GetLineTimings failed: %v", err)
+	}
+	want := []model.Line{
+		{ID: 0, Timestamp: 100, FileID: "file0", MediaAvailable: true},
+		{ID: 1, Timestamp: 110},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetLineTimings = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Timestamp != want[i].Timestamp || got[i].FileID != want[i].FileID || got[i].MediaAvailable != want[i].MediaAvailable || got[i].Segments != nil {
+			t.Errorf("line %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got, err := s.GetLineTimingsRange(ctx, channelID, "s1", 1, 5); err != nil || len(got) != 1 || got[0].ID != 1 || got[0].Timestamp != 110 {
+		t.Errorf("GetLineTimingsRange(1, 5) = %+v, %v; want line 1 only", got, err)
+	}
+	if got, err := s.GetLineTimingsRange(ctx, channelID, "s1", 2, 5); err != nil || len(got) != 0 {
+		t.Errorf("GetLineTimingsRange(2, 5) = %+v, %v; want none", got, err)
+	}
+}
+
 func TestStore_CleanupOrphanedTranscripts(t *testing.T) {
 	s := newTestStore(t)
 
@@ -898,14 +1499,10 @@ func TestStore_CleanupOrphanedTranscripts(t *testing.T) {
 		{ID: 1, Timestamp: 100, Segments: json.RawMessage(`[]`)},
 		{ID: 2, Timestamp: 200, Segments: json.RawMessage(`[]`)},
 	}
-	if err := s.ReplaceTranscript(ctx, channelID, "valid-stream", validLines); err != nil {
-		t.Fatalf("ReplaceTranscript failed: %v", err)
-	}
+	seedTranscript(t, s, channelID, "valid-stream", validLines)
 
 	// 3. Add transcript lines for an orphaned stream (no stream in DB)
-	if err := s.ReplaceTranscript(ctx, channelID, "orphaned-stream", []model.Line{{ID: 1, Timestamp: 100, Segments: json.RawMessage(`[]`)}}); err != nil {
-		t.Fatalf("ReplaceTranscript (orphan) failed: %v", err)
-	}
+	seedTranscript(t, s, channelID, "orphaned-stream", []model.Line{{ID: 1, Timestamp: 100, Segments: json.RawMessage(`[]`)}})
 
 	// Verify before cleanup
 	lines, err := s.GetTranscript(ctx, channelID, "valid-stream")
@@ -932,3 +1529,141 @@ func TestStore_CleanupOrphanedTranscripts(t *testing.T) {
 		t.Fatalf("Expected 0 orphaned lines after cleanup, got %d", len(lines))
 	}
 }
+
+func TestStore_SearchTranscripts(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	channelID := "test-search"
+
+	// searchLines returns "streamID/lineID" for each hit, in result order.
+	searchLines := func(q string) []string {
+		t.Helper()
+		hits, err := s.SearchTranscripts(ctx, channelID, q, 50)
+		if err != nil {
+			t.Fatalf("SearchTranscripts(%q) failed: %v", q, err)
+		}
+		var got []string
+		for _, h := range hits {
+			got = append(got, fmt.Sprintf("%s/%d", h.StreamID, h.LineID))
+		}
+		return got
+	}
+
+	for _, streamID := range []string{"old", "new"} {
+		if err := s.UpsertStream(ctx, &model.Stream{ChannelID: channelID, StreamID: streamID}); err != nil {
+			t.Fatalf("UpsertStream failed: %v", err)
+		}
+	}
+	// "new" was activated later, so it sorts first.
+	if _, err := s.db.ExecContext(ctx, "UPDATE streams SET activated_time = 2 WHERE stream_id = 'new'"); err != nil {
+		t.Fatalf("failed to set activated_time: %v", err)
+	}
+
+	seedTranscript(t, s, channelID, "old", []model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text": "the quick brown fox"}]`)},
+		{ID: 1, Timestamp: 200, Segments: json.RawMessage(`[{"text": "jumps over"}, {"text": "the lazy dog"}]`)},
+	})
+	if err := s.InsertNextLine(ctx, channelID, "new", model.Line{ID: 0, Timestamp: 300, Segments: json.RawMessage(`[{"text": "a quick AND unrelated fox"}]`)}); err != nil {
+		t.Fatalf("InsertNextLine failed: %v", err)
+	}
+	// Lines whose segments are not valid JSON are stored but never match.
+	if err := s.InsertNextLine(ctx, channelID, "new", model.Line{ID: 1, Timestamp: 400, Segments: json.RawMessage(`not json fox`)}); err != nil {
+		t.Fatalf("InsertNextLine (bad segments) failed: %v", err)
+	}
+
+	// Every word must match, in any order, across segments of one line.
+	if got := searchLines("fox quick"); !slices.Equal(got, []string{"new/0", "old/0"}) {
+		t.Errorf("fox quick: got %v", got)
+	}
+	if got := searchLines("over DOG"); !slices.Equal(got, []string{"old/1"}) {
+		t.Errorf("over DOG: got %v", got)
+	}
+	// Query syntax in the input is searched for as text, not parsed.
+	if got := searchLines(`AND "fox`); !slices.Equal(got, []string{"new/0"}) {
+		t.Errorf(`AND "fox: got %v`, got)
+	}
+	if got := searchLines("   "); len(got) != 0 {
+		t.Errorf("blank query: got %v", got)
+	}
+
+	hits, err := s.SearchTranscripts(ctx, channelID, "lazy", 50)
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("lazy: got %v, err %v", hits, err)
+	}
+	if hits[0].Timestamp != 200 || !strings.Contains(hits[0].Snippet, "<mark>lazy</mark>") {
+		t.Errorf("unexpected hit: %+v", hits[0])
+	}
+
+	// Other channels' transcripts are never returned.
+	if err := s.UpsertStream(ctx, &model.Stream{ChannelID: "other", StreamID: "old"}); err != nil {
+		t.Fatalf("UpsertStream failed: %v", err)
+	}
+	if err := s.InsertNextLine(ctx, "other", "old", model.Line{ID: 0, Segments: json.RawMessage(`[{"text": "lazy"}]`)}); err != nil {
+		t.Fatalf("InsertNextLine failed: %v", err)
+	}
+	if got := searchLines("lazy"); !slices.Equal(got, []string{"old/1"}) {
+		t.Errorf("lazy after other channel insert: got %v", got)
+	}
+
+	// A replaced transcript is re-indexed.
+	seedTranscript(t, s, channelID, "old", []model.Line{
+		{ID: 0, Timestamp: 100, Segments: json.RawMessage(`[{"text": "the slow brown fox"}]`)},
+	})
+	if got := searchLines("quick"); !slices.Equal(got, []string{"new/0"}) {
+		t.Errorf("quick after replace: got %v", got)
+	}
+	if got := searchLines("slow"); !slices.Equal(got, []string{"old/0"}) {
+		t.Errorf("slow after replace: got %v", got)
+	}
+
+	// A deleted stream drops out of the index.
+	if err := s.DeleteStreamCascade(ctx, channelID, "new"); err != nil {
+		t.Fatalf("DeleteStreamCascade failed: %v", err)
+	}
+	if got := searchLines("fox"); !slices.Equal(got, []string{"old/0"}) {
+		t.Errorf("fox after delete: got %v", got)
+	}
+	var indexed int
+	if err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM transcripts_fts WHERE channel_id = ? AND stream_id = 'new'", channelID).Scan(&indexed); err != nil {
+		t.Fatalf("failed to count index rows: %v", err)
+	}
+	if indexed != 0 {
+		t.Errorf("expected deleted stream's index rows to be gone, got %d", indexed)
+	}
+}
+
+func TestStore_SearchIndexBackfill(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx := context.Background()
+	if err := s.UpsertStream(ctx, &model.Stream{ChannelID: "c", StreamID: "s"}); err != nil {
+		t.Fatalf("UpsertStream failed: %v", err)
+	}
+	// Written behind the Store's back, as a database predating the index
+	// (or cmd/migrate) would have it.
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO transcripts (channel_id, stream_id, line_id, timestamp, segments) VALUES ('c', 's', 0, 0, '[{"text": "backfilled words"}]')`); err != nil {
+		t.Fatalf("failed to insert raw line: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "DROP TABLE transcripts_fts"); err != nil {
+		t.Fatalf("failed to drop index: %v", err)
+	}
+
+	// Recreating the schema backfills a missing index.
+	if err := EnsureSchema(s.db); err != nil {
+		t.Fatalf("EnsureSchema failed: %v", err)
+	}
+	hits, err := s.SearchTranscripts(ctx, "c", "backfilled", 10)
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("expected 1 backfilled hit, got %v (err %v)", hits, err)
+	}
+
+	// RebuildSearchIndex does not duplicate rows that are already indexed.
+	if err := RebuildSearchIndex(s.db); err != nil {
+		t.Fatalf("RebuildSearchIndex failed: %v", err)
+	}
+	hits, err = s.SearchTranscripts(ctx, "c", "words", 10)
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("expected 1 hit after rebuild, got %v (err %v)", hits, err)
+	}
+}
@@ -9,12 +9,17 @@ import (
 	"live-transcript-server/internal/model"
 )
 
+// streamColumns selects a model.Stream, including its audio format from
+// stream_audio_formats ("" when none was claimed).
+const streamColumns = `channel_id, stream_id, stream_title, start_time, is_live, media_type, activated_time,
+	COALESCE((SELECT f.format FROM stream_audio_formats f WHERE f.channel_id = streams.channel_id AND f.stream_id = streams.stream_id), '')`
+
 // GetRecentStream returns the stream with the most recent activated_time.
 // Returns nil, nil if no stream is found.
 func (s *Store) GetRecentStream(ctx context.Context, channelID string) (*model.Stream, error) {
-	row := s.db.QueryRowContext(ctx, "SELECT channel_id, stream_id, stream_title, start_time, is_live, media_type, activated_time FROM streams WHERE channel_id = ? ORDER BY activated_time DESC LIMIT 1", channelID)
+	row := s.db.QueryRowContext(ctx, "SELECT "+streamColumns+" FROM streams WHERE channel_id = ? ORDER BY activated_time DESC LIMIT 1", channelID)
 	var st model.Stream
-	err := row.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime)
+	err := row.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime, &st.AudioFormat)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -28,9 +33,9 @@ func (s *Store) GetRecentStream(ctx context.Context, channelID string) (*model.S
 // GetStreamByID returns a specific stream by channelID and streamID.
 // Returns nil, nil if no stream is found.
 func (s *Store) GetStreamByID(ctx context.Context, channelID string, streamID string) (*model.Stream, error) {
-	row := s.db.QueryRowContext(ctx, "SELECT channel_id, stream_id, stream_title, start_time, is_live, media_type, activated_time FROM streams WHERE channel_id = ? AND stream_id = ?", channelID, streamID)
+	row := s.db.QueryRowContext(ctx, "SELECT "+streamColumns+" FROM streams WHERE channel_id = ? AND stream_id = ?", channelID, streamID)
 	var st model.Stream
-	err := row.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime)
+	err := row.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime, &st.AudioFormat)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -42,7 +47,7 @@ func (s *Store) GetStreamByID(ctx context.Context, channelID string, streamID st
 
 // GetAllStreams retrieves all streams for a channel, ordered by activated_time descending.
 func (s *Store) GetAllStreams(ctx context.Context, channelID string) ([]model.Stream, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT channel_id, stream_id, stream_title, start_time, is_live, media_type, activated_time FROM streams WHERE channel_id = ? ORDER BY activated_time DESC", channelID)
+	rows, err := s.db.QueryContext(ctx, "SELECT "+streamColumns+" FROM streams WHERE channel_id = ? ORDER BY activated_time DESC", channelID)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +56,7 @@ func (s *Store) GetAllStreams(ctx context.Context, channelID string) ([]model.St
 	var streams []model.Stream
 	for rows.Next() {
 		var st model.Stream
-		if err := rows.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime); err != nil {
+		if err := rows.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime, &st.AudioFormat); err != nil {
 			return nil, err
 		}
 		streams = append(streams, st)
@@ -64,7 +69,7 @@ func (s *Store) GetAllStreams(ctx context.Context, channelID string) ([]model.St
 
 // GetPastStreams retrieves all inactive streams for a channel, ordered by activated_time descending.
 func (s *Store) GetPastStreams(ctx context.Context, channelID string, excludeStreamID string) ([]model.Stream, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT channel_id, stream_id, stream_title, start_time, is_live, media_type, activated_time FROM streams WHERE channel_id = ? AND is_live = 0 AND stream_id != ? ORDER BY activated_time DESC", channelID, excludeStreamID)
+	rows, err := s.db.QueryContext(ctx, "SELECT "+streamColumns+" FROM streams WHERE channel_id = ? AND is_live = 0 AND stream_id != ? ORDER BY activated_time DESC", channelID, excludeStreamID)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +78,7 @@ func (s *Store) GetPastStreams(ctx context.Context, channelID string, excludeStr
 	var streams []model.Stream
 	for rows.Next() {
 		var st model.Stream
-		if err := rows.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime); err != nil {
+		if err := rows.Scan(&st.ChannelID, &st.StreamID, &st.StreamTitle, &st.StartTime, &st.IsLive, &st.MediaType, &st.ActivatedTime, &st.AudioFormat); err != nil {
 			return nil, err
 		}
 		streams = append(streams, st)
@@ -90,8 +95,10 @@ func (s *Store) DeleteStream(ctx context.Context, channelID string, streamID str
 	return err
 }
 
-// DeleteStreamCascade deletes a stream and all of its transcript lines in a
-// single transaction, so a crash between the two deletes cannot orphan lines.
+// DeleteStreamCascade deletes a stream, all of its transcript lines, their
+// admin edits, revisions and search index entries, its clip catalog, its
+// audio format and its media chunk metadata in a single transaction, so a
+// crash between the deletes cannot orphan lines.
 func (s *Store) DeleteStreamCascade(ctx context.Context, channelID string, streamID string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -105,6 +112,24 @@ func (s *Store) DeleteStreamCascade(ctx context.Context, channelID string, strea
 	if _, err := tx.ExecContext(ctx, "DELETE FROM transcripts WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
 		return err
 	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM line_edits WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transcript_revisions WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM clips WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM stream_audio_formats WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM media_chunks WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if err := unindexStream(ctx, tx, channelID, streamID); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
@@ -185,6 +210,22 @@ func (s *Store) SetStreamLive(ctx context.Context, channelID string, streamID st
 	return err
 }
 
+// ClaimStreamAudioFormat fixes the extension a stream's per-line audio is
+// encoded into to format, unless the stream already has one, and returns the
+// stream's format either way. The first claim wins so every chunk of a stream
+// shares one format.
+func (s *Store) ClaimStreamAudioFormat(ctx context.Context, channelID string, streamID string, format string) (string, error) {
+	if _, err := s.db.ExecContext(ctx, "INSERT OR IGNORE INTO stream_audio_formats (channel_id, stream_id, format) VALUES (?, ?, ?)", channelID, streamID, format); err != nil {
+		return "", err
+	}
+	var claimed string
+	err := s.db.QueryRowContext(ctx, "SELECT format FROM stream_audio_formats WHERE channel_id = ? AND stream_id = ?", channelID, streamID).Scan(&claimed)
+	if err != nil {
+		return "", err
+	}
+	return claimed, nil
+}
+
 // StreamExists checks if a stream exists in the database.
 func (s *Store) StreamExists(ctx context.Context, channelID string, streamID string) (bool, error) {
 	var exists bool
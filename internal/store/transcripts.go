@@ -1,55 +1,182 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 
 	"live-transcript-server/internal/model"
 )
 
-// ReplaceTranscript replaces the entire transcript for a channel/stream with new lines in a transaction.
-func (s *Store) ReplaceTranscript(ctx context.Context, channelID string, streamID string, lines []model.Line) error {
+// SyncTranscript makes a stream's stored transcript match lines by writing
+// only the difference: lines with a new ID are inserted, lines whose content
+// changed are updated, and stored lines missing from lines are deleted. It
+// returns the lines it wrote (in line order, as GetTranscript would return
+// them) and the IDs it deleted, so the caller can tell clients exactly what
+// changed. A line counts as changed when its timestamp, segments (ignoring
+// JSON whitespace), media fields or vod_accurate differ. If lines repeats an
+// ID, the last one wins.
+//
+// Admin edits (see EditLine) to lines that remain are kept and still apply
+// afterwards; a deleted line's edit goes with it, so it cannot land on a
+// different line that later reuses the ID. Every change to what clients see
+// of a line is recorded as a sync revision.
+func (s *Store) SyncTranscript(ctx context.Context, channelID string, streamID string, lines []model.Line) (upserted []model.Line, deleted []int, err error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer tx.Rollback()
 
-	// 1. Delete existing lines for this stream
-	if _, err := tx.ExecContext(ctx, "DELETE FROM transcripts WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
-		return err
+	rows, err := tx.QueryContext(ctx, "SELECT "+rawLineColumns+" FROM transcripts t WHERE t.channel_id = ? AND t.stream_id = ? ORDER BY t.line_id ASC", channelID, streamID)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// 2. Insert new lines
-	stmt, err := tx.PrepareContext(ctx, "INSERT INTO transcripts (channel_id, stream_id, line_id, file_id, timestamp, segments, media_available, vod_accurate) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	existing, err := scanLines(rows)
+	rows.Close()
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	stored := make(map[int]model.Line, len(existing))
+	for _, l := range existing {
+		stored[l.ID] = l
 	}
-	defer stmt.Close()
 
-	for _, line := range lines {
-		// Segments is already json.RawMessage ([]byte), so we can cast it to string directly
-		if _, err := stmt.ExecContext(ctx, channelID, streamID, line.ID, line.FileID, line.Timestamp, string(line.Segments), line.MediaAvailable, line.VodAccurate); err != nil {
-			return err
+	incoming := make(map[int]model.Line, len(lines))
+	for _, l := range lines {
+		incoming[l.ID] = l
+	}
+
+	for _, l := range existing {
+		if _, ok := incoming[l.ID]; ok {
+			continue
+		}
+		before, err := getLine(ctx, tx, channelID, streamID, l.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM transcripts WHERE channel_id = ? AND stream_id = ? AND line_id = ?", channelID, streamID, l.ID); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM line_edits WHERE channel_id = ? AND stream_id = ? AND line_id = ?", channelID, streamID, l.ID); err != nil {
+			return nil, nil, err
+		}
+		if err := unindexLine(ctx, tx, channelID, streamID, l.ID); err != nil {
+			return nil, nil, err
+		}
+		if err := recordRevision(ctx, tx, channelID, streamID, l.ID, RevisionSourceSync, before.Segments); err != nil {
+			return nil, nil, err
 		}
+		deleted = append(deleted, l.ID)
 	}
 
-	return tx.Commit()
+	ids := make([]int, 0, len(incoming))
+	for id := range incoming {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		line := incoming[id]
+		old, ok := stored[id]
+		if ok && sameLine(old, line) {
+			continue
+		}
+		var prior json.RawMessage
+		if ok {
+			before, err := getLine(ctx, tx, channelID, streamID, id)
+			if err != nil {
+				return nil, nil, err
+			}
+			prior = before.Segments
+		}
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO transcripts (channel_id, stream_id, line_id, file_id, timestamp, segments, media_available, vod_accurate)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(channel_id, stream_id, line_id) DO UPDATE SET
+			file_id = excluded.file_id,
+			timestamp = excluded.timestamp,
+			segments = excluded.segments,
+			media_available = excluded.media_available,
+			vod_accurate = excluded.vod_accurate
+		`, channelID, streamID, line.ID, line.FileID, line.Timestamp, string(line.Segments), line.MediaAvailable, line.VodAccurate); err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			if err := unindexLine(ctx, tx, channelID, streamID, id); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := indexLine(ctx, tx, channelID, streamID, id); err != nil {
+			return nil, nil, err
+		}
+		// Report the line as clients will see it, with any admin edit.
+		shown, err := getLine(ctx, tx, channelID, streamID, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok || !sameJSON(prior, shown.Segments) {
+			if err := recordRevision(ctx, tx, channelID, streamID, id, RevisionSourceSync, prior); err != nil {
+				return nil, nil, err
+			}
+		}
+		upserted = append(upserted, *shown)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return upserted, deleted, nil
+}
+
+// sameLine reports whether two versions of a line hold the same content.
+func sameLine(a, b model.Line) bool {
+	return a.Timestamp == b.Timestamp &&
+		a.FileID == b.FileID &&
+		a.MediaAvailable == b.MediaAvailable &&
+		a.VodAccurate == b.VodAccurate &&
+		sameJSON(a.Segments, b.Segments)
 }
 
-// DeleteTranscript deletes all transcript lines for a specific stream.
+// DeleteTranscript deletes all transcript lines for a specific stream, along
+// with their admin edits, revisions, media chunk metadata and search index
+// entries.
 func (s *Store) DeleteTranscript(ctx context.Context, channelID string, streamID string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM transcripts WHERE channel_id = ? AND stream_id = ?", channelID, streamID)
-	return err
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transcripts WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM line_edits WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transcript_revisions WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM media_chunks WHERE channel_id = ? AND stream_id = ?", channelID, streamID); err != nil {
+		return err
+	}
+	if err := unindexStream(ctx, tx, channelID, streamID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // InsertNextLine appends a line to the transcript, enforcing that its ID is
 // exactly one past the last stored line (or 0 for an empty transcript). The
 // check and insert share one transaction so concurrent appends cannot race.
-// Returns an error wrapping ErrOutOfSync when the ID does not match.
+// Returns an error wrapping ErrOutOfSync when the ID does not match. A resent
+// line that is already stored with the same timestamp and segments returns
+// ErrDuplicateLine instead and writes nothing; one stored with different
+// content returns ErrLineConflict (which is also ErrOutOfSync).
 func (s *Store) InsertNextLine(ctx context.Context, channelID string, streamID string, line model.Line) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -63,8 +190,8 @@ func (s *Store) InsertNextLine(ctx context.Context, channelID string, streamID s
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
-	if line.ID != last+1 {
-		return fmt.Errorf("expected line id %d, got %d: %w", last+1, line.ID, ErrOutOfSync)
+	if err := checkNextLine(ctx, tx, channelID, streamID, last, line); err != nil {
+		return err
 	}
 
 	if _, err := tx.ExecContext(ctx, `
@@ -73,24 +200,191 @@ func (s *Store) InsertNextLine(ctx context.Context, channelID string, streamID s
 	`, channelID, streamID, line.ID, line.FileID, line.Timestamp, string(line.Segments), line.MediaAvailable, line.VodAccurate); err != nil {
 		return err
 	}
+	if err := indexLine(ctx, tx, channelID, streamID, line.ID); err != nil {
+		return err
+	}
+	if err := recordRevision(ctx, tx, channelID, streamID, line.ID, RevisionSourceLine, nil); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
 
-// GetTranscript retrieves all transcript lines for a channel/stream, ordered by line_id.
+// checkNextLine applies InsertNextLine's rule to line, given the ID of the
+// stream's last stored line. It returns nil when line is next in sequence.
+func checkNextLine(ctx context.Context, tx *sql.Tx, channelID string, streamID string, last int, line model.Line) error {
+	if line.ID == last+1 {
+		return nil
+	}
+	if line.ID > last || line.ID < 0 {
+		return fmt.Errorf("expected line id %d, got %d: %w", last+1, line.ID, ErrOutOfSync)
+	}
+
+	var timestamp int
+	var segments string
+	err := tx.QueryRowContext(ctx, "SELECT timestamp, segments FROM transcripts WHERE channel_id = ? AND stream_id = ? AND line_id = ?", channelID, streamID, line.ID).Scan(&timestamp, &segments)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("expected line id %d, got %d: %w", last+1, line.ID, ErrOutOfSync)
+	}
+	if err != nil {
+		return err
+	}
+	if timestamp != line.Timestamp || !sameJSON([]byte(segments), line.Segments) {
+		return fmt.Errorf("line id %d: %w", line.ID, ErrLineConflict)
+	}
+	return fmt.Errorf("line id %d: %w", line.ID, ErrDuplicateLine)
+}
+
+// sameJSON reports whether a and b are the same JSON once insignificant
+// whitespace is removed, so a retry re-encoded by the worker still matches.
+// Input that is not valid JSON is compared byte for byte.
+func sameJSON(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// InsertNextLines appends an ordered batch of lines in one transaction, under
+// the same rule as InsertNextLine: each line must be next in sequence, and a
+// line already stored with the same content is skipped as a duplicate. It
+// stops at the first line that breaks the rule, commits everything before it,
+// and returns the lines it stored, how many lines it got through (stored plus
+// duplicates), and an error wrapping ErrOutOfSync (or ErrLineConflict). On any
+// other error nothing is stored.
+func (s *Store) InsertNextLines(ctx context.Context, channelID string, streamID string, lines []model.Line) (stored []model.Line, processed int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	last := -1
+	err = tx.QueryRowContext(ctx, "SELECT line_id FROM transcripts WHERE channel_id = ? AND stream_id = ? ORDER BY line_id DESC LIMIT 1", channelID, streamID).Scan(&last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO transcripts (channel_id, stream_id, line_id, file_id, timestamp, segments, media_available, vod_accurate)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	var outOfSync error
+	for _, line := range lines {
+		err := checkNextLine(ctx, tx, channelID, streamID, last, line)
+		if errors.Is(err, ErrDuplicateLine) {
+			processed++
+			continue
+		}
+		if errors.Is(err, ErrOutOfSync) {
+			outOfSync = err
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, channelID, streamID, line.ID, line.FileID, line.Timestamp, string(line.Segments), line.MediaAvailable, line.VodAccurate); err != nil {
+			return nil, 0, err
+		}
+		if err := indexLine(ctx, tx, channelID, streamID, line.ID); err != nil {
+			return nil, 0, err
+		}
+		if err := recordRevision(ctx, tx, channelID, streamID, line.ID, RevisionSourceLine, nil); err != nil {
+			return nil, 0, err
+		}
+		stored = append(stored, line)
+		last = line.ID
+		processed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	return stored, processed, outOfSync
+}
+
+// GetTranscript retrieves all transcript lines for a channel/stream, ordered
+// by line_id, with admin edits applied.
 func (s *Store) GetTranscript(ctx context.Context, channelID string, streamID string) ([]model.Line, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT line_id, file_id, timestamp, segments, media_available, vod_accurate FROM transcripts WHERE channel_id = ? AND stream_id = ? ORDER BY line_id ASC", channelID, streamID)
+	rows, err := s.db.QueryContext(ctx, "SELECT "+lineColumns+" FROM transcripts t "+lineJoins+" WHERE t.channel_id = ? AND t.stream_id = ? ORDER BY t.line_id ASC", channelID, streamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLines(rows)
+}
+
+// GetWorkerTranscript is GetTranscript without admin edits: the lines exactly
+// as the worker sent them, which is what the worker's own copy is compared
+// against.
+func (s *Store) GetWorkerTranscript(ctx context.Context, channelID string, streamID string) ([]model.Line, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+rawLineColumns+" FROM transcripts t WHERE t.channel_id = ? AND t.stream_id = ? ORDER BY t.line_id ASC", channelID, streamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLines(rows)
+}
+
+// GetTranscriptAfter retrieves the transcript lines of a channel/stream with
+// a line_id greater than afterID, ordered by line_id. It is the ranged
+// counterpart of GetTranscript for clients that already hold the lines up to
+// afterID.
+func (s *Store) GetTranscriptAfter(ctx context.Context, channelID string, streamID string, afterID int) ([]model.Line, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+lineColumns+" FROM transcripts t "+lineJoins+" WHERE t.channel_id = ? AND t.stream_id = ? AND t.line_id > ? ORDER BY t.line_id ASC", channelID, streamID, afterID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanLines(rows)
+}
+
+// GetTranscriptRange retrieves the transcript lines of a channel/stream with
+// a line_id in [startID, endID], ordered by line_id, with admin edits applied.
+func (s *Store) GetTranscriptRange(ctx context.Context, channelID string, streamID string, startID, endID int) ([]model.Line, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+lineColumns+" FROM transcripts t "+lineJoins+" WHERE t.channel_id = ? AND t.stream_id = ? AND t.line_id >= ? AND t.line_id <= ? ORDER BY t.line_id ASC", channelID, streamID, startID, endID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLines(rows)
+}
+
+// lineColumns selects a transcript line as clients see it, with its admin
+// edit applied: edited segments replace the worker's, and a hidden line keeps
+// its place but has no segments. Its media chunk's metadata is zero when none
+// was recorded. Queries using it alias transcripts as t and add lineJoins.
+const lineColumns = `t.line_id, t.file_id, t.timestamp,
+	CASE WHEN e.hidden THEN '[]' ELSE COALESCE(e.segments, t.segments) END,
+	t.media_available, t.vod_accurate, COALESCE(e.hidden, 0),
+	COALESCE(m.duration, 0), COALESCE(m.codec, ''), COALESCE(m.width, 0), COALESCE(m.height, 0), COALESCE(m.size_bytes, 0)`
 
+// rawLineColumns selects a transcript line as the worker sent it, in the
+// same shape as lineColumns. The worker knows nothing of chunk metadata, so
+// it is left zero.
+const rawLineColumns = "t.line_id, t.file_id, t.timestamp, t.segments, t.media_available, t.vod_accurate, 0, 0, '', 0, 0, 0"
+
+// lineJoins attaches each line's admin edit and the metadata of its media
+// chunk, if any, for lineColumns.
+const lineJoins = `LEFT JOIN line_edits e ON e.channel_id = t.channel_id AND e.stream_id = t.stream_id AND e.line_id = t.line_id
+	LEFT JOIN media_chunks m ON m.channel_id = t.channel_id AND m.stream_id = t.stream_id AND m.file_id = t.file_id`
+
+// scanLines collects a transcript-line result set selected with lineColumns
+// or rawLineColumns.
+func scanLines(rows *sql.Rows) ([]model.Line, error) {
 	var lines []model.Line
 	for rows.Next() {
 		var l model.Line
 		var segmentsStr string
 		var fileID sql.NullString
-		if err := rows.Scan(&l.ID, &fileID, &l.Timestamp, &segmentsStr, &l.MediaAvailable, &l.VodAccurate); err != nil {
+		if err := rows.Scan(&l.ID, &fileID, &l.Timestamp, &segmentsStr, &l.MediaAvailable, &l.VodAccurate, &l.Hidden,
+			&l.Duration, &l.Codec, &l.Width, &l.Height, &l.SizeBytes); err != nil {
 			return nil, err
 		}
 		l.FileID = fileID.String
@@ -120,26 +414,19 @@ func (s *Store) GetLastLineID(ctx context.Context, channelID string, streamID st
 	return id, nil
 }
 
-// GetLastLine retrieves the last transcript line for a channel/stream.
-// Returns nil, nil if no lines exist.
+// GetLastLine retrieves the last transcript line for a channel/stream, with
+// its admin edit applied. Returns nil, nil if no lines exist.
 func (s *Store) GetLastLine(ctx context.Context, channelID string, streamID string) (*model.Line, error) {
-	row := s.db.QueryRowContext(ctx, "SELECT line_id, file_id, timestamp, segments, media_available, vod_accurate FROM transcripts WHERE channel_id = ? AND stream_id = ? ORDER BY line_id DESC LIMIT 1", channelID, streamID)
-
-	var l model.Line
-	var segmentsStr string
-	var fileID sql.NullString
-	err := row.Scan(&l.ID, &fileID, &l.Timestamp, &segmentsStr, &l.MediaAvailable, &l.VodAccurate)
-	l.FileID = fileID.String
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+	rows, err := s.db.QueryContext(ctx, "SELECT "+lineColumns+" FROM transcripts t "+lineJoins+" WHERE t.channel_id = ? AND t.stream_id = ? ORDER BY t.line_id DESC LIMIT 1", channelID, streamID)
 	if err != nil {
 		return nil, err
 	}
-
-	l.Segments = json.RawMessage(segmentsStr)
-
-	return &l, nil
+	defer rows.Close()
+	lines, err := scanLines(rows)
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+	return &lines[0], nil
 }
 
 // SetMediaAvailable updates the media_available status of a transcript line
@@ -160,6 +447,24 @@ func (s *Store) SetMediaAvailable(ctx context.Context, channelID string, streamI
 	return nil
 }
 
+// SetChunkMedia records the metadata of the media chunk fileID of a stream,
+// replacing any recorded before. It is keyed by file ID rather than line, so
+// it can be written before the line points at the chunk and stays with the
+// chunk if a resync moves the line elsewhere.
+func (s *Store) SetChunkMedia(ctx context.Context, channelID string, streamID string, fileID string, m model.ChunkMedia) error {
+	_, err := s.db.ExecContext(ctx, `
+	INSERT INTO media_chunks (channel_id, stream_id, file_id, duration, codec, width, height, size_bytes)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(channel_id, stream_id, file_id) DO UPDATE SET
+		duration = excluded.duration,
+		codec = excluded.codec,
+		width = excluded.width,
+		height = excluded.height,
+		size_bytes = excluded.size_bytes
+	`, channelID, streamID, fileID, m.Duration, m.Codec, m.Width, m.Height, m.SizeBytes)
+	return err
+}
+
 // GetLastAvailableMediaFiles returns the last 'limit' line ID->FileID map that have media available for a specific stream.
 // If limit is -1, returns all available media files.
 func (s *Store) GetLastAvailableMediaFiles(ctx context.Context, channelID string, streamID string, limit int) (map[int]string, error) {
@@ -201,6 +506,56 @@ func (s *Store) GetFileIDsInRange(ctx context.Context, channelID string, streamI
 	return scanFileIDs(rows)
 }
 
+// lineTimingColumns selects the fields of a line GetLineTimings sets.
+// Queries using it alias transcripts as t and join media_chunks as m.
+const lineTimingColumns = "t.line_id, t.file_id, t.timestamp, t.media_available, COALESCE(m.duration, 0)"
+
+// GetLineTimings returns every line of a stream, ordered by line ID, with only
+// its ID, file ID, timestamp, media availability and chunk duration (zero
+// when unknown) set: enough to find the chunks covering a span of time
+// without loading the transcript text.
+func (s *Store) GetLineTimings(ctx context.Context, channelID string, streamID string) ([]model.Line, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+lineTimingColumns+` FROM transcripts t
+	LEFT JOIN media_chunks m ON m.channel_id = t.channel_id AND m.stream_id = t.stream_id AND m.file_id = t.file_id
+	WHERE t.channel_id = ? AND t.stream_id = ? ORDER BY t.line_id ASC`, channelID, streamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLineTimings(rows)
+}
+
+// GetLineTimingsRange is GetLineTimings for the lines with a line_id in
+// [startID, endID] only.
+func (s *Store) GetLineTimingsRange(ctx context.Context, channelID string, streamID string, startID, endID int) ([]model.Line, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+lineTimingColumns+` FROM transcripts t
+	LEFT JOIN media_chunks m ON m.channel_id = t.channel_id AND m.stream_id = t.stream_id AND m.file_id = t.file_id
+	WHERE t.channel_id = ? AND t.stream_id = ? AND t.line_id BETWEEN ? AND ? ORDER BY t.line_id ASC`, channelID, streamID, startID, endID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLineTimings(rows)
+}
+
+// scanLineTimings collects a result set selected with lineTimingColumns.
+func scanLineTimings(rows *sql.Rows) ([]model.Line, error) {
+	var lines []model.Line
+	for rows.Next() {
+		var l model.Line
+		var fileID sql.NullString
+		if err := rows.Scan(&l.ID, &fileID, &l.Timestamp, &l.MediaAvailable, &l.Duration); err != nil {
+			return nil, err
+		}
+		l.FileID = fileID.String
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
 // GetAllMediaFileIDs returns the file IDs of every line of a stream that has
 // media stored, ordered by line ID. Same selection as GetFileIDsInRange
 // without the bounds — a full-VOD render covers the whole transcript.
@@ -248,8 +603,29 @@ func (s *Store) CountTranscriptMedia(ctx context.Context, channelID string, stre
 	return total, withMedia, nil
 }
 
-// CleanupOrphanedTranscripts deletes transcript lines that do not have a corresponding stream in the streams table.
+// CleanupOrphanedTranscripts deletes transcript lines, and their admin edits,
+// revisions and search index entries, catalogued clips, audio formats and
+// media chunk metadata that do not have a corresponding stream in the streams
+// table.
 func (s *Store) CleanupOrphanedTranscripts(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM transcripts WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)")
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM transcripts WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM line_edits WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM transcript_revisions WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM clips WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM stream_audio_formats WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM media_chunks WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)"); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM transcripts_fts WHERE (channel_id, stream_id) NOT IN (SELECT channel_id, stream_id FROM streams)")
 	return err
 }
@@ -0,0 +1,113 @@
+// Package watchlist matches transcript text against a channel's configured
+// keywords and patterns. Each entry has its own cooldown, so a word repeated
+// through a stream alerts once per window instead of once per line.
+package watchlist
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"live-transcript-server/internal/config"
+)
+
+// defaultCooldown applies when the config leaves CooldownSeconds at zero.
+const defaultCooldown = 5 * time.Minute
+
+// entry is one compiled keyword or pattern. name is what alerts report as the
+// keyword: the keyword as configured, or the pattern's source.
+type entry struct {
+	name string
+	re   *regexp.Regexp
+	// group is the submatch holding the matched text: 1 for keywords, whose
+	// regexp wraps the word in boundary groups, 0 for patterns.
+	group int
+}
+
+// Hit is one entry matching a line.
+type Hit struct {
+	// Keyword is the entry that matched: a configured keyword or pattern.
+	Keyword string
+	// Matched is the text in the line that matched it.
+	Matched string
+}
+
+// Watchlist matches lines against a channel's entries. It is safe for
+// concurrent use.
+type Watchlist struct {
+	// Broadcast mirrors WatchlistConfig.Broadcast.
+	Broadcast bool
+
+	entries  []entry
+	cooldown time.Duration
+
+	// mu guards lastHit, the time each entry (by name) last alerted.
+	mu      sync.Mutex
+	lastHit map[string]time.Time
+}
+
+// New compiles a channel's watchlist. It returns nil for a config with no
+// keywords or patterns; a nil *Watchlist matches nothing.
+func New(cfg config.WatchlistConfig) (*Watchlist, error) {
+	if len(cfg.Keywords) == 0 && len(cfg.Patterns) == 0 {
+		return nil, nil
+	}
+	w := &Watchlist{
+		Broadcast: cfg.Broadcast,
+		cooldown:  defaultCooldown,
+		lastHit:   make(map[string]time.Time),
+	}
+	if cfg.CooldownSeconds > 0 {
+		w.cooldown = time.Duration(cfg.CooldownSeconds) * time.Second
+	}
+	for _, kw := range cfg.Keywords {
+		kw = strings.TrimSpace(kw)
+		if kw == "" {
+			return nil, fmt.Errorf("empty keyword")
+		}
+		// Letters and digits on either side mean the keyword is part of a
+		// longer word, so "cat" does not match "category". Whitespace inside
+		// a phrase matches any run of whitespace.
+		words := strings.Fields(kw)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		re := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(` + strings.Join(words, `\s+`) + `)(?:[^\p{L}\p{N}]|$)`)
+		w.entries = append(w.entries, entry{name: kw, re: re, group: 1})
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		w.entries = append(w.entries, entry{name: p, re: re})
+	}
+	return w, nil
+}
+
+// Match returns the entries text matches that are not cooling down, in
+// config order (keywords before patterns), and starts their cooldown at now.
+// Entries that match while cooling down are left out and do not extend it.
+func (w *Watchlist) Match(text string, now time.Time) []Hit {
+	if w == nil || text == "" {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var hits []Hit
+	for _, e := range w.entries {
+		m := e.re.FindStringSubmatch(text)
+		if m == nil || m[e.group] == "" {
+			continue
+		}
+		if last, ok := w.lastHit[e.name]; ok && now.Sub(last) < w.cooldown {
+			continue
+		}
+		w.lastHit[e.name] = now
+		hits = append(hits, Hit{Keyword: e.name, Matched: m[e.group]})
+	}
+	return hits
+}
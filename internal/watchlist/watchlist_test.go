@@ -0,0 +1,117 @@
+package watchlist
+
+import (
+	"testing"
+	"time"
+
+	"live-transcript-server/internal/config"
+)
+
+func TestNewEmptyIsNil(t *testing.T) {
+	w, err := New(config.WatchlistConfig{Broadcast: true, CooldownSeconds: 10})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if w != nil {
+		t.Fatalf("New with no entries = %+v, want nil", w)
+	}
+	if hits := w.Match("anything", time.Now()); hits != nil {
+		t.Errorf("nil Watchlist Match = %v, want nil", hits)
+	}
+}
+
+func TestNewRejectsBadPattern(t *testing.T) {
+	if _, err := New(config.WatchlistConfig{Patterns: []string{"("}}); err == nil {
+		t.Error("New accepted an invalid pattern")
+	}
+}
+
+func TestMatchKeywords(t *testing.T) {
+	w, err := New(config.WatchlistConfig{Keywords: []string{"cat", "new  song", "c++"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tests := []struct {
+		text string
+		want []Hit
+	}{
+		{"I have a Cat.", []Hit{{Keyword: "cat", Matched: "Cat"}}},
+		{"what category is this", nil},
+		{"concatenate", nil},
+		{"cat", []Hit{{Keyword: "cat", Matched: "cat"}}},
+		{"a New Song today", []Hit{{Keyword: "new  song", Matched: "New Song"}}},
+		{"newsong", nil},
+		{"I write C++ code", []Hit{{Keyword: "c++", Matched: "C++"}}},
+	}
+	now := time.Now()
+	for _, tt := range tests {
+		// Each case is far enough apart that no cooldown is active.
+		now = now.Add(time.Hour)
+		got := w.Match(tt.text, now)
+		if len(got) != len(tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Match(%q)[%d] = %+v, want %+v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	w, err := New(config.WatchlistConfig{Patterns: []string{`(?i)giv(e|ing)\s*away`, `\d{3,}`}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got := w.Match("We are GIVING AWAY 1000 points", time.Now())
+	want := []Hit{
+		{Keyword: `(?i)giv(e|ing)\s*away`, Matched: "GIVING AWAY"},
+		{Keyword: `\d{3,}`, Matched: "1000"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Match = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Match[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMatchCooldownPerEntry(t *testing.T) {
+	w, err := New(config.WatchlistConfig{Keywords: []string{"alpha", "beta"}, CooldownSeconds: 60})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	start := time.Unix(1_700_000_000, 0)
+
+	if hits := w.Match("alpha", start); len(hits) != 1 {
+		t.Fatalf("first alpha: hits = %v, want 1", hits)
+	}
+	// alpha is cooling down, beta is not.
+	hits := w.Match("alpha and beta", start.Add(30*time.Second))
+	if len(hits) != 1 || hits[0].Keyword != "beta" {
+		t.Fatalf("alpha+beta inside cooldown: hits = %v, want only beta", hits)
+	}
+	// A suppressed match does not extend the cooldown.
+	if hits := w.Match("alpha", start.Add(61*time.Second)); len(hits) != 1 {
+		t.Errorf("alpha after cooldown: hits = %v, want 1", hits)
+	}
+}
+
+func TestMatchDefaultCooldown(t *testing.T) {
+	w, err := New(config.WatchlistConfig{Keywords: []string{"alpha"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	start := time.Unix(1_700_000_000, 0)
+	w.Match("alpha", start)
+	if hits := w.Match("alpha", start.Add(defaultCooldown-time.Second)); len(hits) != 0 {
+		t.Errorf("inside default cooldown: hits = %v, want none", hits)
+	}
+	if hits := w.Match("alpha", start.Add(defaultCooldown)); len(hits) != 1 {
+		t.Errorf("after default cooldown: hits = %v, want 1", hits)
+	}
+}
@@ -23,6 +23,10 @@ const (
 	EventPastStreams   EventType = "pastStreams"
 	EventDeletedStream EventType = "deletedStream"
 	EventUpdatedStream EventType = "updatedStream"
+	EventResumed       EventType = "resumed"
+	EventLinesChanged  EventType = "linesChanged"
+	EventUpdatedLine   EventType = "updatedLine"
+	EventKeywordHit    EventType = "keywordHit"
 )
 
 // Message represents a message sent over the WebSocket connection.
@@ -40,6 +44,13 @@ type EventSyncData struct {
 	MediaType    string       `json:"mediaType"`
 	MediaBaseURL string       `json:"mediaBaseUrl"`
 	Transcript   []model.Line `json:"transcript"`
+	// AudioFormat is the extension of the stream's per-line audio files
+	// (e.g. ".opus"); omitted for streams whose audio is ".m4a" from before
+	// encoding profiles.
+	AudioFormat string `json:"audioFormat,omitempty"`
+	// ResumeToken lets the client reconnect with ?resume=<token> and receive
+	// only what it missed. Empty when there is no stream to resume.
+	ResumeToken string `json:"resumeToken"`
 }
 
 // EventNewLineData represents the data sent to notify the client of a new line in the transcript.
@@ -50,6 +61,44 @@ type EventNewLineData struct {
 	MediaAvailable bool            `json:"mediaAvailable"`
 	Segments       json.RawMessage `json:"segments"`
 	VodAccurate    bool            `json:"vodAccurate"`
+	// ResumeToken replaces the client's previous token: it now holds this
+	// line.
+	ResumeToken string `json:"resumeToken"`
+}
+
+// EventLinesChangedData tells clients exactly how a stream's transcript
+// changed when a worker resynced it: Upserted lines (in line order) replace
+// or add the lines with the same IDs, and Deleted IDs are removed. Applying
+// it leaves the client with the server's transcript. ResumeToken replaces the
+// client's previous token.
+type EventLinesChangedData struct {
+	StreamID    string       `json:"streamId"`
+	Upserted    []model.Line `json:"upserted"`
+	Deleted     []int        `json:"deleted"`
+	ResumeToken string       `json:"resumeToken"`
+}
+
+// EventUpdatedLineData notifies clients that an admin corrected or hid (or
+// unhid) a line. Line is the line's complete new state, so a client replaces
+// whatever it holds for Line.ID in StreamID; a hidden line arrives with empty
+// segments. For the current stream, ResumeToken replaces the client's
+// previous token; it is omitted for edits to past streams.
+type EventUpdatedLineData struct {
+	StreamID    string     `json:"streamId"`
+	Line        model.Line `json:"line"`
+	ResumeToken string     `json:"resumeToken,omitempty"`
+}
+
+// EventKeywordHitData tells clients that a new line matched an entry in the
+// channel's watchlist. Keyword is the configured keyword or pattern and
+// Matched the text in the line that matched it. Sent only for channels whose
+// watchlist has broadcast enabled.
+type EventKeywordHitData struct {
+	StreamID  string `json:"streamId"`
+	LineID    int    `json:"lineId"`
+	Timestamp int    `json:"timestamp"`
+	Keyword   string `json:"keyword"`
+	Matched   string `json:"matched"`
 }
 
 // EventNewStreamData represents the data sent to notify the client of a new stream.
@@ -60,6 +109,8 @@ type EventNewStreamData struct {
 	MediaType    string `json:"mediaType"`
 	MediaBaseURL string `json:"mediaBaseUrl"`
 	IsLive       bool   `json:"isLive"`
+	// AudioFormat is as in EventSyncData.
+	AudioFormat string `json:"audioFormat,omitempty"`
 }
 
 // EventStatusData represents the data sent to notify the client of a change in the stream status.
@@ -101,6 +152,16 @@ type EventUpdatedStreamData struct {
 	IsLive      bool   `json:"isLive"`
 }
 
+// EventResumedData ends the delta sent to a client that reconnected with a
+// valid resume token. Everything the client missed (updatedStream, newLine,
+// newMedia) has been sent before it; the client keeps its state instead of
+// expecting a sync. A rejected token gets a normal sync instead.
+type EventResumedData struct {
+	StreamID    string `json:"streamId"`
+	ResumeToken string `json:"resumeToken"`
+	MissedLines int    `json:"missedLines"`
+}
+
 // EventDeletedStreamData notifies clients that a stream has been removed
 // (typically by an admin via the admin UI). Clients should drop the stream
 // from their local state and refresh past-stream lists.
@@ -43,7 +43,9 @@ func IsClientDisconnectError(err error) bool {
 	return false
 }
 
-// Client is a middleman between the websocket connection and the hub.
+// Client is a middleman between a connection and the hub. A WebSocket client
+// (Add) has conn set and is written by the hub's write pump; a listener client
+// (AddListener) has no conn, and its owner drains Messages itself.
 type Client struct {
 	conn *websocket.Conn
 	send chan Message
@@ -72,6 +74,18 @@ func (c *Client) TrySend(msg Message) bool {
 	}
 }
 
+// Messages returns the client's queued messages. Only the owner of a listener
+// client (AddListener) reads it; a WebSocket client's queue belongs to its
+// write pump.
+func (c *Client) Messages() <-chan Message {
+	return c.send
+}
+
+// Done is closed once the client has been removed from the hub.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
 // Hub tracks the WebSocket clients for a single channel key and fans
 // broadcast messages out to them.
 type Hub struct {
@@ -120,6 +134,27 @@ func (h *Hub) Unreserve() {
 // must have claimed a slot with Reserve; Add does not touch the connection
 // count. The pump exits when the client is removed or ctx is done.
 func (h *Hub) Add(ctx context.Context, conn *websocket.Conn) *Client {
+	c := h.register(conn)
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.writePump(ctx, c)
+	}()
+
+	return c
+}
+
+// AddListener registers a client with no WebSocket behind it, for transports
+// that write messages themselves (Server-Sent Events). The caller drains
+// Messages until Done is closed, and must Remove the client when its
+// connection ends. Slot accounting is the same as Add: Reserve first.
+func (h *Hub) AddListener() *Client {
+	return h.register(nil)
+}
+
+// register adds a client for conn (nil for a listener) to the hub.
+func (h *Hub) register(conn *websocket.Conn) *Client {
 	c := &Client{
 		conn: conn,
 		send: make(chan Message, 256),
@@ -134,18 +169,12 @@ func (h *Hub) Add(ctx context.Context, conn *websocket.Conn) *Client {
 	metrics.TotalConnections.Inc()
 	metrics.ClientsPerKey.WithLabelValues(h.key).Inc()
 
-	h.wg.Add(1)
-	go func() {
-		defer h.wg.Done()
-		h.writePump(ctx, c)
-	}()
-
 	return c
 }
 
-// Remove unregisters the client, releases its connection slot, and closes the
-// underlying connection. It is idempotent: a second Remove of the same client
-// finds nothing and returns false without touching the slot count.
+// Remove unregisters the client, releases its connection slot, and closes its
+// WebSocket connection, if any. It is idempotent: a second Remove of the same
+// client finds nothing and returns false without touching the slot count.
 func (h *Hub) Remove(c *Client) bool {
 	h.mu.Lock()
 	found := false
@@ -167,7 +196,9 @@ func (h *Hub) Remove(c *Client) bool {
 	metrics.ClientsPerKey.WithLabelValues(h.key).Dec()
 
 	c.closeOnce.Do(func() { close(c.done) })
-	c.conn.Close()
+	if c.conn != nil {
+		c.conn.Close()
+	}
 	return true
 }
 
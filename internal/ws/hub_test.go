@@ -134,6 +134,37 @@ func TestTrySendAfterRemove(t *testing.T) {
 	h.Wait()
 }
 
+func TestListenerClient(t *testing.T) {
+	h := NewHub("test-listener", 4)
+	if !h.Reserve() {
+		t.Fatal("Reserve failed on an empty hub")
+	}
+	c := h.AddListener()
+
+	h.Broadcast(Message{Event: EventStatus, Data: EventStatusData{StreamID: "s1"}})
+	select {
+	case msg := <-c.Messages():
+		if msg.Event != EventStatus {
+			t.Errorf("expected status, got %s", msg.Event)
+		}
+	default:
+		t.Fatal("broadcast did not reach the listener")
+	}
+
+	// A listener has no connection to close; Remove must still release it.
+	if !h.Remove(c) {
+		t.Error("Remove returned false")
+	}
+	select {
+	case <-c.Done():
+	default:
+		t.Error("Done not closed after Remove")
+	}
+	if got := h.Connections(); got != 0 {
+		t.Errorf("expected 0 connections, got %d", got)
+	}
+}
+
 func TestBroadcastDropsFullClient(t *testing.T) {
 	h := NewHub("test-full-buffer", 4)
 